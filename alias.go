@@ -0,0 +1,200 @@
+package ctxdep
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// aliasedDependency is an internal wrapper to signal to the DependencyContext that the value
+// should also be registered under additional types. This is created by AliasAs().
+type aliasedDependency struct {
+	value      any
+	aliasTypes []reflect.Type
+}
+
+// typedDependency is an internal wrapper to signal to the DependencyContext that the value
+// should be stored under the given type rather than its own type. This is created by AddAs().
+type typedDependency struct {
+	slotType reflect.Type
+	value    any
+}
+
+// AliasAs adds a value to the dependency context that can also be retrieved as each of the
+// given types, which are typically interfaces that the value implements. Normally, the first
+// request for an interface scans the dependency context for a value that implements the
+// interface. With AliasAs, the value is registered under the interfaces up front, so Status
+// shows them immediately and the scan is avoided.
+//
+//	ctxdep.AliasAs(store, reflect.TypeOf((*ReadStore)(nil)).Elem(), reflect.TypeOf((*WriteStore)(nil)).Elem())
+//
+// If the value can't be assigned to one of the types, this panics when the dependency
+// context is created.
+func AliasAs(value any, aliasTypes ...reflect.Type) *aliasedDependency {
+	return &aliasedDependency{
+		value:      value,
+		aliasTypes: aliasTypes,
+	}
+}
+
+// addAliasedValue adds the value of the aliased dependency and registers its slot under
+// each of the alias types.
+func (d *DependencyContext) addAliasedValue(aliased *aliasedDependency) {
+	valueType := reflect.TypeOf(aliased.value)
+	if valueType == nil || valueType.Kind() != reflect.Pointer {
+		panic(fmt.Sprintf("invalid aliased dependency: %v", valueType))
+	}
+	d.addValue(valueType, aliased.value)
+	sa, _ := d.slots.Load(valueType)
+	for _, aliasType := range aliased.aliasTypes {
+		if !valueType.AssignableTo(aliasType) {
+			panic(fmt.Sprintf("aliased dependency %v is not assignable to %v", valueType, aliasType))
+		}
+		if existing, ok := d.slots.Load(aliasType); ok && !d.loose && !d.isIdempotentValue(existing.(*slot), aliased.value) {
+			panic(fmt.Sprintf("a slot for type %v already exists--an alias may not override an existing slot", aliasType))
+		}
+		d.slots.Store(aliasType, sa)
+	}
+}
+
+// boundDependency is an internal marker to signal to the DependencyContext that requests for
+// the interface type should be served by the slot of the concrete type. This is created by
+// Bind().
+type boundDependency struct {
+	interfaceType reflect.Type
+	concreteType  reflect.Type
+}
+
+// Bind registers the slot of the Concrete type under the Interface type when the dependency
+// context is created. Normally, the first request for an interface scans the dependency
+// context for a type that implements it. With Bind, the mapping is made up front, so even the
+// first request is a direct lookup, and the choice of type is explicit:
+//
+//	ctx = ctxdep.NewDependencyContext(ctx, NewPostgresStore, ctxdep.Bind[Store, *PostgresStore]())
+//
+// The Concrete type must be provided by a value or a generator of the same dependency
+// context, otherwise this panics when the dependency context is created. This panics right
+// away if Concrete isn't assignable to Interface.
+func Bind[Interface, Concrete any]() *boundDependency {
+	interfaceType := reflect.TypeOf((*Interface)(nil)).Elem()
+	concreteType := reflect.TypeOf((*Concrete)(nil)).Elem()
+	if interfaceType.Kind() != reflect.Interface {
+		panic(fmt.Sprintf("Bind requires an interface type, got %v", interfaceType))
+	}
+	if !concreteType.AssignableTo(interfaceType) {
+		panic(fmt.Sprintf("%v is not assignable to %v", concreteType, interfaceType))
+	}
+	return &boundDependency{
+		interfaceType: interfaceType,
+		concreteType:  concreteType,
+	}
+}
+
+// applyBindings registers the slots of the concrete types of the bindings under their
+// interface types.
+func (d *DependencyContext) applyBindings() {
+	for _, bound := range d.bindings {
+		sa, ok := d.slots.Load(bound.concreteType)
+		if !ok || sa.(*slot).slotType != bound.concreteType || !sa.(*slot).providesValue() {
+			panic(fmt.Sprintf("no dependency of type %v to bind to %v", bound.concreteType, bound.interfaceType))
+		}
+		if existing, ok := d.slots.Load(bound.interfaceType); ok && existing != sa && !d.loose {
+			panic(fmt.Sprintf("a slot for type %v already exists--a binding may not override an existing slot", bound.interfaceType))
+		}
+		d.slots.Store(bound.interfaceType, sa)
+	}
+	d.bindings = nil
+}
+
+// AddAs adds a value to the dependency context under the interface type I instead of its
+// concrete type. The value can only be retrieved as I, which is useful when the concrete
+// type should stay hidden:
+//
+//	ctx = ctxdep.NewDependencyContext(ctx, ctxdep.AddAs[Store](&postgresStore{}))
+//
+// Generators that take I as a parameter are validated against it directly.
+func AddAs[I any](value I) *typedDependency {
+	slotType := reflect.TypeOf((*I)(nil)).Elem()
+	if slotType.Kind() != reflect.Interface {
+		panic(fmt.Sprintf("AddAs requires an interface type, got %v", slotType))
+	}
+	return &typedDependency{
+		slotType: slotType,
+		value:    value,
+	}
+}
+
+// addTypedValue adds the value of the typed dependency under its declared type.
+func (d *DependencyContext) addTypedValue(typed *typedDependency) {
+	v := reflect.ValueOf(typed.value)
+	if !v.IsValid() || (v.Kind() == reflect.Pointer && v.IsNil()) {
+		panic(fmt.Sprintf("invalid nil value dependency for type %v", typed.slotType))
+	}
+	if existing, ok := d.slots.Load(typed.slotType); ok {
+		if d.isIdempotentValue(existing.(*slot), typed.value) {
+			return
+		}
+		if !d.loose && existing.(*slot).status != StatusRequired {
+			panic(fmt.Sprintf("a slot for type %v already exists--value may not override an existing slot", typed.slotType))
+		}
+	}
+	d.slots.Store(typed.slotType, newValueSlot(typed.slotType, typed.value, StatusDirect))
+}
+
+// ProduceAs returns a generator that makes the result of the given generator under the
+// interface type I instead of its concrete type. This allows generators from different places
+// that return different concrete types to provide the same interface without both of them
+// filling a slot for their concrete types:
+//
+//	ctx = ctxdep.NewDependencyContext(ctx, ctxdep.ProduceAs[Store](NewPostgresStore))
+//
+// The generator must have a single result, other than an error, that is assignable to I. The
+// returned generator takes the same parameters, so it can be used anywhere a generator can,
+// such as with Immediate. If the generator returns a nil pointer, the returned generator
+// returns a nil I.
+func ProduceAs[I any](generator any) any {
+	slotType := reflect.TypeOf((*I)(nil)).Elem()
+	if slotType.Kind() != reflect.Interface {
+		panic(fmt.Sprintf("ProduceAs requires an interface type, got %v", slotType))
+	}
+	genType := reflect.TypeOf(generator)
+	if genType == nil || genType.Kind() != reflect.Func {
+		panic("ProduceAs requires a generator function")
+	}
+	resultTypes := generatorResultTypes(genType)
+	if len(resultTypes) != 1 || !resultTypes[0].AssignableTo(slotType) {
+		panic(fmt.Sprintf("generator for ProduceAs must return a single value assignable to %v", slotType))
+	}
+
+	var inTypes, outTypes []reflect.Type
+	for i := 0; i < genType.NumIn(); i++ {
+		inTypes = append(inTypes, genType.In(i))
+	}
+	for i := 0; i < genType.NumOut(); i++ {
+		if genType.Out(i).AssignableTo(errorType) {
+			outTypes = append(outTypes, genType.Out(i))
+		} else {
+			outTypes = append(outTypes, slotType)
+		}
+	}
+
+	genValue := reflect.ValueOf(generator)
+	return reflect.MakeFunc(reflect.FuncOf(inTypes, outTypes, genType.IsVariadic()), func(args []reflect.Value) []reflect.Value {
+		var results []reflect.Value
+		if genType.IsVariadic() {
+			results = genValue.CallSlice(args)
+		} else {
+			results = genValue.Call(args)
+		}
+		for i, result := range results {
+			if outTypes[i] != slotType {
+				continue
+			}
+			converted := reflect.New(slotType).Elem()
+			if !(result.Kind() == reflect.Pointer && result.IsNil()) {
+				converted.Set(result)
+			}
+			results[i] = converted
+		}
+		return results
+	}).Interface()
+}
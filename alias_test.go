@@ -0,0 +1,158 @@
+package ctxdep
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"reflect"
+	"testing"
+)
+
+var testInterfaceType = reflect.TypeOf((*testInterface)(nil)).Elem()
+var stringerType = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+var anyType = reflect.TypeOf((*any)(nil)).Elem()
+
+func Test_AliasAs(t *testing.T) {
+	ctx := NewDependencyContext(context.Background(), AliasAs(&testImpl{val: 42}, testInterfaceType))
+
+	assert.Equal(t, "*ctxdep.testImpl - direct value set\nctxdep.testInterface - assigned from *ctxdep.testImpl", Status(ctx))
+	assert.Equal(t, 42, Get[testInterface](ctx).getVal())
+	assert.Equal(t, 42, Get[*testImpl](ctx).val)
+}
+
+func Test_AliasAs_MultipleTypes(t *testing.T) {
+	ctx := NewDependencyContext(context.Background(), AliasAs(&testDoodad{Val: "doodad"}, stringerType, anyType))
+
+	assert.Equal(t, "*ctxdep.testDoodad - direct value set\nfmt.Stringer - assigned from *ctxdep.testDoodad\ninterface {} - assigned from *ctxdep.testDoodad", Status(ctx))
+	assert.Equal(t, "doodad", Get[fmt.Stringer](ctx).String())
+}
+
+func Test_AliasAs_GeneratorValidation(t *testing.T) {
+	ctx := NewDependencyContext(context.Background(), AliasAs(&testImpl{val: 42}, testInterfaceType), func(i testInterface) *testWidget {
+		return &testWidget{Val: i.getVal()}
+	})
+
+	assert.Equal(t, 42, Get[*testWidget](ctx).Val)
+}
+
+func Test_AliasAs_Invalid(t *testing.T) {
+	assert.PanicsWithValue(t, "aliased dependency *ctxdep.testWidget is not assignable to ctxdep.testInterface", func() {
+		NewDependencyContext(context.Background(), AliasAs(&testWidget{}, testInterfaceType))
+	})
+
+	assert.PanicsWithValue(t, "a slot for type interface {} already exists--an alias may not override an existing slot", func() {
+		NewDependencyContext(context.Background(), AliasAs(&testImpl{}, anyType), AliasAs(&testDoodad{}, anyType))
+	})
+
+	assert.PanicsWithValue(t, "invalid aliased dependency: ctxdep.testWidget", func() {
+		NewDependencyContext(context.Background(), AliasAs(testWidget{}, testInterfaceType))
+	})
+}
+
+func Test_AddAs(t *testing.T) {
+	var impl testInterface = &testImpl{val: 42}
+	ctx := NewDependencyContext(context.Background(), AddAs(impl), func(i testInterface) *testWidget {
+		return &testWidget{Val: i.getVal()}
+	})
+
+	assert.Equal(t, "*ctxdep.testWidget - uninitialized - generator: (ctxdep.testInterface) *ctxdep.testWidget\nctxdep.testInterface - direct value set", Status(ctx))
+	assert.Equal(t, 42, Get[testInterface](ctx).getVal())
+	assert.Equal(t, 42, Get[*testWidget](ctx).Val)
+
+	// The concrete type is not exposed.
+	_, err := GetWithError[*testImpl](ctx)
+	assert.Error(t, err)
+}
+
+func Test_AddAs_Invalid(t *testing.T) {
+	assert.PanicsWithValue(t, "AddAs requires an interface type, got *ctxdep.testWidget", func() {
+		AddAs(&testWidget{})
+	})
+
+	assert.PanicsWithValue(t, "invalid nil value dependency for type ctxdep.testInterface", func() {
+		NewDependencyContext(context.Background(), AddAs[testInterface](nil))
+	})
+
+	assert.PanicsWithValue(t, "invalid nil value dependency for type ctxdep.testInterface", func() {
+		NewDependencyContext(context.Background(), AddAs[testInterface]((*testImpl)(nil)))
+	})
+
+	assert.PanicsWithValue(t, "a slot for type ctxdep.testInterface already exists--value may not override an existing slot", func() {
+		NewDependencyContext(context.Background(), AddAs[testInterface](&testImpl{}), AddAs[testInterface](&testImpl{}))
+	})
+}
+
+type otherTestImpl struct{}
+
+func (o *otherTestImpl) getVal() int { return 105 }
+
+func Test_ProduceAs(t *testing.T) {
+	ctx := NewDependencyContext(context.Background(), &testWidget{Val: 42},
+		ProduceAs[testInterface](func(w *testWidget) (*testImpl, error) {
+			return &testImpl{val: w.Val}, nil
+		}),
+		// A different concrete type doesn't collide with the interface.
+		func() *otherTestImpl { return &otherTestImpl{} })
+
+	assert.Equal(t, "*ctxdep.otherTestImpl - uninitialized - generator: () *ctxdep.otherTestImpl\n*ctxdep.testWidget - direct value set\nctxdep.testInterface - uninitialized - generator: (*ctxdep.testWidget) ctxdep.testInterface, error", Status(ctx))
+	assert.Equal(t, 42, Get[testInterface](ctx).getVal())
+	assert.Equal(t, 105, Get[*otherTestImpl](ctx).getVal())
+
+	// The concrete type is not exposed.
+	_, err := GetWithError[*testImpl](ctx)
+	assert.Error(t, err)
+}
+
+func Test_ProduceAs_Errors(t *testing.T) {
+	ctx := NewDependencyContext(context.Background(), ProduceAs[testInterface](func() (*testImpl, error) {
+		return nil, errors.New("expected error")
+	}))
+	_, err := GetWithError[testInterface](ctx)
+	assert.EqualError(t, err, "error running generator: ctxdep.testInterface (expected error)")
+
+	ctx = NewDependencyContext(context.Background(), ProduceAs[testInterface](func() *testImpl {
+		return nil
+	}))
+	_, err = GetWithError[testInterface](ctx)
+	assert.EqualError(t, err, "error mapping generator results to context: ctxdep.testInterface (generator returned nil result: ctxdep.testInterface)")
+
+	assert.PanicsWithValue(t, "ProduceAs requires an interface type, got *ctxdep.testImpl", func() {
+		ProduceAs[*testImpl](func() *testImpl { return nil })
+	})
+	assert.PanicsWithValue(t, "ProduceAs requires a generator function", func() {
+		ProduceAs[testInterface](&testImpl{})
+	})
+	assert.PanicsWithValue(t, "generator for ProduceAs must return a single value assignable to ctxdep.testInterface", func() {
+		ProduceAs[testInterface](func() *testWidget { return nil })
+	})
+	assert.PanicsWithValue(t, "generator for ProduceAs must return a single value assignable to ctxdep.testInterface", func() {
+		ProduceAs[testInterface](func() (*testImpl, *testWidget) { return nil, nil })
+	})
+}
+
+func Test_Bind(t *testing.T) {
+	// The binding may come before the concrete type, and picks it over other implementations.
+	ctx := NewDependencyContext(context.Background(), Bind[testInterface, *otherTestImpl](), &testImpl{val: 42}, func() *otherTestImpl {
+		return &otherTestImpl{}
+	})
+
+	assert.Equal(t, "*ctxdep.otherTestImpl - uninitialized - generator: () *ctxdep.otherTestImpl\n*ctxdep.testImpl - direct value set\nctxdep.testInterface - assigned from *ctxdep.otherTestImpl", Status(ctx))
+	assert.Equal(t, 105, Get[testInterface](ctx).getVal())
+	assert.Same(t, Get[*otherTestImpl](ctx), Get[testInterface](ctx))
+}
+
+func Test_Bind_Invalid(t *testing.T) {
+	assert.PanicsWithValue(t, "Bind requires an interface type, got *ctxdep.testImpl", func() {
+		Bind[*testImpl, *testImpl]()
+	})
+	assert.PanicsWithValue(t, "*ctxdep.testWidget is not assignable to ctxdep.testInterface", func() {
+		Bind[testInterface, *testWidget]()
+	})
+	assert.PanicsWithValue(t, "no dependency of type *ctxdep.testImpl to bind to ctxdep.testInterface", func() {
+		NewDependencyContext(context.Background(), Bind[testInterface, *testImpl]())
+	})
+	assert.PanicsWithValue(t, "a slot for type ctxdep.testInterface already exists--a binding may not override an existing slot", func() {
+		NewDependencyContext(context.Background(), AddAs[testInterface](&otherTestImpl{}), &testImpl{}, Bind[testInterface, *testImpl]())
+	})
+}
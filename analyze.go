@@ -0,0 +1,131 @@
+package ctxdep
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// WiringReport describes the dependency context that would be built from a set of
+// dependencies. This is returned from AnalyzeDependencies.
+type WiringReport struct {
+	// Slots are the dependencies that would be in the dependency context, ordered by
+	// type name.
+	Slots []SlotReport
+
+	// Errors are the problems that were found with the dependencies, such as generators
+	// that have parameters that can't be resolved. Each of them is a *ConstructionError.
+	// This is empty if the dependencies are valid.
+	Errors []error
+}
+
+// SlotReport describes a single dependency in a WiringReport.
+type SlotReport struct {
+	// Type is the type of the dependency.
+	Type reflect.Type
+
+	// Key is the name of the dependency if it was added with Keyed.
+	Key string
+
+	// Status is how the dependency is provided. This is StatusGenerator for all generators
+	// since none of them are run.
+	Status SlotStatus
+
+	// Generator is the signature of the generator that creates the dependency, or empty
+	// if the dependency is a direct value.
+	Generator string
+
+	// Immediate is true if the generator would be run when the dependency context is
+	// created.
+	Immediate bool
+}
+
+// AnalyzeDependencies processes the dependencies the same way as NewDependencyContext, but
+// doesn't create the dependency context or run any generators, including immediate ones.
+// Instead, it returns a report of what would be in the dependency context. This is meant
+// for tools that check the wiring of an application.
+//
+// Any problems that would cause NewDependencyContext to panic are returned as an error
+// instead. If the dependencies can't be processed at all, such as when there are multiple
+// dependencies for the same type, the report is nil. If the dependencies fail validation,
+// such as when generators have parameters that can't be resolved, each problem is listed in
+// the Errors of the report as a *ConstructionError, and the returned error is a MultiError
+// of them.
+func AnalyzeDependencies(ctx context.Context, dependencies ...any) (report *WiringReport, err error) {
+	dc := &DependencyContext{}
+	dc.setParentContext(ctx)
+	dc.selfContext = context.WithValue(ctx, dependencyContextKey, dc)
+	// None of the immediate dependencies are run.
+	immediateDone := make(chan error)
+	close(immediateDone)
+	dc.immediateDone = immediateDone
+
+	defer func() {
+		if r := recover(); r != nil {
+			report = nil
+			err = fmt.Errorf("invalid dependencies: %v", r)
+		}
+	}()
+	dc.applyOptions(dependencies)
+	if dc.options.overrides {
+		dc.loose = true
+	}
+	dc.addDependencies(dependencies, nil)
+	dc.applyBindings()
+	dc.inheritCopyOnGet()
+	errs := dc.constructionErrors(hasDependencies(dependencies))
+
+	report = &WiringReport{Slots: dc.slotReports()}
+	sort.Slice(errs, func(i, j int) bool {
+		return errs[i].Message < errs[j].Message
+	})
+	for _, ce := range errs {
+		report.Errors = append(report.Errors, ce)
+	}
+
+	if len(report.Errors) > 0 {
+		return report, &MultiError{Errors: report.Errors}
+	}
+	return report, nil
+}
+
+// slotReports returns a report of each of the dependencies of this dependency context, not
+// including its parents, ordered by type name and then key.
+func (d *DependencyContext) slotReports() []SlotReport {
+	var reports []SlotReport
+	d.slots.Range(func(key, value any) bool {
+		s := value.(*slot)
+		if key.(reflect.Type) != s.slotType {
+			return true
+		}
+		sr := SlotReport{
+			Type:      s.slotType,
+			Status:    s.status,
+			Immediate: s.immediate != nil,
+		}
+		if s.generator != nil {
+			sr.Generator = formatGeneratorDebug(s.generator)
+		}
+		reports = append(reports, sr)
+		return true
+	})
+	d.keyedSlots.Range(func(key, value any) bool {
+		k := key.(keyedSlotKey)
+		reports = append(reports, SlotReport{
+			Type:   k.slotType,
+			Key:    k.name,
+			Status: value.(*slot).status,
+		})
+		return true
+	})
+
+	sort.Slice(reports, func(i, j int) bool {
+		ti, tj := reports[i].Type.String(), reports[j].Type.String()
+		if ti != tj {
+			return ti < tj
+		}
+		return reports[i].Key < reports[j].Key
+	})
+	return reports
+}
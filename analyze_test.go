@@ -0,0 +1,67 @@
+package ctxdep
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"reflect"
+	"testing"
+)
+
+func Test_AnalyzeDependencies(t *testing.T) {
+	called := false
+	report, err := AnalyzeDependencies(context.Background(), &testWidget{Val: 42}, Immediate(func(w *testWidget) *testDoodad {
+		called = true
+		return &testDoodad{}
+	}), Keyed("name", &testImpl{}))
+
+	assert.NoError(t, err)
+	assert.False(t, called)
+	assert.Equal(t, []SlotReport{
+		{Type: reflect.TypeOf(&testDoodad{}), Status: StatusGenerator, Generator: "(*ctxdep.testWidget) *ctxdep.testDoodad", Immediate: true},
+		{Type: reflect.TypeOf(&testImpl{}), Key: "name", Status: StatusDirect},
+		{Type: reflect.TypeOf(&testWidget{}), Status: StatusDirect},
+	}, report.Slots)
+	assert.Empty(t, report.Errors)
+}
+
+func Test_AnalyzeDependencies_Unresolved(t *testing.T) {
+	report, err := AnalyzeDependencies(context.Background(), func(w *testWidget) *testDoodad {
+		return &testDoodad{}
+	}, func(w *testDoodad, i *testImpl) *outputValue {
+		return &outputValue{}
+	})
+
+	assert.EqualError(t, err, "generator for (*ctxdep.testDoodad, *ctxdep.testImpl) *ctxdep.outputValue has dependencies that cannot be resolved; generator for (*ctxdep.testWidget) *ctxdep.testDoodad has dependencies that cannot be resolved")
+	if assert.NotNil(t, report) {
+		assert.Len(t, report.Slots, 2)
+		assert.Len(t, report.Errors, 2)
+	}
+}
+
+func Test_AnalyzeDependencies_Invalid(t *testing.T) {
+	report, err := AnalyzeDependencies(context.Background(), &testWidget{}, &testWidget{})
+
+	assert.Nil(t, report)
+	assert.EqualError(t, err, "invalid dependencies: a slot for type *ctxdep.testWidget already exists--value may not override an existing slot")
+}
+
+func Test_AnalyzeDependencies_Validation(t *testing.T) {
+	// The same checks are done as when the dependency context is created.
+	frozen := NewDependencyContext(context.Background(), WithFreeze(), &testImpl{})
+	_, err := AnalyzeDependencies(frozen, &testWidget{})
+	assert.EqualError(t, err, "cannot add dependencies to a child of a frozen dependency context")
+
+	parent := NewDependencyContext(context.Background(), WithStrictShadowCheck(), &testWidget{})
+	report, err := AnalyzeDependencies(parent, func() *testWidget { return &testWidget{} })
+	assert.EqualError(t, err, "generator () *ctxdep.testWidget shadows the *ctxdep.testWidget direct value of a parent dependency context")
+	if assert.Len(t, report.Errors, 1) {
+		var ce *ConstructionError
+		assert.ErrorAs(t, report.Errors[0], &ce)
+		assert.Equal(t, ConstructionShadowed, ce.Category)
+	}
+
+	_, err = AnalyzeDependencies(context.Background(), Contributes[testInterface](func(w *testWidget) testInterface {
+		return &testImpl{val: w.Val}
+	}))
+	assert.EqualError(t, err, "contribution generator for (*ctxdep.testWidget) ctxdep.testInterface has dependencies that cannot be resolved")
+}
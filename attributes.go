@@ -0,0 +1,35 @@
+package ctxdep
+
+import (
+	"reflect"
+)
+
+// Attributes allows a generator to get scalar attributes of the request, such as the locale
+// or tenant, without each of them being a dependency of its own. If a generator takes an
+// Attributes parameter, it's filled with the attributes that were given with WithAttributes
+// to the dependency context of the generator and its parents:
+//
+//	func GreetingGenerator(attrs ctxdep.Attributes) *Greeting {
+//	    return NewGreeting(attrs["locale"])
+//	}
+//
+// If a dependency context and one of its parents have the same attribute, the value of the
+// dependency context is used. The generator gets its own copy of the attributes. An Attributes
+// parameter can always be satisfied, so it is never a reason for a generator to fail
+// validation; if there are no attributes, it's empty.
+type Attributes map[string]string
+
+var attributesType = reflect.TypeOf(Attributes{})
+
+// attributes returns the attributes of this dependency context and its parents.
+func (d *DependencyContext) attributes() Attributes {
+	result := Attributes{}
+	for dc := d; dc != nil; dc = dc.parentDependencyContext() {
+		for k, v := range dc.options.attributes {
+			if _, ok := result[k]; !ok {
+				result[k] = v
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,30 @@
+package ctxdep
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_Attributes(t *testing.T) {
+	parent := NewDependencyContext(context.Background(), WithAttributes(map[string]string{"tenant": "acme", "locale": "en"}))
+	ctx := NewDependencyContext(parent, WithAttributes(map[string]string{"locale": "fr"}), func(attrs Attributes) *testDoodad {
+		attrs["tenant"] = "modified"
+		return &testDoodad{Val: attrs["locale"]}
+	}, func(attrs Attributes, d *testDoodad) *testWidget {
+		assert.Equal(t, Attributes{"tenant": "acme", "locale": "fr"}, attrs)
+		return &testWidget{Val: 42}
+	})
+
+	assert.Equal(t, "fr", Get[*testDoodad](ctx).Val)
+	assert.Equal(t, 42, Get[*testWidget](ctx).Val)
+}
+
+func Test_Attributes_Empty(t *testing.T) {
+	ctx := NewDependencyContext(context.Background(), func(attrs Attributes) *testWidget {
+		assert.Empty(t, attrs)
+		return &testWidget{Val: 42}
+	})
+
+	assert.Equal(t, 42, Get[*testWidget](ctx).Val)
+}
@@ -3,6 +3,7 @@ package ctxdep
 import (
 	"context"
 	"reflect"
+	"sync/atomic"
 	"testing"
 )
 
@@ -14,6 +15,17 @@ func BenchmarkGetStruct(b *testing.B) {
 	}
 }
 
+func BenchmarkFillDependencyStruct(b *testing.B) {
+	// This is the same as BenchmarkGetStruct without the fast path for direct values.
+	ctx := NewDependencyContext(context.Background(), &testWidget{42})
+	dc := GetDependencyContext(ctx)
+
+	for i := 0; i < b.N; i++ {
+		var widget *testWidget
+		_ = dc.FillDependency(ctx, &widget)
+	}
+}
+
 func BenchmarkGetInterface(b *testing.B) {
 	ctx := NewDependencyContext(context.Background(), func(ctx context.Context) *testImpl {
 		return &testImpl{}
@@ -36,7 +48,7 @@ func BenchmarkGetSimpleGenerator(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		_ = Get[*testWidget](ctx)
 		// Intentionally clear the generated value using a non-public value.
-		s.value = nil
+		s.storeValue(nil)
 	}
 }
 
@@ -54,7 +66,7 @@ func BenchmarkGetGeneratorWithDependency(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		_ = Get[*testDoodad](ctx)
 		// Intentionally clear the generated value using a non-public value.
-		s.value = nil
+		s.storeValue(nil)
 	}
 }
 
@@ -72,6 +84,61 @@ func BenchmarkGetGeneratorWithDependencyAndContext(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		_ = Get[*testDoodad](ctx)
 		// Intentionally clear the generated value using a non-public value.
-		s.value = nil
+		s.storeValue(nil)
+	}
+}
+
+func BenchmarkGetFromDeepParent(b *testing.B) {
+	ctx := NewDependencyContext(context.Background(), &testWidget{42})
+	for i := 0; i < 10; i++ {
+		ctx = NewDependencyContext(ctx, WithoutParentHoisting())
+	}
+
+	for i := 0; i < b.N; i++ {
+		_ = Get[*testWidget](ctx)
 	}
 }
+
+func BenchmarkGetConcurrentColdGenerator(b *testing.B) {
+	// Many goroutines ask for the results of one multi-result generator, and clear the value
+	// they got so the generator keeps having to run again. The perSlot case takes away the
+	// lock shared by the results of the generator, so running it takes the lock of every
+	// result slot instead. That is how the results were locked before the lock was shared.
+	b.Run("shared", func(b *testing.B) {
+		benchmarkConcurrentColdGenerator(b, false)
+	})
+	b.Run("perSlot", func(b *testing.B) {
+		benchmarkConcurrentColdGenerator(b, true)
+	})
+}
+
+func benchmarkConcurrentColdGenerator(b *testing.B, perSlot bool) {
+	ctx := NewDependencyContext(context.Background(), func() (*testWidget, *testDoodad, *testImpl) {
+		return &testWidget{Val: 42}, &testDoodad{Val: "105"}, &testImpl{val: 7}
+	})
+	dc := GetDependencyContext(ctx)
+	if perSlot {
+		dc.slots.Range(func(_, sa any) bool {
+			sa.(*slot).generatorLock = nil
+			return true
+		})
+	}
+	types := []reflect.Type{reflect.TypeOf(&testWidget{}), reflect.TypeOf(&testDoodad{}), reflect.TypeOf(&testImpl{})}
+	var next int32
+
+	b.RunParallel(func(pb *testing.PB) {
+		i := int(atomic.AddInt32(&next, 1))
+		for pb.Next() {
+			switch i % 3 {
+			case 0:
+				_ = Get[*testWidget](ctx)
+			case 1:
+				_ = Get[*testDoodad](ctx)
+			default:
+				_ = Get[*testImpl](ctx)
+			}
+			_ = dc.Invalidate(types[i%3])
+			i++
+		}
+	})
+}
@@ -0,0 +1,111 @@
+package ctxdep
+
+import (
+	"context"
+	"fmt"
+)
+
+// Builder accumulates the dependencies and options for a new dependency context. This is
+// an alternative to passing everything to NewDependencyContext at once, which can be hard
+// to read for large wirings:
+//
+//	dc := ctxdep.NewBuilder(ctx).
+//	    WithOption(ctxdep.WithRecoverGenerators()).
+//	    WithValue(db).
+//	    WithGenerator(UserDataGenerator).
+//	    If(isDev).WithValue(&FakeMailer{}).
+//	    Build()
+//
+// The dependencies are added in the same order as they are given to the builder, exactly
+// as if they were passed to NewDependencyContext.
+type Builder struct {
+	ctx          context.Context
+	dependencies []any
+	condition    *bool
+}
+
+// NewBuilder returns a new Builder for a dependency context on top of ctx.
+func NewBuilder(ctx context.Context) *Builder {
+	return &Builder{
+		ctx: ctx,
+	}
+}
+
+// If makes the next dependency or option that is given to the builder conditional. If cond
+// is false, the next one is skipped. This only applies to the single call that follows it.
+func (b *Builder) If(cond bool) *Builder {
+	b.condition = &cond
+	return b
+}
+
+// With adds any dependencies that can be passed to NewDependencyContext, such as the
+// results of Immediate or Keyed. If this follows a call to If, all the dependencies are
+// skipped if the condition is false.
+func (b *Builder) With(deps ...any) *Builder {
+	return b.add(deps)
+}
+
+// WithValue adds a value to the dependency context.
+func (b *Builder) WithValue(value any) *Builder {
+	return b.add(value)
+}
+
+// WithGenerator adds a generator to the dependency context.
+func (b *Builder) WithGenerator(generator any) *Builder {
+	return b.add(generator)
+}
+
+// WithImmediate adds generators that are called immediately when the dependency context
+// is built. This is the same as adding Immediate(generators...).
+func (b *Builder) WithImmediate(generators ...any) *Builder {
+	return b.add(Immediate(generators...))
+}
+
+// WithOption adds a ContextOption to the dependency context. As with NewDependencyContext,
+// options are applied before any dependencies are added regardless of their order.
+func (b *Builder) WithOption(option ContextOption) *Builder {
+	// Options can't be conditional dependencies, so handle the condition here.
+	if b.condition != nil {
+		cond := *b.condition
+		b.condition = nil
+		if !cond {
+			return b
+		}
+	}
+	b.dependencies = append(b.dependencies, option)
+	return b
+}
+
+// add adds the dependency to the builder, wrapping it with When if there is a pending
+// condition.
+func (b *Builder) add(dep any) *Builder {
+	if b.condition != nil {
+		dep = When(*b.condition, dep)
+		b.condition = nil
+	}
+	b.dependencies = append(b.dependencies, dep)
+	return b
+}
+
+// Build creates the dependency context from the accumulated dependencies. This panics in
+// the same cases as NewDependencyContext.
+func (b *Builder) Build() *DependencyContext {
+	return GetDependencyContext(NewDependencyContext(b.ctx, b.dependencies...))
+}
+
+// BuildWithValidation is the same as Build, except if the dependencies are invalid, such as
+// if there are multiple dependencies for the same type or a generator's parameters can't be
+// provided, an error is returned instead of panicking.
+func (b *Builder) BuildWithValidation() (dc *DependencyContext, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			dc = nil
+			if ce, ok := r.(*ConstructionError); ok {
+				err = fmt.Errorf("invalid dependencies: %w", ce)
+			} else {
+				err = fmt.Errorf("invalid dependencies: %v", r)
+			}
+		}
+	}()
+	return b.Build(), nil
+}
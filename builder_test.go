@@ -0,0 +1,69 @@
+package ctxdep
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_Builder(t *testing.T) {
+	dc := NewBuilder(context.Background()).
+		WithValue(&testWidget{Val: 42}).
+		WithGenerator(func(w *testWidget) *testDoodad {
+			return &testDoodad{Val: "from widget"}
+		}).
+		WithImmediate(func() *testImpl { return &testImpl{val: 105} }).
+		With(Keyed("name", "value")).
+		Build()
+	for range dc.ImmediateDone() {
+	}
+	ctx := dc.Context()
+
+	assert.Equal(t, 42, Get[*testWidget](ctx).Val)
+	assert.Equal(t, "from widget", Get[*testDoodad](ctx).Val)
+	assert.Equal(t, 105, Get[*testImpl](ctx).val)
+	assert.Equal(t, "value", GetKeyed[string](ctx, "name"))
+}
+
+func Test_Builder_If(t *testing.T) {
+	dc := NewBuilder(context.Background()).
+		If(false).WithValue(&testWidget{Val: 1}).
+		If(true).WithValue(&testDoodad{Val: "2"}).
+		WithValue(&testImpl{val: 3}).
+		If(false).WithOption(WithOverrides()).
+		Build()
+
+	assert.False(t, dc.options.overrides)
+	assert.False(t, Has[*testWidget](dc.Context()))
+	assert.Equal(t, "2", Get[*testDoodad](dc.Context()).Val)
+	assert.Equal(t, 3, Get[*testImpl](dc.Context()).val)
+
+	dc = NewBuilder(context.Background()).
+		If(true).WithOption(WithOverrides()).
+		WithValue(&testWidget{Val: 1}).
+		WithValue(&testWidget{Val: 2}).
+		Build()
+
+	assert.Equal(t, 2, Get[*testWidget](dc.Context()).Val)
+}
+
+func Test_Builder_BuildWithValidation(t *testing.T) {
+	dc, err := NewBuilder(context.Background()).
+		WithGenerator(func(w *testWidget) *testDoodad { return &testDoodad{} }).
+		BuildWithValidation()
+
+	assert.Nil(t, dc)
+	assert.EqualError(t, err, "invalid dependencies: generator for (*ctxdep.testWidget) *ctxdep.testDoodad has dependencies that cannot be resolved")
+
+	dc, err = NewBuilder(context.Background()).
+		WithValue(&testWidget{Val: 1}).
+		WithValue(&testWidget{Val: 2}).
+		BuildWithValidation()
+
+	assert.Nil(t, dc)
+	assert.EqualError(t, err, "invalid dependencies: a slot for type *ctxdep.testWidget already exists--value may not override an existing slot")
+
+	dc, err = NewBuilder(context.Background()).WithValue(&testWidget{Val: 1}).BuildWithValidation()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, Get[*testWidget](dc.Context()).Val)
+}
@@ -0,0 +1,131 @@
+package ctxdep
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"time"
+)
+
+// BytesCache is an alternative to Cache for caches that can only store bytes, such as
+// Redis or memcached. This is used with CachedBytes, which takes care of serializing the
+// results of the generator function.
+type BytesCache interface {
+	// GetBytes returns the value for the given key, and if it was found.
+	GetBytes(ctx context.Context, key string) ([]byte, bool)
+
+	// SetBytes sets the value for the given key, and sets the TTL for the key. If the
+	// TTL is 0, the key will not expire.
+	SetBytes(ctx context.Context, key string, value []byte, ttl time.Duration)
+}
+
+// CodecOptions controls how the results of a generator function are serialized for a
+// BytesCache. If either function is nil, the encoding/json function is used.
+type CodecOptions struct {
+	// Marshal serializes a value.
+	Marshal func(v any) ([]byte, error)
+
+	// Unmarshal deserializes the data into the value pointed to by v.
+	Unmarshal func(data []byte, v any) error
+}
+
+// bytesCacheEnvelope is what is serialized to a BytesCache. Each of the results of the
+// generator function is serialized on its own, so they can be deserialized into their
+// known types.
+type bytesCacheEnvelope struct {
+	Values    [][]byte
+	SavedTime time.Time
+	TTL       time.Duration
+}
+
+// bytesCacheAdapter adapts a BytesCache to the Cache interface for a specific generator
+// function, whose result types are needed to deserialize the cached values.
+type bytesCacheAdapter struct {
+	cache      BytesCache
+	codec      CodecOptions
+	valueTypes []reflect.Type
+}
+
+// CachedBytes is the same as CachedOpts, but for a cache that stores bytes. The non-error
+// results of the generator function are serialized using the codec, and deserialized back
+// into the result types of the generator function when they are found in the cache. The
+// time the results were saved and their TTL are kept alongside them.
+//
+// If the cached bytes can't be deserialized, this is treated as a cache miss.
+func CachedBytes(cache BytesCache, codec CodecOptions, generator any, opts CtxCacheOptions) any {
+	genType := reflect.TypeOf(generator)
+	if genType == nil || genType.Kind() != reflect.Func {
+		panic("generator must be a function")
+	}
+	if codec.Marshal == nil {
+		codec.Marshal = json.Marshal
+	}
+	if codec.Unmarshal == nil {
+		codec.Unmarshal = json.Unmarshal
+	}
+	adapter := &bytesCacheAdapter{
+		cache: cache,
+		codec: codec,
+	}
+	for i := 0; i < genType.NumOut(); i++ {
+		if out := genType.Out(i); !out.ConvertibleTo(errorType) {
+			adapter.valueTypes = append(adapter.valueTypes, out)
+		}
+	}
+	return CachedOpts(adapter, generator, opts)
+}
+
+func (b *bytesCacheAdapter) Get(ctx context.Context, key string) []any {
+	data, ok := b.cache.GetBytes(ctx, key)
+	if !ok {
+		return nil
+	}
+	var envelope bytesCacheEnvelope
+	err := b.codec.Unmarshal(data, &envelope)
+	if err != nil {
+		logf("ERROR: Failed to decode cached value for %s: %v\n", key, err)
+		return nil
+	}
+	if len(envelope.Values) != len(b.valueTypes) {
+		logf("ERROR: Failed to decode cached value for %s: expected %d values, got %d\n", key, len(b.valueTypes), len(envelope.Values))
+		return nil
+	}
+
+	result := make([]any, 0, len(b.valueTypes)+2)
+	for i, valueType := range b.valueTypes {
+		value := reflect.New(valueType)
+		if valueType.Kind() == reflect.Pointer {
+			// Decode into a new value rather than a nil pointer.
+			value.Elem().Set(reflect.New(valueType.Elem()))
+		}
+		err = b.codec.Unmarshal(envelope.Values[i], value.Interface())
+		if err != nil {
+			logf("ERROR: Failed to decode cached value for %s: %v\n", key, err)
+			return nil
+		}
+		result = append(result, value.Elem().Interface())
+	}
+	return append(result, envelope.SavedTime, envelope.TTL)
+}
+
+func (b *bytesCacheAdapter) SetTTL(ctx context.Context, key string, value []any, ttl time.Duration) {
+	// The last two values are the time the results were saved and the TTL.
+	envelope := bytesCacheEnvelope{
+		SavedTime: value[len(value)-2].(time.Time),
+		TTL:       value[len(value)-1].(time.Duration),
+	}
+	for _, v := range value[:len(value)-2] {
+		data, err := b.codec.Marshal(v)
+		if err != nil {
+			logf("ERROR: Failed to encode value to cache for %s: %v\n", key, err)
+			return
+		}
+		envelope.Values = append(envelope.Values, data)
+	}
+	data, err := b.codec.Marshal(envelope)
+	if err != nil {
+		logf("ERROR: Failed to encode value to cache for %s: %v\n", key, err)
+		return
+	}
+	b.cache.SetBytes(ctx, key, data, ttl)
+}
@@ -0,0 +1,109 @@
+package ctxdep
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"log"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testBytesCache struct {
+	lock    sync.Mutex
+	values  map[string][]byte
+	lastTtl time.Duration
+}
+
+func (c *testBytesCache) GetBytes(_ context.Context, key string) ([]byte, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	value, ok := c.values[key]
+	return value, ok
+}
+
+func (c *testBytesCache) SetBytes(_ context.Context, key string, value []byte, ttl time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.values[key] = value
+	c.lastTtl = ttl
+}
+
+func Test_CachedBytes(t *testing.T) {
+	cache := &testBytesCache{values: map[string][]byte{}}
+
+	calls := 0
+	generator := func(ctx context.Context, key *inputValue) (*outputValue, *testWidget, error) {
+		calls++
+		return &outputValue{Value: key.Value}, &testWidget{Val: 42}, nil
+	}
+
+	for i := 0; i < 2; i++ {
+		ctx := NewDependencyContext(context.Background(), &inputValue{Value: "1"}, CachedBytes(cache, CodecOptions{}, generator, CtxCacheOptions{TTL: time.Minute}))
+		assert.Equal(t, "1", Get[*outputValue](ctx).Value)
+		assert.Equal(t, 42, Get[*testWidget](ctx).Val)
+	}
+	assert.Equal(t, 1, calls)
+	assert.Contains(t, cache.values, "1//outputValue:testWidget")
+	assert.Equal(t, time.Minute, cache.lastTtl)
+}
+
+func Test_CachedBytes_Codec(t *testing.T) {
+	cache := &testBytesCache{values: map[string][]byte{}}
+
+	generator := func(ctx context.Context, key *inputValue) (*outputValue, error) {
+		return &outputValue{Value: key.Value}, nil
+	}
+	codec := CodecOptions{
+		Marshal: func(v any) ([]byte, error) {
+			return nil, errors.New("expected error")
+		},
+	}
+
+	logger := &testLogger{}
+	SetLogger(logger)
+	defer SetLogger(log.Default())
+
+	ctx := NewDependencyContext(context.Background(), &inputValue{Value: "1"}, CachedBytes(cache, codec, generator, CtxCacheOptions{TTL: time.Minute}))
+	assert.Equal(t, "1", Get[*outputValue](ctx).Value)
+	assert.Empty(t, cache.values)
+	assert.Equal(t, []string{"ERROR: Failed to encode value to cache for 1//outputValue: expected error\n"}, logger.getMessages())
+}
+
+func Test_CachedBytes_BadData(t *testing.T) {
+	cache := &testBytesCache{values: map[string][]byte{"1//outputValue": []byte("not json")}}
+
+	calls := 0
+	generator := func(ctx context.Context, key *inputValue) (*outputValue, error) {
+		calls++
+		return &outputValue{Value: key.Value}, nil
+	}
+
+	ctx := NewDependencyContext(context.Background(), &inputValue{Value: "1"}, CachedBytes(cache, CodecOptions{}, generator, CtxCacheOptions{TTL: time.Minute}))
+	assert.Equal(t, "1", Get[*outputValue](ctx).Value)
+	assert.Equal(t, 1, calls)
+}
+
+func Test_CachedBytes_NilResults(t *testing.T) {
+	cache := &testBytesCache{values: map[string][]byte{}}
+
+	calls := 0
+	generator := func(ctx context.Context, key *inputValue) (*outputValue, *testWidget, error) {
+		calls++
+		return &outputValue{Value: key.Value}, nil, nil
+	}
+
+	cached := CachedBytes(cache, CodecOptions{}, generator, CtxCacheOptions{
+		TTL:             time.Minute,
+		CacheNilResults: true,
+	}).(func(context.Context, *inputValue) (*outputValue, *testWidget, error))
+
+	for i := 0; i < 2; i++ {
+		ov, widget, err := cached(context.Background(), &inputValue{Value: "1"})
+		assert.NoError(t, err)
+		assert.Equal(t, "1", ov.Value)
+		assert.Nil(t, widget)
+	}
+	assert.Equal(t, 1, calls)
+}
@@ -3,8 +3,9 @@ package ctxdep
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"math/rand"
 	"reflect"
 	"runtime"
 	"strings"
@@ -41,6 +42,39 @@ func RegisterCacheKeyProvider(t reflect.Type, f func(any) ([]byte, error)) {
 	}
 }
 
+// RegisterKeyProvider is the same as RegisterCacheKeyProvider, but the type is T and the
+// function is given the value as a T:
+//
+//	ctxdep.RegisterKeyProvider(func(u *User) ([]byte, error) {
+//	    return []byte(u.ID), nil
+//	})
+//
+// If T is an interface, the function is used for any type that implements it.
+func RegisterKeyProvider[T any](f func(T) ([]byte, error)) {
+	RegisterCacheKeyProvider(reflect.TypeOf((*T)(nil)).Elem(), func(v any) ([]byte, error) {
+		return f(v.(T))
+	})
+}
+
+// cacheKeyMarshaler serializes the parameters that don't have a more specific way to make
+// their cache key. This is set with RegisterCacheKeyMarshaler.
+var cacheKeyMarshaler = json.Marshal
+
+// RegisterCacheKeyMarshaler replaces the function that is used to serialize parameters of
+// cached generators when making their cache keys. By default, this is json.Marshal. This is
+// only used for parameters that don't have a provider registered with
+// RegisterCacheKeyProvider, and don't implement Keyable or fmt.Stringer. If the serialized
+// value is "{}", the name of the type is still used instead. This allows a faster, or more
+// lenient, serializer to be used, such as one that includes unexported fields. Passing nil
+// restores json.Marshal. This is not safe to call concurrently with cached generators, so it
+// should be called during initialization.
+func RegisterCacheKeyMarshaler(marshaler func(any) ([]byte, error)) {
+	if marshaler == nil {
+		marshaler = json.Marshal
+	}
+	cacheKeyMarshaler = marshaler
+}
+
 // Cache is an interface for a cache that can be used with the Cached() function.
 // The cache must be safe for concurrent use. The cache is not required to
 // support locking, but if it does not support locking then the generator
@@ -62,6 +96,19 @@ type Cache interface {
 	SetTTL(ctx context.Context, key string, value []any, ttl time.Duration)
 }
 
+// TaggableCache is a Cache that can also index its entries by tags, so that all the entries
+// with a tag can be removed at once. This is required to use the CacheTags option.
+type TaggableCache interface {
+	Cache
+
+	// SetTTLTagged is the same as SetTTL, but also indexes the entry under each of the
+	// tags.
+	SetTTLTagged(ctx context.Context, key string, value []any, ttl time.Duration, tags []string)
+
+	// DeleteByTag removes all the entries that are indexed under the tag.
+	DeleteByTag(ctx context.Context, tag string)
+}
+
 // CtxCacheOptions contains the options for the CachedOpts function.
 type CtxCacheOptions struct {
 	// TTL is the time-to-live for the cache entry. If TTL is 0, the cache
@@ -83,8 +130,91 @@ type CtxCacheOptions struct {
 	// entry is always fresh and fetching new data before the cache entry expires.
 	RefreshPercentage float64
 
+	// SlidingTTL causes every read of a cache entry to save it again with the current
+	// time, so that it expires a full TTL after it was last read rather than after it
+	// was created. The TTL of the entry is kept. If the entry is due to be refreshed
+	// because of RefreshPercentage, it isn't saved again since the refresh does that.
+	SlidingTTL bool
+
+	// ShouldCache is an optional function that is called with the non-error results of
+	// the generator function. If it returns false, the results are returned to the
+	// caller but are not saved in the cache. This is useful for results that are valid
+	// but should not be cached, such as empty lists. If ShouldCache is nil, all valid
+	// results are cached.
+	ShouldCache func(results []any) bool
+
+	// CacheNilResults allows results of the generator function that are nil, or otherwise
+	// the zero value, to be cached. This is for generators that return multiple results
+	// where some of them may legitimately be absent. Results are still never cached if
+	// the generator function returns an error.
+	CacheNilResults bool
+
+	// NegativeCache allows results of the generator function that are nil, or otherwise the
+	// zero value, to be cached as a miss for NegativeTTL instead of not being cached at all.
+	// This is for lookups that legitimately find nothing, so that repeated requests for keys
+	// that are known to be absent don't call the generator function each time. A miss is
+	// stored with nil in place of each zero result, and is returned as the zero values. This
+	// has no effect if CacheNilResults is set, since those results are cached as they are.
+	NegativeCache bool
+
+	// NegativeTTL is the time-to-live for misses that are cached because of NegativeCache.
+	// This is normally shorter than the TTL so that a value that appears is found soon. If
+	// NegativeTTL is 0, misses are not cached.
+	NegativeTTL time.Duration
+
+	// LockWaitTimeout is the longest time to wait for another caller that is already
+	// calling the generator function for the same cache key. If the wait is longer than
+	// this, the generator function is called directly. This trades a potential duplicate
+	// call of the generator function for bounded latency. If LockWaitTimeout is 0, the
+	// wait is only bounded by the context.
+	LockWaitTimeout time.Duration
+
+	// CacheTags is an optional function that is called with the results of the generator
+	// function before they are cached, and returns the tags to index the cache entry
+	// under. All the entries with a tag can then be removed with InvalidateTag, such as
+	// when the entity that they were derived from changes. The cache must be a
+	// TaggableCache to use this.
+	CacheTags func(results []any) []string
+
+	// LockMaxRetries is the most times to wait for other callers that are calling the
+	// generator function for the same cache key. Each time one of them finishes, the
+	// waiting callers retry getting the lock, so under heavy contention a caller may wait
+	// several times. Once this is exceeded, the generator function is called directly,
+	// the same as with LockWaitTimeout. If LockMaxRetries is 0, the number of retries is
+	// not limited.
+	LockMaxRetries int
+
+	// RefreshContextProvider is an optional function that returns the context for the
+	// background refresh of a cache entry, given the context of the caller that triggered
+	// it. By default, the refresh uses the caller's context, so it is canceled along with
+	// the caller. This allows the refresh to be detached from the caller and given its own
+	// timeout.
+	RefreshContextProvider func(parent context.Context) context.Context
+
+	// KeyTransformer is an optional function that is called with each cache key before it is
+	// used. The key it returns is what is used for the cache and for locking. This allows for
+	// keys that are built from sensitive values to be hashed before they are stored.
+	KeyTransformer func(rawKey string) string
+
+	// ContextKeyFunc is an optional function that is called with the context of each call,
+	// and returns a string that is added to the cache key that is made from the parameters.
+	// This is for when the results vary by something in the context, such as the tenant of
+	// the request, rather than by the parameters of the generator function. If it returns
+	// an error, the error is logged and the generator function is called without caching.
+	ContextKeyFunc func(ctx context.Context) (string, error)
+
+	// TTLJitter randomizes the TTL of each cache entry by up to this fraction of the TTL
+	// in either direction. For instance, 0.1 makes the TTL anywhere from 90% to 110% of
+	// what it would otherwise be. This keeps entries that were created at the same time
+	// from all expiring at the same time. If TTLJitter is 0, the TTL is used as is.
+	TTLJitter float64
+
 	// now is used for testing purposes to override the current time.
 	now func() time.Time
+
+	// random is used for testing purposes to override the source of the TTL jitter. It
+	// returns a number in [0.0,1.0).
+	random func() float64
 }
 
 // CacheDurationProvider is a type alias for a function that takes a slice of any type
@@ -236,6 +366,9 @@ func CachedOpts(cache Cache, generator any, opts CtxCacheOptions) any {
 	if opts.now == nil {
 		opts.now = time.Now
 	}
+	if opts.random == nil {
+		opts.random = rand.Float64
+	}
 
 	state := makeStateForGenerator(cache, generator, opts)
 
@@ -256,29 +389,51 @@ func CachedOpts(cache Cache, generator any, opts CtxCacheOptions) any {
 
 		cacheKey, err := generatorParamKeys(args)
 		if err != nil {
-			log.Printf("ERROR: Failed to generate cache key: %v\n", err)
+			logf("ERROR: Failed to generate cache key: %v\n", err)
 			return state.baseGenerator.Call(args)
 		}
 
+		if state.opts.ContextKeyFunc != nil {
+			contextKey, err := state.opts.ContextKeyFunc(ctx)
+			if err != nil {
+				logf("ERROR: Failed to generate context cache key: %v\n", err)
+				return state.baseGenerator.Call(args)
+			}
+			cacheKey += "//" + contextKey
+		}
+
 		cacheKey += "//" + state.returnTypeKey
+		if ns := cacheNamespace(ctx); ns != "" {
+			cacheKey = ns + "//" + cacheKey
+		}
+		if state.opts.KeyTransformer != nil {
+			cacheKey = state.opts.KeyTransformer(cacheKey)
+		}
 
 		intUnlock, err := state.internalLock.lock(ctx, cacheKey)
 		if intUnlock != nil {
 			defer intUnlock()
 		}
 
-		if err != nil {
+		if err == ErrLockWaitExceeded {
+			// We waited as long as we were allowed to for someone else to fill
+			// the cache. Rather than waiting more, call the backing function
+			// directly at the cost of a potential duplicate call.
+		} else if err != nil {
 			// If we can't lock the key, just call the backing function
 			// If this is due to a timeout, it's on the called function
 			// to handle the timeout.
-			log.Printf("Failed to lock cache key: %v\n", err)
+			logf("Failed to lock cache key: %v\n", err)
 		}
 
-		cachedValues := cache.Get(ctx, cacheKey)
-		if cachedValues != nil {
-			returnVals, savedTime, ttl := generateCacheResult(state.outTypes, cachedValues)
-			handlePreRefresh(ctx, cacheKey, state, args, savedTime, ttl)
-			return returnVals
+		if !isCacheBypassed(ctx) {
+			cachedValues := cache.Get(ctx, cacheKey)
+			if cachedValues != nil {
+				returnVals, savedTime, ttl := generateCacheResult(state.outTypes, cachedValues)
+				handleSlidingTTL(ctx, cacheKey, state, cachedValues, savedTime, ttl)
+				handlePreRefresh(ctx, cacheKey, state, args, savedTime, ttl)
+				return returnVals
+			}
 		}
 
 		return callBackingFunction(ctx, args, cacheKey, state)
@@ -301,6 +456,9 @@ func makeStateForGenerator(cache Cache, generator any, opts CtxCacheOptions) *ca
 	if genType.Kind() != reflect.Func {
 		panic("generator must be a function")
 	}
+	if _, ok := cache.(TaggableCache); opts.CacheTags != nil && !ok {
+		panic("CacheTags requires a TaggableCache")
+	}
 
 	// Get this for later when we have to call it
 	baseGenerator := reflect.ValueOf(generator)
@@ -335,7 +493,10 @@ func makeStateForGenerator(cache Cache, generator any, opts CtxCacheOptions) *ca
 
 	returnTypeKey := generatorReturnTypesKey(outTypes)
 
-	cacheLock := internalLock{}
+	cacheLock := internalLock{
+		maxWait:    opts.LockWaitTimeout,
+		maxRetries: opts.LockMaxRetries,
+	}
 
 	state := cacheState{
 		opts:          opts,
@@ -379,12 +540,17 @@ func handlePreRefresh(ctx context.Context, cacheKey string, state *cacheState, a
 		return
 	}
 
+	if opts.RefreshContextProvider != nil {
+		ctx, args = refreshContextArgs(ctx, state, args)
+	}
+
 	// Refresh the cache entry
 	go func() {
-		// At this point, we're inheriting the ctx of the caller. This is
-		// so any timeouts associated with the caller are inherited by the
-		// background goroutine. This is important because we don't want
-		// the background goroutine to run forever.
+		// At this point, we're inheriting the ctx of the caller, unless a
+		// RefreshContextProvider is set. This is so any timeouts associated
+		// with the caller are inherited by the background goroutine. This is
+		// important because we don't want the background goroutine to run
+		// forever.
 		//
 		// This is called at the same time a regular call to the backing
 		// function would be called, so the expectation is that the backing
@@ -394,10 +560,10 @@ func handlePreRefresh(ctx context.Context, cacheKey string, state *cacheState, a
 			// from panics. We don't want to crash the program because of
 			// a panic in a background goroutine.
 			if p := recover(); p != nil {
-				log.Printf("Panic in background goroutine refreshing cache: %v\n", p)
+				logf("Panic in background goroutine refreshing cache: %v\n", p)
 				buf := make([]byte, 1<<16)
 				stackSize := runtime.Stack(buf, true)
-				log.Printf("Stack trace: %s\n", buf[:stackSize])
+				logf("Stack trace: %s\n", buf[:stackSize])
 			}
 		}()
 
@@ -406,6 +572,22 @@ func handlePreRefresh(ctx context.Context, cacheKey string, state *cacheState, a
 	}()
 }
 
+// refreshContextArgs gets the context for a background refresh from the
+// RefreshContextProvider and substitutes it for the context parameter of the
+// generator function, if it has one.
+func refreshContextArgs(ctx context.Context, state *cacheState, args []reflect.Value) (context.Context, []reflect.Value) {
+	refreshCtx := state.opts.RefreshContextProvider(ctx)
+	refreshArgs := make([]reflect.Value, len(args))
+	for i, arg := range args {
+		if state.inTypes[i] == contextType {
+			refreshArgs[i] = reflect.ValueOf(refreshCtx)
+		} else {
+			refreshArgs[i] = arg
+		}
+	}
+	return refreshCtx, refreshArgs
+}
+
 // shouldPreRefresh determines if the cache entry should be refreshed based on the given state, TTL, and saved time.
 //
 // Parameters:
@@ -471,7 +653,10 @@ func generateCacheResult(outTypes []reflect.Type, cachedValues []any) ([]reflect
 			val := reflect.New(outType).Elem()
 			cachedValue := reflect.ValueOf(cachedValues[cachedValueIndex])
 			cachedValueIndex++
-			val.Set(cachedValue)
+			if cachedValue.IsValid() {
+				// An untyped nil from the cache is left as the zero value.
+				val.Set(cachedValue)
+			}
 			returnVals[i] = val
 		}
 	}
@@ -496,6 +681,7 @@ func callBackingFunction(ctx context.Context, args []reflect.Value, cacheKey str
 	results := state.baseGenerator.Call(args)
 
 	cacheVals := make([]any, 0)
+	miss := false
 
 	// Verify that the results are valid.
 	for _, result := range results {
@@ -505,25 +691,172 @@ func callBackingFunction(ctx context.Context, args []reflect.Value, cacheKey str
 				return results
 			}
 			continue
-		} else if result.IsZero() {
-			// If the result is nil, don't cache the result
-			return results
+		} else if result.IsZero() && !state.opts.CacheNilResults {
+			if !state.opts.NegativeCache {
+				// If the result is nil, don't cache the result
+				return results
+			}
+			// Remember the miss, which is returned as the zero value when it's found.
+			miss = true
+			cacheVals = append(cacheVals, nil)
+			continue
 		}
 
 		cacheVals = append(cacheVals, result.Interface())
 	}
 
-	ttl := state.opts.DurationProvider(state.opts, cacheVals)
+	if miss {
+		storeNegativeCacheValues(ctx, cacheKey, state, cacheVals)
+		return results
+	}
+
+	if state.opts.ShouldCache != nil && !state.opts.ShouldCache(cacheVals) {
+		return results
+	}
+
+	ttl := jitterTTL(state.opts, state.opts.DurationProvider(state.opts, cacheVals))
 	now := state.opts.now()
 	cacheVals = append(cacheVals, now)
 	cacheVals = append(cacheVals, ttl)
 
 	if ttl > 0 {
-		state.cache.SetTTL(ctx, cacheKey, cacheVals, ttl)
+		storeCacheValues(ctx, cacheKey, state, cacheVals, ttl)
 	}
 	return results
 }
 
+// storeNegativeCacheValues saves the values of a miss in the cache for the NegativeTTL. The
+// values have nil in place of each zero result.
+func storeNegativeCacheValues(ctx context.Context, cacheKey string, state *cacheState, cacheVals []any) {
+	ttl := jitterTTL(state.opts, state.opts.NegativeTTL)
+	if ttl <= 0 {
+		return
+	}
+	cacheVals = append(cacheVals, state.opts.now(), ttl)
+	state.cache.SetTTL(ctx, cacheKey, cacheVals, ttl)
+}
+
+// storeCacheValues saves the values in the cache, along with their tags if the CacheTags
+// option is set. The values must end with the saved time and the TTL.
+func storeCacheValues(ctx context.Context, cacheKey string, state *cacheState, cacheVals []any, ttl time.Duration) {
+	var tags []string
+	if state.opts.CacheTags != nil {
+		tags = state.opts.CacheTags(cacheVals[:len(cacheVals)-2])
+	}
+	if len(tags) > 0 {
+		state.cache.(TaggableCache).SetTTLTagged(ctx, cacheKey, cacheVals, ttl, tags)
+	} else {
+		state.cache.SetTTL(ctx, cacheKey, cacheVals, ttl)
+	}
+}
+
+// handleSlidingTTL saves the cached values again with the current time when the SlidingTTL
+// option is set, so that the entry expires a full TTL after it was last read. This is skipped
+// if the entry is due to be refreshed, since the refresh saves it anyway.
+func handleSlidingTTL(ctx context.Context, cacheKey string, state *cacheState, cachedValues []any, savedTime time.Time, ttl time.Duration) {
+	if !state.opts.SlidingTTL || ttl <= 0 {
+		return
+	}
+	if state.opts.RefreshPercentage > 0 && shouldPreRefresh(state, ttl, savedTime) {
+		return
+	}
+	cacheVals := copyCacheValues(cachedValues)
+	cacheVals[len(cacheVals)-2] = state.opts.now()
+	storeCacheValues(ctx, cacheKey, state, cacheVals, ttl)
+}
+
+// InvalidateTag removes all the entries from the cache that were indexed under the tag by
+// the CacheTags option. If the cache is not a TaggableCache, this returns an error.
+func InvalidateTag(ctx context.Context, cache Cache, tag string) error {
+	tc, ok := cache.(TaggableCache)
+	if !ok {
+		return fmt.Errorf("cache of type %T does not support tags", cache)
+	}
+	tc.DeleteByTag(ctx, tag)
+	return nil
+}
+
+// CachedFunc is the same as Cached, but returns the cached function as the same type as the
+// generator function. This is useful for calling the cached function directly, such as in
+// tests, rather than adding it to a dependency context.
+//
+// If the generator function doesn't take a context.Context, the function returned by Cached
+// has one added to it. Since that doesn't match the type of the generator, the function that
+// is returned here calls it with context.Background().
+func CachedFunc[F any](cache Cache, generator F, ttl time.Duration) F {
+	cached := Cached(cache, generator, ttl)
+	if f, ok := cached.(F); ok {
+		return f
+	}
+	cachedVal := reflect.ValueOf(cached)
+	return reflect.MakeFunc(reflect.TypeOf(generator), func(args []reflect.Value) []reflect.Value {
+		callArgs := make([]reflect.Value, len(args), len(args)+1)
+		copy(callArgs, args)
+		callArgs = append(callArgs, reflect.ValueOf(context.Background()))
+		return cachedVal.Call(callArgs)
+	}).Interface().(F)
+}
+
+type cacheBypass int
+
+const cacheBypassKey cacheBypass = 0
+
+// WithCacheBypass returns a context that makes cached generators ignore what is in the cache
+// for any request made with it. The generator function is always called, and its results are
+// saved in the cache as usual, replacing what was there. This is for requests that need fresh
+// values, such as a forced refresh, without clearing the whole cache:
+//
+//	ctx = ctxdep.WithCacheBypass(ctx)
+//	user := ctxdep.Get[*User](ctx)
+//
+// The bypass applies whether ctx is used to make the request or to create the dependency
+// context. A value that is already in the dependency context is still returned, since the
+// generator isn't run again for it.
+func WithCacheBypass(ctx context.Context) context.Context {
+	return context.WithValue(ctx, cacheBypassKey, true)
+}
+
+// isCacheBypassed returns if WithCacheBypass was used on the context.
+func isCacheBypassed(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	bypass, _ := ctx.Value(cacheBypassKey).(bool)
+	return bypass
+}
+
+// cacheNamespace returns the namespace for cache keys that was set with WithCacheNamespace
+// on the dependency context that the cached generator is called from, or any of its parents.
+// If there isn't one, this returns an empty string.
+func cacheNamespace(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	dc, _ := ctx.Value(dependencyContextKey).(*DependencyContext)
+	for dc != nil {
+		if dc.options.cacheNamespace != "" {
+			return dc.options.cacheNamespace
+		}
+		dc = dc.parentDependencyContext()
+	}
+	return ""
+}
+
+// jitterTTL randomizes the TTL by up to the TTLJitter fraction of the options.
+func jitterTTL(opts CtxCacheOptions, ttl time.Duration) time.Duration {
+	if opts.TTLJitter <= 0 || ttl <= 0 {
+		return ttl
+	}
+	// Scale the random number to be in [-1.0,1.0) so the TTL can move in either direction.
+	offset := time.Duration(float64(ttl) * opts.TTLJitter * (opts.random()*2 - 1))
+	jittered := ttl + offset
+	if jittered <= 0 {
+		// A TTL of 0 means the entry isn't cached, so don't jitter it that far.
+		return ttl
+	}
+	return jittered
+}
+
 // generatorReturnTypesKey returns a string that represents the return
 // types of the generator function. This is used to generate a unique
 // signature for the given generator function.
@@ -574,7 +907,7 @@ func generatorParamKeys(args []reflect.Value) (string, error) {
 		} else if stringer, ok := val.(fmt.Stringer); ok {
 			builder.WriteString(stringer.String())
 		} else {
-			valJson, err := json.Marshal(val)
+			valJson, err := cacheKeyMarshaler(keyFieldValues(arg))
 			if err != nil {
 				return "", err
 			}
@@ -588,6 +921,28 @@ func generatorParamKeys(args []reflect.Value) (string, error) {
 	return builder.String(), nil
 }
 
+// keyFieldValues returns the value that is serialized for the cache key of the parameter.
+// If the parameter is a struct, or a pointer to one, that has fields tagged with
+// `ctxdep:"key"`, only those fields are used. Otherwise, the whole parameter is used.
+func keyFieldValues(arg reflect.Value) any {
+	structVal := arg
+	if structVal.Kind() == reflect.Pointer {
+		if structVal.IsNil() {
+			return arg.Interface()
+		}
+		structVal = structVal.Elem()
+	}
+	fields := keyFields(structVal.Type())
+	if len(fields) == 0 {
+		return arg.Interface()
+	}
+	values := make(map[string]any, len(fields))
+	for _, i := range fields {
+		values[structVal.Type().Field(i).Name] = structVal.Field(i).Interface()
+	}
+	return values
+}
+
 // DefaultDurationProvider is a CacheDurationProvider that returns the
 // minimum TTL of the given results that implement the CacheTTL interface.
 // If none of the results implement the CacheTTL interface, the default
@@ -611,11 +966,20 @@ func DefaultDurationProvider(opts CtxCacheOptions, rets []any) time.Duration {
 // Fields:
 // - mu: A sync.Mutex used to ensure that the operations on the keys map are thread-safe.
 // - keys: A map where the key is a string representing the lock key, and the value is a pointer to an internalLockWait structure.
+// - maxWait: The longest time to wait for a lock, independent of the context. If this is 0, the wait is only bounded by the context.
+// - maxRetries: The most times to wait for a lock. If this is 0, the number of retries is not limited.
 type internalLock struct {
-	mu   sync.Mutex
-	keys map[string]*internalLockWait
+	mu         sync.Mutex
+	keys       map[string]*internalLockWait
+	maxWait    time.Duration
+	maxRetries int
 }
 
+// ErrLockWaitExceeded is returned when waiting for the lock of a cache key took longer, or
+// took more retries, than is allowed by the LockWaitTimeout or LockMaxRetries of the
+// CtxCacheOptions.
+var ErrLockWaitExceeded = errors.New("lock wait exceeded")
+
 // lock attempts to acquire a lock for the given key in a thread-safe manner.
 // If the key is already locked, it waits until the key is unlocked and retries.
 //
@@ -625,21 +989,38 @@ type internalLock struct {
 //
 // Returns:
 // - A function that unlocks the key when called.
-// - An error if the context is canceled or times out while waiting for the lock.
+// - An error if the context is canceled or times out while waiting for the lock, or
+// ErrLockWaitExceeded if the maxWait or maxRetries of the lock is exceeded.
 func (il *internalLock) lock(ctx context.Context, key string) (func(), error) {
+	waitCtx := ctx
+	if il.maxWait > 0 {
+		var cancel context.CancelFunc
+		waitCtx, cancel = context.WithTimeout(ctx, il.maxWait)
+		defer cancel()
+	}
+	retries := 0
 	for {
 		il.mu.Lock()
 		if il.keys == nil {
 			il.keys = make(map[string]*internalLockWait)
 		}
-		if _, ok := il.keys[key]; ok {
+		if existing, ok := il.keys[key]; ok {
+			if il.maxRetries > 0 && retries >= il.maxRetries {
+				il.mu.Unlock()
+				return nil, ErrLockWaitExceeded
+			}
 			// Already locked, add a wait.
 			il.mu.Unlock()
-			err := il.keys[key].wait(ctx)
+			err := existing.wait(waitCtx)
 			if err != nil {
+				if ctx.Err() == nil {
+					// Only the wait for the lock timed out, not the context.
+					return nil, ErrLockWaitExceeded
+				}
 				return nil, err
 			}
 			// Retry after waiting
+			retries++
 			continue
 		}
 		il.keys[key] = &internalLockWait{
@@ -0,0 +1,71 @@
+package ctxdep
+
+import (
+	"fmt"
+	"time"
+)
+
+// SnapshotableCache is a Cache whose entries can be exported and imported in bulk, such as to
+// persist a cache across restarts of a service. This is required to use CacheSnapshot and
+// RestoreCacheSnapshot.
+type SnapshotableCache interface {
+	Cache
+
+	// Export returns all the entries of the cache, keyed by their cache keys. The values
+	// are exactly as they were passed to SetTTL.
+	Export() map[string][]any
+
+	// Import adds all the entries to the cache, replacing any existing entries with the
+	// same keys.
+	Import(entries map[string][]any)
+}
+
+// CacheSnapshot returns all the entries of the cache so that they can be restored later with
+// RestoreCacheSnapshot. The values of entries that were saved by CachedOpts are the results
+// of the generator followed by the time they were saved and their TTL, which is what
+// RestoreCacheSnapshot uses to drop the entries that have expired. Any tooling that persists
+// the snapshot must keep those values intact. If the cache is not a SnapshotableCache, this
+// returns an error.
+func CacheSnapshot(cache Cache) (map[string][]any, error) {
+	sc, ok := cache.(SnapshotableCache)
+	if !ok {
+		return nil, fmt.Errorf("cache of type %T does not support snapshots", cache)
+	}
+	return sc.Export(), nil
+}
+
+// RestoreCacheSnapshot adds the entries of a snapshot from CacheSnapshot to the cache, such
+// as to warm the cache when a service starts. Entries whose saved time and TTL show that they
+// have expired are left out. Entries without a saved time and TTL can't be checked and are
+// always restored, so a snapshot that is restored long after it was taken may bring back
+// values that should no longer be used. Even entries that haven't expired reflect the
+// state from when the snapshot was taken, not when it's restored. If the cache is not a
+// SnapshotableCache, this returns an error.
+func RestoreCacheSnapshot(cache Cache, snapshot map[string][]any) error {
+	sc, ok := cache.(SnapshotableCache)
+	if !ok {
+		return fmt.Errorf("cache of type %T does not support snapshots", cache)
+	}
+	now := time.Now()
+	entries := make(map[string][]any, len(snapshot))
+	for key, value := range snapshot {
+		if savedTime, ttl, ok := cacheValueExpiry(value); ok && ttl > 0 && now.After(savedTime.Add(ttl)) {
+			continue
+		}
+		entries[key] = value
+	}
+	sc.Import(entries)
+	return nil
+}
+
+// cacheValueExpiry returns the saved time and TTL that CachedOpts stores at the end of the
+// cached values. If the values don't end with them, ok is false.
+func cacheValueExpiry(value []any) (savedTime time.Time, ttl time.Duration, ok bool) {
+	n := len(value)
+	if n < 2 {
+		return time.Time{}, 0, false
+	}
+	savedTime, timeOk := value[n-2].(time.Time)
+	ttl, ttlOk := value[n-1].(time.Duration)
+	return savedTime, ttl, timeOk && ttlOk
+}
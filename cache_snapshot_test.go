@@ -0,0 +1,48 @@
+package ctxdep
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func Test_CacheSnapshot(t *testing.T) {
+	cache := NewMemoryCache(10)
+
+	callCount := 0
+	generator := func(ctx context.Context, key *inputValue) (*outputValue, error) {
+		callCount++
+		return &outputValue{Value: key.Value}, nil
+	}
+
+	input := &inputValue{Value: "1"}
+	ctx := NewDependencyContext(context.Background(), input, Cached(cache, generator, time.Minute))
+	Get[*outputValue](ctx)
+
+	snapshot, err := CacheSnapshot(cache)
+	assert.NoError(t, err)
+	assert.Len(t, snapshot, 1)
+
+	// Entries that have expired are not restored.
+	snapshot["expired"] = []any{&outputValue{}, time.Now().Add(-time.Hour), time.Minute}
+
+	restored := NewMemoryCache(10)
+	err = RestoreCacheSnapshot(restored, snapshot)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, restored.Len())
+
+	ctx = NewDependencyContext(context.Background(), input, Cached(restored, generator, time.Minute))
+	assert.Equal(t, "1", Get[*outputValue](ctx).Value)
+	assert.Equal(t, 1, callCount)
+}
+
+func Test_CacheSnapshot_NotSnapshotable(t *testing.T) {
+	cache := &DumbCache{values: make(map[string][]any)}
+
+	_, err := CacheSnapshot(cache)
+	assert.EqualError(t, err, "cache of type *ctxdep.DumbCache does not support snapshots")
+
+	err = RestoreCacheSnapshot(cache, map[string][]any{})
+	assert.EqualError(t, err, "cache of type *ctxdep.DumbCache does not support snapshots")
+}
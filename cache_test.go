@@ -2,10 +2,15 @@ package ctxdep
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"github.com/stretchr/testify/assert"
+	"log"
+	"math/rand"
 	"reflect"
 	"strconv"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -196,6 +201,428 @@ func Test_Cache_Nil(t *testing.T) {
 	assert.Equal(t, 2, callCount)
 }
 
+func Test_Cache_CacheNilResults(t *testing.T) {
+	cache := DumbCache{
+		values: make(map[string][]any),
+	}
+
+	callCount := 0
+	generator := func(ctx context.Context, key *inputValue) (*outputValue, *testWidget, error) {
+		callCount++
+		return &outputValue{Value: key.Value}, nil, nil
+	}
+
+	cached := CachedOpts(&cache, generator, CtxCacheOptions{
+		TTL:             time.Minute,
+		CacheNilResults: true,
+	}).(func(context.Context, *inputValue) (*outputValue, *testWidget, error))
+
+	for i := 0; i < 2; i++ {
+		ov, widget, err := cached(context.Background(), &inputValue{Value: "1"})
+		assert.NoError(t, err)
+		assert.Equal(t, "1", ov.Value)
+		assert.Nil(t, widget)
+	}
+	assert.Equal(t, 1, callCount)
+
+	// A cache may not keep the type of a nil value.
+	cache.values["2//outputValue:testWidget"] = []any{&outputValue{Value: "2"}, nil, time.Now(), time.Minute}
+	ov, widget, err := cached(context.Background(), &inputValue{Value: "2"})
+	assert.NoError(t, err)
+	assert.Equal(t, "2", ov.Value)
+	assert.Nil(t, widget)
+	assert.Equal(t, 1, callCount)
+}
+
+func Test_Cache_NegativeCache(t *testing.T) {
+	cache := NewMemoryCache(0)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	callCount := 0
+	lookup := func(ctx context.Context, key *inputValue) (*outputValue, error) {
+		callCount++
+		if key.Value == "missing" {
+			return nil, nil
+		}
+		return &outputValue{Value: key.Value}, nil
+	}
+	opts := CtxCacheOptions{
+		TTL:           time.Hour,
+		NegativeCache: true,
+		NegativeTTL:   time.Minute,
+		now:           func() time.Time { return now },
+	}
+	cached := CachedOpts(cache, lookup, opts).(func(context.Context, *inputValue) (*outputValue, error))
+
+	for i := 0; i < 2; i++ {
+		ov, err := cached(context.Background(), &inputValue{Value: "missing"})
+		assert.NoError(t, err)
+		assert.Nil(t, ov)
+	}
+	assert.Equal(t, 1, callCount)
+
+	// The miss expires after the NegativeTTL, well before the TTL.
+	now = now.Add(2 * time.Minute)
+	_, _ = cached(context.Background(), &inputValue{Value: "missing"})
+	assert.Equal(t, 2, callCount)
+
+	// Found values are still cached for the TTL.
+	for i := 0; i < 2; i++ {
+		ov, err := cached(context.Background(), &inputValue{Value: "found"})
+		assert.NoError(t, err)
+		assert.Equal(t, "found", ov.Value)
+	}
+	now = now.Add(2 * time.Minute)
+	_, _ = cached(context.Background(), &inputValue{Value: "found"})
+	assert.Equal(t, 3, callCount)
+
+	// Without a NegativeTTL, misses aren't cached.
+	opts.NegativeTTL = 0
+	uncached := CachedOpts(NewMemoryCache(0), lookup, opts).(func(context.Context, *inputValue) (*outputValue, error))
+	_, _ = uncached(context.Background(), &inputValue{Value: "missing"})
+	_, _ = uncached(context.Background(), &inputValue{Value: "missing"})
+	assert.Equal(t, 5, callCount)
+}
+
+type cacheTenantKey struct{}
+
+func Test_Cache_ContextKeyFunc(t *testing.T) {
+	cache := DumbCache{
+		values: make(map[string][]any),
+	}
+
+	callCount := 0
+	generator := func(ctx context.Context, key *inputValue) (*outputValue, error) {
+		callCount++
+		return &outputValue{Value: key.Value}, nil
+	}
+	cached := CachedOpts(&cache, generator, CtxCacheOptions{
+		TTL: time.Minute,
+		ContextKeyFunc: func(ctx context.Context) (string, error) {
+			tenant, ok := ctx.Value(cacheTenantKey{}).(string)
+			if !ok {
+				return "", fmt.Errorf("no tenant")
+			}
+			return tenant, nil
+		},
+	})
+
+	for _, tenant := range []string{"a", "b", "a"} {
+		base := context.WithValue(context.Background(), cacheTenantKey{}, tenant)
+		ctx := NewDependencyContext(base, &inputValue{Value: "1"}, cached)
+		assert.Equal(t, "1", Get[*outputValue](ctx).Value)
+	}
+	assert.Equal(t, 2, callCount)
+	assert.Contains(t, cache.values, "1//a//outputValue")
+	assert.Contains(t, cache.values, "1//b//outputValue")
+
+	logger := &testLogger{}
+	SetLogger(logger)
+	defer SetLogger(log.Default())
+
+	// Without a tenant, the generator is called without caching.
+	for i := 0; i < 2; i++ {
+		ctx := NewDependencyContext(context.Background(), &inputValue{Value: "1"}, cached)
+		assert.Equal(t, "1", Get[*outputValue](ctx).Value)
+	}
+	assert.Equal(t, 4, callCount)
+	assert.Len(t, cache.values, 2)
+	assert.Equal(t, []string{
+		"ERROR: Failed to generate context cache key: no tenant\n",
+		"ERROR: Failed to generate context cache key: no tenant\n",
+	}, logger.getMessages())
+}
+
+func Test_Cache_Bypass(t *testing.T) {
+	cache := DumbCache{
+		values: make(map[string][]any),
+	}
+
+	callCount := 0
+	generator := func(ctx context.Context, key *inputValue) (*outputValue, error) {
+		callCount++
+		return &outputValue{Value: key.Value + ":" + strconv.Itoa(callCount)}, nil
+	}
+	cached := Cached(&cache, generator, time.Minute)
+
+	ctx := NewDependencyContext(context.Background(), &inputValue{Value: "1"}, cached)
+	assert.Equal(t, "1:1", Get[*outputValue](ctx).Value)
+
+	// The request ignores the cache, but saves what it gets.
+	ctx = NewDependencyContext(context.Background(), &inputValue{Value: "1"}, cached)
+	assert.Equal(t, "1:2", Get[*outputValue](WithCacheBypass(ctx)).Value)
+	assert.Equal(t, "1:2", cache.values["1//outputValue"][0].(*outputValue).Value)
+
+	ctx = NewDependencyContext(context.Background(), &inputValue{Value: "1"}, cached)
+	assert.Equal(t, "1:2", Get[*outputValue](ctx).Value)
+
+	// The bypass also works from the parent of the dependency context.
+	ctx = NewDependencyContext(WithCacheBypass(context.Background()), &inputValue{Value: "1"}, cached)
+	assert.Equal(t, "1:3", Get[*outputValue](ctx).Value)
+	assert.Equal(t, 3, callCount)
+}
+
+func Test_Cache_Namespace(t *testing.T) {
+	cache := DumbCache{
+		values: make(map[string][]any),
+	}
+
+	callCount := 0
+	generator := func(ctx context.Context, key *inputValue) (*outputValue, error) {
+		callCount++
+		return &outputValue{Value: key.Value}, nil
+	}
+	cached := Cached(&cache, generator, time.Minute)
+
+	tenantA := NewDependencyContext(context.Background(), WithCacheNamespace("a"))
+	tenantB := NewDependencyContext(context.Background(), WithCacheNamespace("b"))
+
+	ctx := NewDependencyContext(tenantA, &inputValue{Value: "1"}, cached)
+	_ = Get[*outputValue](ctx)
+	ctx = NewDependencyContext(tenantB, &inputValue{Value: "1"}, cached)
+	_ = Get[*outputValue](ctx)
+	assert.Equal(t, 2, callCount)
+	assert.Contains(t, cache.values, "a//1//outputValue")
+	assert.Contains(t, cache.values, "b//1//outputValue")
+
+	// The namespace is set directly on the dependency context.
+	ctx = NewDependencyContext(context.Background(), WithCacheNamespace("c"), &inputValue{Value: "2"}, cached)
+	_ = Get[*outputValue](ctx)
+	assert.Contains(t, cache.values, "c//2//outputValue")
+}
+
+func Test_CachedFunc(t *testing.T) {
+	cache := DumbCache{
+		values: make(map[string][]any),
+	}
+
+	callCount := 0
+	cached := CachedFunc(&cache, func(ctx context.Context, key *inputValue) (*outputValue, error) {
+		callCount++
+		return &outputValue{Value: key.Value}, nil
+	}, time.Minute)
+
+	for i := 0; i < 2; i++ {
+		ov, err := cached(context.Background(), &inputValue{Value: "1"})
+		assert.NoError(t, err)
+		assert.Equal(t, "1", ov.Value)
+	}
+	assert.Equal(t, 1, callCount)
+}
+
+func Test_CachedFunc_NoContext(t *testing.T) {
+	cache := DumbCache{
+		values: make(map[string][]any),
+	}
+
+	callCount := 0
+	cached := CachedFunc(&cache, func(key *inputValue) (*outputValue, error) {
+		callCount++
+		return &outputValue{Value: key.Value}, nil
+	}, time.Minute)
+
+	for i := 0; i < 2; i++ {
+		ov, err := cached(&inputValue{Value: "1"})
+		assert.NoError(t, err)
+		assert.Equal(t, "1", ov.Value)
+	}
+	assert.Equal(t, 1, callCount)
+	assert.Contains(t, cache.values, "1//outputValue")
+
+	// It can still be used as a generator.
+	ctx := NewDependencyContext(context.Background(), &inputValue{Value: "1"}, cached)
+	assert.Equal(t, "1", Get[*outputValue](ctx).Value)
+	assert.Equal(t, 1, callCount)
+}
+
+func Test_Cache_KeyTransformer(t *testing.T) {
+	cache := DumbCache{
+		values: make(map[string][]any),
+	}
+
+	callCount := 0
+	generator := func(ctx context.Context, key *inputValue) (*outputValue, error) {
+		callCount++
+		return &outputValue{Value: key.Value}, nil
+	}
+
+	var rawKeys []string
+	opts := CtxCacheOptions{
+		TTL: time.Minute,
+		KeyTransformer: func(rawKey string) string {
+			rawKeys = append(rawKeys, rawKey)
+			sum := sha256.Sum256([]byte(rawKey))
+			return hex.EncodeToString(sum[:])
+		},
+	}
+
+	for i := 0; i < 2; i++ {
+		ctx := NewDependencyContext(context.Background(), WithCacheNamespace("ns"), &inputValue{Value: "secret"}, CachedOpts(&cache, generator, opts))
+		assert.Equal(t, "secret", Get[*outputValue](ctx).Value)
+	}
+	assert.Equal(t, 1, callCount)
+	assert.Equal(t, []string{"ns//secret//outputValue", "ns//secret//outputValue"}, rawKeys)
+	sum := sha256.Sum256([]byte("ns//secret//outputValue"))
+	assert.Contains(t, cache.values, hex.EncodeToString(sum[:]))
+}
+
+func Test_Cache_ShouldCache(t *testing.T) {
+	cache := DumbCache{
+		values: make(map[string][]any),
+	}
+
+	callCount := 0
+	generator := func(ctx context.Context, key *inputValue) (*outputValue, error) {
+		callCount++
+		return &outputValue{Value: key.Value}, nil
+	}
+
+	var predicateResults []any
+	opts := CtxCacheOptions{
+		TTL: time.Minute,
+		ShouldCache: func(results []any) bool {
+			predicateResults = results
+			return results[0].(*outputValue).Value != ""
+		},
+	}
+
+	empty := &inputValue{Value: ""}
+	for i := 0; i < 2; i++ {
+		ctx := NewDependencyContext(context.Background(), empty, CachedOpts(&cache, generator, opts))
+		assert.Equal(t, "", Get[*outputValue](ctx).Value)
+	}
+	assert.Len(t, cache.values, 0)
+	assert.Equal(t, 2, callCount)
+	assert.Len(t, predicateResults, 1)
+
+	input := &inputValue{Value: "1"}
+	for i := 0; i < 2; i++ {
+		ctx := NewDependencyContext(context.Background(), input, CachedOpts(&cache, generator, opts))
+		assert.Equal(t, "1", Get[*outputValue](ctx).Value)
+	}
+	assert.Contains(t, cache.values, "1//outputValue")
+	assert.Equal(t, 3, callCount)
+}
+
+func Test_Cache_TTLJitter(t *testing.T) {
+	cache := DumbCache{
+		values: make(map[string][]any),
+	}
+
+	generator := func(ctx context.Context, key *inputValue) (*outputValue, error) {
+		return &outputValue{Value: key.Value}, nil
+	}
+
+	opts := CtxCacheOptions{
+		TTL:       time.Minute,
+		TTLJitter: 0.1,
+		random:    func() float64 { return 0.75 },
+	}
+
+	ctx := NewDependencyContext(context.Background(), &inputValue{Value: "1"}, CachedOpts(&cache, generator, opts))
+	assert.Equal(t, "1", Get[*outputValue](ctx).Value)
+
+	// 0.75 is scaled to 0.5, so the TTL is 5% longer.
+	assert.Equal(t, 63*time.Second, cache.lastTtl)
+	cachedVals := cache.values["1//outputValue"]
+	assert.Equal(t, 63*time.Second, cachedVals[len(cachedVals)-1])
+}
+
+func Test_jitterTTL(t *testing.T) {
+	opts := CtxCacheOptions{
+		TTLJitter: 0.1,
+		random:    func() float64 { return 0 },
+	}
+	assert.Equal(t, 90*time.Second, jitterTTL(opts, 100*time.Second))
+	assert.Equal(t, time.Duration(0), jitterTTL(opts, 0))
+
+	opts.TTLJitter = 0
+	assert.Equal(t, 100*time.Second, jitterTTL(opts, 100*time.Second))
+
+	// Jitter that would drop the TTL to 0 is ignored.
+	opts.TTLJitter = 2
+	assert.Equal(t, 100*time.Second, jitterTTL(opts, 100*time.Second))
+
+	opts.TTLJitter = 0.1
+	opts.random = rand.Float64
+	for i := 0; i < 100; i++ {
+		ttl := jitterTTL(opts, 100*time.Second)
+		assert.GreaterOrEqual(t, ttl, 90*time.Second)
+		assert.Less(t, ttl, 110*time.Second)
+	}
+}
+
+func Test_Cache_LockWaitTimeout(t *testing.T) {
+	cache := NewMemoryCache(10)
+
+	var callCount int32
+	generator := func(ctx context.Context, key *inputValue) (*outputValue, error) {
+		atomic.AddInt32(&callCount, 1)
+		time.Sleep(200 * time.Millisecond)
+		return &outputValue{Value: key.Value}, nil
+	}
+
+	cachedFunc := CachedOpts(cache, generator, CtxCacheOptions{
+		TTL:             time.Minute,
+		LockWaitTimeout: 20 * time.Millisecond,
+	}).(func(context.Context, *inputValue) (*outputValue, error))
+
+	input := &inputValue{Value: "1"}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = cachedFunc(context.Background(), input)
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	// The second call doesn't wait for the first to complete.
+	start := time.Now()
+	result, err := cachedFunc(context.Background(), input)
+	assert.NoError(t, err)
+	assert.Equal(t, "1", result.Value)
+	assert.Less(t, time.Since(start), 350*time.Millisecond)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&callCount))
+	<-done
+}
+
+func Test_Cache_LockMaxRetries(t *testing.T) {
+	state := makeStateForGenerator(NewMemoryCache(10), func(key *inputValue) (*outputValue, error) {
+		return &outputValue{Value: key.Value}, nil
+	}, CtxCacheOptions{TTL: time.Minute, LockWaitTimeout: time.Second, LockMaxRetries: 3})
+
+	assert.Equal(t, time.Second, state.internalLock.maxWait)
+	assert.Equal(t, 3, state.internalLock.maxRetries)
+}
+
+func Test_Cache_LockWait_NoTimeout(t *testing.T) {
+	cache := NewMemoryCache(10)
+
+	var callCount int32
+	generator := func(ctx context.Context, key *inputValue) (*outputValue, error) {
+		atomic.AddInt32(&callCount, 1)
+		time.Sleep(50 * time.Millisecond)
+		return &outputValue{Value: key.Value}, nil
+	}
+
+	cachedFunc := Cached(cache, generator, time.Minute).(func(context.Context, *inputValue) (*outputValue, error))
+
+	input := &inputValue{Value: "1"}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = cachedFunc(context.Background(), input)
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	// Without a timeout, the second call waits for the first and uses its result.
+	result, err := cachedFunc(context.Background(), input)
+	assert.NoError(t, err)
+	assert.Equal(t, "1", result.Value)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&callCount))
+	<-done
+}
+
 func Test_Cache_NonFunction(t *testing.T) {
 	cache := DumbCache{
 		values: make(map[string][]any),
@@ -223,6 +650,54 @@ func Test_Cache_NonKeyed_JSON(t *testing.T) {
 	assert.Equal(t, "42", ov.Value)
 }
 
+func Test_Cache_NonKeyed_TaggedFields(t *testing.T) {
+	cache := DumbCache{
+		values: make(map[string][]any),
+	}
+
+	type request struct {
+		UserID    string `ctxdep:"key"`
+		Region    string `json:"region" ctxdep:"key"`
+		RequestID string
+	}
+
+	calls := 0
+	generator := func(ctx context.Context, r *request) (*outputValue, error) {
+		calls++
+		return &outputValue{Value: r.UserID}, nil
+	}
+
+	ctx := NewDependencyContext(context.Background(), &request{UserID: "user", Region: "us", RequestID: "1"}, Cached(&cache, generator, time.Minute))
+	ov := Get[*outputValue](ctx)
+	assert.Contains(t, cache.values, "{\"Region\":\"us\",\"UserID\":\"user\"}//outputValue")
+	assert.Equal(t, "user", ov.Value)
+
+	// A different request ID uses the same cache entry.
+	ctx = NewDependencyContext(context.Background(), &request{UserID: "user", Region: "us", RequestID: "2"}, Cached(&cache, generator, time.Minute))
+	ov = Get[*outputValue](ctx)
+	assert.Equal(t, "user", ov.Value)
+	assert.Equal(t, 1, calls)
+	assert.Len(t, cache.values, 1)
+}
+
+func Test_keyFields(t *testing.T) {
+	type tagged struct {
+		A int `ctxdep:"key"`
+		B int
+		c int `ctxdep:"key"`
+		D int `ctxdep:"other,key"`
+	}
+
+	assert.Equal(t, []int{0, 3}, keyFields(reflect.TypeOf(tagged{})))
+	assert.Nil(t, keyFields(reflect.TypeOf(testWidget{})))
+	assert.Nil(t, keyFields(reflect.TypeOf(42)))
+
+	// The result is cached.
+	cached, ok := keyFieldCache.Load(reflect.TypeOf(tagged{}))
+	assert.True(t, ok)
+	assert.Equal(t, []int{0, 3}, cached)
+}
+
 func Test_Cache_NonKeyed_EmptyJSON(t *testing.T) {
 	cache := DumbCache{
 		values: make(map[string][]any),
@@ -437,6 +912,59 @@ func Test_Lock_ContextCancelled(t *testing.T) {
 	unlock1()
 }
 
+func Test_Lock_MaxWait(t *testing.T) {
+	il := &internalLock{maxWait: 20 * time.Millisecond}
+	ctx := context.Background()
+	key := "testKey"
+
+	unlock, err := il.lock(ctx, key)
+	assert.NoError(t, err)
+	defer unlock()
+
+	start := time.Now()
+	_, err = il.lock(ctx, key)
+	assert.Equal(t, ErrLockWaitExceeded, err)
+	assert.Less(t, time.Since(start), 100*time.Millisecond)
+
+	// If the context is what's done, that is the error that is returned.
+	cancelCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = il.lock(cancelCtx, key)
+	assert.Equal(t, context.Canceled, err)
+}
+
+func Test_Lock_MaxRetries(t *testing.T) {
+	il := &internalLock{maxRetries: 1}
+	ctx := context.Background()
+	key := "testKey"
+
+	unlock, err := il.lock(ctx, key)
+	assert.NoError(t, err)
+	defer unlock()
+
+	result := make(chan error)
+	go func() {
+		_, err := il.lock(ctx, key)
+		result <- err
+	}()
+	// Wake up the waiter without releasing the key, as if another caller got the lock first.
+	for {
+		il.mu.Lock()
+		w := il.keys[key]
+		il.mu.Unlock()
+		w.mu.Lock()
+		waiting := len(w.strobe) > 0
+		w.mu.Unlock()
+		if waiting {
+			w.release()
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	assert.Equal(t, ErrLockWaitExceeded, <-result)
+}
+
 func Test_Lock_NoKeys(t *testing.T) {
 	il := &internalLock{}
 	ctx := context.Background()
@@ -563,9 +1091,9 @@ func Test_handlePreRefresh_HappyCase(t *testing.T) {
 	cache := DumbCache{
 		values: make(map[string][]any),
 	}
-	calls := 0
+	var calls int32
 	f := func(s string) *string {
-		calls++
+		atomic.AddInt32(&calls, 1)
 		return &s
 	}
 	options := CtxCacheOptions{
@@ -584,7 +1112,51 @@ func Test_handlePreRefresh_HappyCase(t *testing.T) {
 
 	handlePreRefresh(ctx, cacheKey, state, args, savedTime, ttl)
 	time.Sleep(time.Millisecond * 10)
-	assert.Equal(t, 1, calls)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func Test_handlePreRefresh_RefreshContextProvider(t *testing.T) {
+	type ctxKey string
+	callerCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+	cacheKey := "testKey"
+	now := time.Now()
+	cache := DumbCache{
+		values: make(map[string][]any),
+	}
+	done := make(chan error, 1)
+	f := func(ctx context.Context, s string) *string {
+		assert.Equal(t, "refresh", ctx.Value(ctxKey("name")))
+		done <- ctx.Err()
+		return &s
+	}
+	options := CtxCacheOptions{
+		RefreshPercentage: 0.5,
+		DurationProvider:  DefaultDurationProvider,
+		RefreshContextProvider: func(parent context.Context) context.Context {
+			assert.Equal(t, callerCtx, parent)
+			return context.WithValue(context.Background(), ctxKey("name"), "refresh")
+		},
+		now: func() time.Time { return now },
+	}
+	state := makeStateForGenerator(&cache, f, options)
+
+	savedTime := now.Add(-time.Minute * 6)
+	ttl := time.Minute * 10
+
+	args := []reflect.Value{
+		reflect.ValueOf(&callerCtx).Elem(),
+		reflect.ValueOf("test"),
+	}
+
+	handlePreRefresh(callerCtx, cacheKey, state, args, savedTime, ttl)
+	select {
+	case err := <-done:
+		// The caller's context is canceled, but the refresh isn't.
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		assert.Fail(t, "refresh did not run")
+	}
 }
 
 func Test_handlePreRefresh_TooNew(t *testing.T) {
@@ -626,9 +1198,9 @@ func Test_handlePreRefresh_Panics(t *testing.T) {
 	cache := DumbCache{
 		values: make(map[string][]any),
 	}
-	calls := 0
+	var calls int32
 	f := func(s string) *string {
-		calls++
+		atomic.AddInt32(&calls, 1)
 		panic("test panic")
 	}
 	options := CtxCacheOptions{
@@ -648,5 +1220,160 @@ func Test_handlePreRefresh_Panics(t *testing.T) {
 	// The function panics, but the panic is caught and the function continues.
 	handlePreRefresh(ctx, cacheKey, state, args, savedTime, ttl)
 	time.Sleep(time.Millisecond * 10)
-	assert.Equal(t, 1, calls)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func Test_Cache_Tags(t *testing.T) {
+	cache := NewMemoryCache(10)
+
+	callCount := 0
+	generator := func(ctx context.Context, key *inputValue) (*outputValue, error) {
+		callCount++
+		return &outputValue{Value: key.Value}, nil
+	}
+	opts := CtxCacheOptions{
+		TTL: time.Minute,
+		CacheTags: func(results []any) []string {
+			return []string{"value:" + results[0].(*outputValue).Value}
+		},
+	}
+
+	ctx := context.Background()
+	input := &inputValue{Value: "1"}
+	ctx1 := NewDependencyContext(ctx, input, CachedOpts(cache, generator, opts))
+	assert.Equal(t, "1", Get[*outputValue](ctx1).Value)
+
+	ctx2 := NewDependencyContext(ctx, input, CachedOpts(cache, generator, opts))
+	assert.Equal(t, "1", Get[*outputValue](ctx2).Value)
+	assert.Equal(t, 1, callCount)
+
+	err := InvalidateTag(ctx, cache, "value:1")
+	assert.NoError(t, err)
+
+	ctx3 := NewDependencyContext(ctx, input, CachedOpts(cache, generator, opts))
+	assert.Equal(t, "1", Get[*outputValue](ctx3).Value)
+	assert.Equal(t, 2, callCount)
+}
+
+func Test_Cache_Tags_NotTaggable(t *testing.T) {
+	cache := &DumbCache{values: make(map[string][]any)}
+	generator := func(ctx context.Context, key *inputValue) (*outputValue, error) {
+		return &outputValue{Value: key.Value}, nil
+	}
+
+	assert.PanicsWithValue(t, "CacheTags requires a TaggableCache", func() {
+		CachedOpts(cache, generator, CtxCacheOptions{
+			TTL:       time.Minute,
+			CacheTags: func(results []any) []string { return nil },
+		})
+	})
+
+	err := InvalidateTag(context.Background(), cache, "tag")
+	assert.EqualError(t, err, "cache of type *ctxdep.DumbCache does not support tags")
+}
+
+type marshaledKey struct {
+	value string
+}
+
+func Test_Cache_KeyMarshaler(t *testing.T) {
+	cache := DumbCache{
+		values: make(map[string][]any),
+	}
+
+	generator := func(ctx context.Context, key *marshaledKey) (*outputValue, error) {
+		return &outputValue{Value: key.value}, nil
+	}
+
+	RegisterCacheKeyMarshaler(func(v any) ([]byte, error) {
+		return []byte("marshaled:" + v.(*marshaledKey).value), nil
+	})
+	defer RegisterCacheKeyMarshaler(nil)
+
+	ctx := NewDependencyContext(context.Background(), &marshaledKey{value: "a"}, Cached(&cache, generator, time.Minute))
+	assert.Equal(t, "a", Get[*outputValue](ctx).Value)
+	assert.Contains(t, cache.values, "marshaled:a//outputValue")
+
+	// Without the marshaler, the unexported field is not part of the key.
+	RegisterCacheKeyMarshaler(nil)
+	key, err := generatorParamKeys([]reflect.Value{reflect.ValueOf(&marshaledKey{value: "a"})})
+	assert.NoError(t, err)
+	assert.Equal(t, "marshaledKey", key)
+}
+
+type typedKeyParam struct {
+	id int
+}
+
+type typedKeyInterface interface {
+	typedKey() string
+}
+
+type typedKeyImpl struct {
+	key string
+}
+
+func (t *typedKeyImpl) typedKey() string {
+	return t.key
+}
+
+func Test_RegisterKeyProvider(t *testing.T) {
+	RegisterKeyProvider(func(p *typedKeyParam) ([]byte, error) {
+		return []byte(fmt.Sprintf("typed:%d", p.id)), nil
+	})
+	RegisterKeyProvider(func(i typedKeyInterface) ([]byte, error) {
+		return []byte("iface:" + i.typedKey()), nil
+	})
+
+	key, err := generatorParamKeys([]reflect.Value{reflect.ValueOf(&typedKeyParam{id: 7}), reflect.ValueOf(&typedKeyImpl{key: "k"})})
+	assert.NoError(t, err)
+	assert.Equal(t, "typed:7:iface:k", key)
+}
+
+func Test_Cache_SlidingTTL(t *testing.T) {
+	cache := DumbCache{
+		values: make(map[string][]any),
+	}
+	now := time.Now()
+	generator := func(ctx context.Context, key *inputValue) (*outputValue, error) {
+		return &outputValue{Value: key.Value}, nil
+	}
+	opts := CtxCacheOptions{
+		TTL:        time.Minute,
+		SlidingTTL: true,
+		now:        func() time.Time { return now },
+	}
+	input := &inputValue{Value: "1"}
+
+	ctx := NewDependencyContext(context.Background(), input, CachedOpts(&cache, generator, opts))
+	Get[*outputValue](ctx)
+	assert.Equal(t, now, cache.values["1//outputValue"][1])
+
+	// Reading the entry saves it again with the current time and the same TTL.
+	now = now.Add(30 * time.Second)
+	ctx = NewDependencyContext(context.Background(), input, CachedOpts(&cache, generator, opts))
+	Get[*outputValue](ctx)
+	assert.Equal(t, now, cache.values["1//outputValue"][1])
+	assert.Equal(t, time.Minute, cache.values["1//outputValue"][2])
+	assert.Equal(t, time.Minute, cache.lastTtl)
+}
+
+func Test_handleSlidingTTL_NearRefresh(t *testing.T) {
+	cache := DumbCache{
+		values: make(map[string][]any),
+	}
+	now := time.Now()
+	state := makeStateForGenerator(&cache, func(s string) *string { return &s }, CtxCacheOptions{
+		SlidingTTL:        true,
+		RefreshPercentage: 0.5,
+		now:               func() time.Time { return now },
+	})
+
+	savedTime := now.Add(-6 * time.Minute)
+	handleSlidingTTL(context.Background(), "key", state, []any{"value", savedTime, 10 * time.Minute}, savedTime, 10*time.Minute)
+	assert.Empty(t, cache.values)
+
+	savedTime = now.Add(-time.Minute)
+	handleSlidingTTL(context.Background(), "key", state, []any{"value", savedTime, 10 * time.Minute}, savedTime, 10*time.Minute)
+	assert.Equal(t, []any{"value", now, 10 * time.Minute}, cache.values["key"])
 }
@@ -0,0 +1,128 @@
+package ctxdep
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// circuitBreaker is an internal wrapper to signal to the DependencyContext that the generator
+// should stop being called after it fails repeatedly. This is created by CircuitBreaker().
+type circuitBreaker struct {
+	generator any
+	threshold int
+	cooldown  time.Duration
+
+	lock     sync.Mutex
+	failures int
+	openedAt time.Time
+	trial    bool
+
+	// now is used for testing purposes to override the current time.
+	now func() time.Time
+}
+
+// CircuitBreaker wraps a generator so that once it has returned an error threshold times in a
+// row, it is no longer called. Instead, requests for its results fail immediately with a
+// DependencyError until the cooldown has elapsed. After that, a single trial call is allowed;
+// if it succeeds the generator is called normally again, and if it fails the cooldown starts
+// over.
+//
+// The state is kept in the returned wrapper, so it is shared by every dependency context that
+// the wrapper is added to. This allows for the state to persist across requests. Errors
+// returned by the generator and panics in it count as failures, but a failure to resolve the
+// parameters of the generator doesn't.
+func CircuitBreaker(generator any, threshold int, cooldown time.Duration) *circuitBreaker {
+	genType := reflect.TypeOf(generator)
+	if genType == nil || genType.Kind() != reflect.Func {
+		panic("circuit breaker generator must be a function")
+	}
+	if threshold < 1 {
+		panic("circuit breaker threshold must be at least 1")
+	}
+	return &circuitBreaker{
+		generator: generator,
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+	}
+}
+
+// allow returns if the generator may be called.
+func (cb *circuitBreaker) allow() bool {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+	if cb.failures < cb.threshold {
+		return true
+	}
+	if cb.trial || cb.now().Sub(cb.openedAt) < cb.cooldown {
+		return false
+	}
+	// Let one call through to see if the generator has recovered.
+	cb.trial = true
+	return true
+}
+
+// record updates the state of the circuit breaker with the result of a call.
+func (cb *circuitBreaker) record(success bool) {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+	cb.trial = false
+	if success {
+		cb.failures = 0
+		return
+	}
+	cb.failures++
+	if cb.failures >= cb.threshold {
+		cb.openedAt = cb.now()
+	}
+}
+
+// addCircuitBreakerGenerator adds the generator to the dependency context and records the
+// circuit breaker on each of the resulting slots.
+func (d *DependencyContext) addCircuitBreakerGenerator(cb *circuitBreaker, immediate *immediateDependencies) {
+	slots := d.addGenerator(cb.generator, immediate)
+	for _, s := range slots {
+		s.breaker = cb
+	}
+}
+
+// invokeCircuitBreakerGenerator calls the slot's generator if its circuit breaker allows it.
+// The parameters the generator was called with are returned along with its results.
+func (d *DependencyContext) invokeCircuitBreakerGenerator(ctx context.Context, activeSlot *slot, requestedType reflect.Type) ([]reflect.Value, []reflect.Value, error) {
+	cb := activeSlot.breaker
+	if !cb.allow() {
+		return nil, nil, &DependencyError{
+			Message:        "circuit breaker open",
+			ReferencedType: activeSlot.slotType,
+			Status:         d.Status(),
+		}
+	}
+	returned := false
+	defer func() {
+		if !returned {
+			// The generator panicked, which counts as a failure. Recording it also ends any
+			// trial call so the breaker doesn't stay open forever.
+			cb.record(false)
+		}
+	}()
+	results, params, err := d.invokeGenerator(ctx, activeSlot.generator, activeSlot.slotType, requestedType, activeSlot.timeout)
+	returned = true
+	if err != nil {
+		var panicErr *PanicError
+		if errors.As(err, &panicErr) {
+			// A panic that was recovered with WithRecoverGenerators is a failure too.
+			cb.record(false)
+			return nil, nil, err
+		}
+		// The generator wasn't called, so this doesn't count either way.
+		cb.lock.Lock()
+		cb.trial = false
+		cb.lock.Unlock()
+		return nil, nil, err
+	}
+	cb.record(d.getGeneratorError(results) == nil)
+	return results, params, nil
+}
@@ -0,0 +1,131 @@
+package ctxdep
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func Test_CircuitBreaker(t *testing.T) {
+	now := time.Now()
+	calls := 0
+	failing := true
+	f := func() (*testWidget, error) {
+		calls++
+		if failing {
+			return nil, errors.New("expected error")
+		}
+		return &testWidget{Val: 42}, nil
+	}
+	cb := CircuitBreaker(f, 2, time.Minute)
+	cb.now = func() time.Time { return now }
+
+	get := func() error {
+		ctx := NewDependencyContext(context.Background(), cb)
+		_, err := GetWithError[*testWidget](ctx)
+		return err
+	}
+
+	assert.EqualError(t, get(), "error running generator: *ctxdep.testWidget (expected error)")
+	assert.EqualError(t, get(), "error running generator: *ctxdep.testWidget (expected error)")
+	assert.Equal(t, 2, calls)
+
+	// The breaker is open, so the generator isn't called.
+	assert.EqualError(t, get(), "circuit breaker open: *ctxdep.testWidget")
+	assert.Equal(t, 2, calls)
+
+	// After the cooldown, a trial call is let through. It fails, so the breaker opens again.
+	now = now.Add(time.Minute)
+	assert.EqualError(t, get(), "error running generator: *ctxdep.testWidget (expected error)")
+	assert.Equal(t, 3, calls)
+	assert.EqualError(t, get(), "circuit breaker open: *ctxdep.testWidget")
+	assert.Equal(t, 3, calls)
+
+	// A successful trial call closes the breaker.
+	now = now.Add(time.Minute)
+	failing = false
+	assert.NoError(t, get())
+	assert.NoError(t, get())
+	assert.Equal(t, 5, calls)
+}
+
+func Test_CircuitBreaker_Panic(t *testing.T) {
+	for _, recoverGenerators := range []bool{false, true} {
+		now := time.Now()
+		calls := 0
+		panicking := true
+		f := func() *testWidget {
+			calls++
+			if panicking {
+				panic("expected panic")
+			}
+			return &testWidget{Val: 42}
+		}
+		cb := CircuitBreaker(f, 1, time.Minute)
+		cb.now = func() time.Time { return now }
+
+		get := func() (err error) {
+			var deps []any
+			if recoverGenerators {
+				deps = append(deps, WithRecoverGenerators())
+			}
+			ctx := NewDependencyContext(context.Background(), append(deps, cb)...)
+			defer func() {
+				if r := recover(); r != nil {
+					err = fmt.Errorf("panic: %v", r)
+				}
+			}()
+			_, err = GetWithError[*testWidget](ctx)
+			return err
+		}
+
+		// A panic counts as a failure and opens the breaker.
+		assert.ErrorContains(t, get(), "expected panic")
+		assert.EqualError(t, get(), "circuit breaker open: *ctxdep.testWidget")
+		assert.Equal(t, 1, calls)
+
+		// A panicking trial call opens the breaker again instead of leaving it stuck.
+		now = now.Add(time.Minute)
+		assert.ErrorContains(t, get(), "expected panic")
+		assert.Equal(t, 2, calls)
+		assert.EqualError(t, get(), "circuit breaker open: *ctxdep.testWidget")
+
+		now = now.Add(time.Minute)
+		panicking = false
+		assert.NoError(t, get())
+		assert.Equal(t, 3, calls)
+	}
+}
+
+func Test_CircuitBreaker_ResetOnSuccess(t *testing.T) {
+	results := []error{errors.New("expected error"), nil, errors.New("expected error"), nil}
+	calls := 0
+	f := func() (*testWidget, error) {
+		err := results[calls]
+		calls++
+		if err != nil {
+			return nil, err
+		}
+		return &testWidget{}, nil
+	}
+	cb := CircuitBreaker(f, 2, time.Hour)
+
+	for range results {
+		ctx := NewDependencyContext(context.Background(), cb)
+		_, _ = GetWithError[*testWidget](ctx)
+	}
+	// The failures were never consecutive, so the breaker never opened.
+	assert.Equal(t, 4, calls)
+}
+
+func Test_CircuitBreaker_Invalid(t *testing.T) {
+	assert.PanicsWithValue(t, "circuit breaker generator must be a function", func() {
+		CircuitBreaker(&testWidget{}, 2, time.Second)
+	})
+	assert.PanicsWithValue(t, "circuit breaker threshold must be at least 1", func() {
+		CircuitBreaker(func() *testWidget { return nil }, 0, time.Second)
+	})
+}
@@ -0,0 +1,68 @@
+package ctxdep
+
+import (
+	"context"
+)
+
+// conditionalDependency is an internal wrapper to signal to the DependencyContext that
+// the dependency should only be added if the condition is true. This is created by
+// When() or WhenFunc().
+type conditionalDependency struct {
+	condition  func() bool
+	dependency any
+}
+
+// When adds the dependency to the dependency context only if cond is true. If it's false,
+// the dependency is skipped entirely as if it was never passed in. This allows for
+// environment-specific wiring to be declared along with the rest of the dependencies:
+//
+//	ctx = ctxdep.NewDependencyContext(ctx, UserGenerator, ctxdep.When(isDev, &FakeMailer{}))
+//
+// The dependency may be anything that can be passed to NewDependencyContext, including
+// the results of Immediate() or a slice of dependencies, except for context options and
+// a context.Context, which are applied before any dependencies are added. Passing one of
+// those panics.
+func When(cond bool, dep any) *conditionalDependency {
+	return &conditionalDependency{
+		condition:  func() bool { return cond },
+		dependency: dep,
+	}
+}
+
+// WhenFunc is the same as When, except the condition is determined by calling cond when
+// the dependency would be added to the dependency context.
+func WhenFunc(cond func() bool, dep any) *conditionalDependency {
+	if cond == nil {
+		panic("conditional dependency must have a condition function")
+	}
+	return &conditionalDependency{
+		condition:  cond,
+		dependency: dep,
+	}
+}
+
+// addConditionalDependency adds the dependency of the conditional wrapper if its condition
+// is true.
+func (d *DependencyContext) addConditionalDependency(conditional *conditionalDependency, immediate *immediateDependencies) {
+	checkConditionalDependency(conditional.dependency)
+	if !conditional.condition() {
+		return
+	}
+	d.addDependencies([]any{conditional.dependency}, immediate)
+}
+
+// checkConditionalDependency panics if the dependency, or any dependency in a slice of them,
+// is something that can't be conditionally added. This is checked regardless of the
+// condition so that the wiring fails the same way in every environment.
+func checkConditionalDependency(dep any) {
+	switch v := dep.(type) {
+	case ContextOption:
+		panic("a context option may not be a conditional dependency")
+	case context.Context:
+		panic("a context may not be a conditional dependency")
+	case []any:
+		for _, sub := range v {
+			checkConditionalDependency(sub)
+		}
+	}
+}
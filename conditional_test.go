@@ -0,0 +1,71 @@
+package ctxdep
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"reflect"
+	"testing"
+)
+
+func Test_When(t *testing.T) {
+	ctx := NewDependencyContext(context.Background(),
+		When(true, &testWidget{Val: 42}),
+		When(false, &testDoodad{Val: "skipped"}))
+
+	assert.Equal(t, 42, Get[*testWidget](ctx).Val)
+	assert.False(t, Has[*testDoodad](ctx))
+}
+
+func Test_When_Override(t *testing.T) {
+	isTest := true
+	ctx := NewDependencyContext(context.Background(),
+		When(!isTest, func() *testWidget { return &testWidget{Val: 1} }),
+		When(isTest, func() *testWidget { return &testWidget{Val: 2} }))
+
+	assert.Equal(t, 2, Get[*testWidget](ctx).Val)
+}
+
+func Test_WhenFunc(t *testing.T) {
+	calls := 0
+	cond := func() bool {
+		calls++
+		return true
+	}
+	ctx := NewDependencyContext(context.Background(), WhenFunc(cond, []any{&testWidget{Val: 42}, &testDoodad{Val: "42"}}))
+
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, 42, Get[*testWidget](ctx).Val)
+	assert.Equal(t, "42", Get[*testDoodad](ctx).Val)
+
+	assert.PanicsWithValue(t, "conditional dependency must have a condition function", func() {
+		WhenFunc(nil, &testWidget{})
+	})
+}
+
+func Test_When_Immediate(t *testing.T) {
+	ctx := NewDependencyContext(context.Background(),
+		When(true, Immediate(func() *testWidget { return &testWidget{Val: 42} })),
+		Immediate(When(true, func() *testDoodad { return &testDoodad{Val: "42"} })))
+	dc := GetDependencyContext(ctx)
+	for range dc.ImmediateDone() {
+	}
+
+	for _, slotType := range []reflect.Type{reflect.TypeOf(&testWidget{}), reflect.TypeOf(&testDoodad{})} {
+		sa, ok := dc.slots.Load(slotType)
+		assert.True(t, ok)
+		assert.NotNil(t, sa.(*slot).immediate)
+		assert.NotNil(t, sa.(*slot).loadValue())
+	}
+}
+
+func Test_When_Unsupported(t *testing.T) {
+	assert.PanicsWithValue(t, "a context option may not be a conditional dependency", func() {
+		NewDependencyContext(context.Background(), When(false, WithOverrides()))
+	})
+	assert.PanicsWithValue(t, "a context option may not be a conditional dependency", func() {
+		NewDependencyContext(context.Background(), When(true, []any{&testWidget{}, WithOverrides()}))
+	})
+	assert.PanicsWithValue(t, "a context may not be a conditional dependency", func() {
+		NewDependencyContext(context.Background(), When(true, context.Background()))
+	})
+}
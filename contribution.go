@@ -0,0 +1,138 @@
+package ctxdep
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// contribution is a single element of the slice of T that is returned by GetSlice. This is
+// created by Contributes(). A contribution holds either a value or a generator for it.
+type contribution struct {
+	contributionType reflect.Type
+	value            any
+	generator        any
+	lock             sync.Mutex
+}
+
+// Contributes adds an element to the slice of T that is returned by GetSlice. Unlike other
+// dependencies, any number of contributions can be made for the same type without
+// conflicting with each other or with a regular dependency of type T. This allows for
+// collecting things such as middleware from separate places:
+//
+//	ctx = ctxdep.NewDependencyContext(ctx,
+//	    ctxdep.Contributes[Middleware](LoggingMiddleware),
+//	    ctxdep.Contributes[Middleware](AuthMiddlewareGenerator))
+//	...
+//	middleware := ctxdep.GetSlice[Middleware](ctx)
+//
+// The contribution is either a value of type T, or a generator that returns a T, optionally
+// along with an error. A generator is run the first time the slice is requested. Any other
+// contribution panics.
+func Contributes[T any](contribution any) *contribution {
+	contributionType := reflect.TypeOf((*T)(nil)).Elem()
+	return newContribution(contributionType, contribution)
+}
+
+// newContribution validates and creates a contribution of the given type.
+func newContribution(contributionType reflect.Type, dep any) *contribution {
+	depType := reflect.TypeOf(dep)
+	if depType == nil {
+		panic(fmt.Sprintf("invalid nil contribution for type %v", contributionType))
+	}
+	if depType.AssignableTo(contributionType) {
+		return &contribution{contributionType: contributionType, value: dep}
+	}
+	if depType.Kind() == reflect.Func {
+		resultTypes := generatorResultTypes(depType)
+		if len(resultTypes) == 1 && resultTypes[0].AssignableTo(contributionType) {
+			return &contribution{contributionType: contributionType, generator: dep}
+		}
+	}
+	panic(fmt.Sprintf("contribution for %v must be a value of that type or a generator that returns one, got %v", contributionType, depType))
+}
+
+// addContribution adds the contribution to the dependency context after the ones that were
+// already added for its type.
+func (d *DependencyContext) addContribution(c *contribution, immediate *immediateDependencies) {
+	if immediate != nil {
+		panic(fmt.Sprintf("a contribution for %v may not be immediate", c.contributionType))
+	}
+	if d.contributions == nil {
+		d.contributions = map[reflect.Type][]*contribution{}
+	}
+	d.contributions[c.contributionType] = append(d.contributions[c.contributionType], c)
+}
+
+// GetSlice returns all the contributions for type T that were added with Contributes. The
+// contributions from the parent dependency contexts come first, then the ones from this
+// dependency context, with the contributions of each dependency context in the order that
+// they were added. Any contribution generators that have not yet run are run. If there are
+// no contributions, the result is empty. If any of the generators fail, this panics.
+func GetSlice[T any](ctx context.Context) []T {
+	result, err := GetSliceWithError[T](ctx)
+	if err != nil {
+		panic(err)
+	}
+	return result
+}
+
+// GetSliceWithError is the same as GetSlice, except it returns an error if any of the
+// contribution generators fail.
+func GetSliceWithError[T any](ctx context.Context) ([]T, error) {
+	contributionType := reflect.TypeOf((*T)(nil)).Elem()
+	var chain []*DependencyContext
+	for dc := GetDependencyContext(ctx); dc != nil; dc = dc.parentDependencyContext() {
+		chain = append(chain, dc)
+	}
+
+	var result []T
+	for i := len(chain) - 1; i >= 0; i-- {
+		dc := chain[i]
+		for _, c := range dc.contributions[contributionType] {
+			value, err := dc.resolveContribution(ctx, c)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, value.(T))
+		}
+	}
+	return result, nil
+}
+
+// resolveContribution returns the value of the contribution, running its generator if it
+// hasn't been run yet.
+func (d *DependencyContext) resolveContribution(ctx context.Context, c *contribution) (any, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.value != nil {
+		return c.value, nil
+	}
+	results, _, err := d.invokeGenerator(ctx, c.generator, c.contributionType, c.contributionType, 0)
+	if err != nil {
+		return nil, err
+	}
+	if err = d.getGeneratorError(results); err != nil {
+		return nil, &DependencyError{
+			Message:        "error running contribution generator",
+			ReferencedType: c.contributionType,
+			Status:         d.Status(),
+			SourceError:    err,
+		}
+	}
+	for _, result := range results {
+		if result.Type().AssignableTo(errorType) {
+			continue
+		}
+		if (result.Kind() == reflect.Pointer || result.Kind() == reflect.Interface) && result.IsNil() {
+			return nil, &DependencyError{
+				Message:        "generator returned nil result",
+				ReferencedType: c.contributionType,
+				Status:         d.Status(),
+			}
+		}
+		c.value = result.Interface()
+	}
+	return c.value, nil
+}
@@ -0,0 +1,91 @@
+package ctxdep
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type testMiddleware func(string) string
+
+func Test_Contributes(t *testing.T) {
+	calls := 0
+	parent := NewDependencyContext(context.Background(),
+		Contributes[testMiddleware](testMiddleware(func(s string) string { return s + " parent" })))
+	ctx := NewDependencyContext(parent,
+		&testWidget{Val: 42},
+		Contributes[testMiddleware](func(w *testWidget) testMiddleware {
+			calls++
+			return func(s string) string { return s + " generated" }
+		}),
+		Contributes[testMiddleware](testMiddleware(func(s string) string { return s + " value" })))
+
+	var results []string
+	for _, m := range GetSlice[testMiddleware](ctx) {
+		results = append(results, m("called"))
+	}
+	assert.Equal(t, []string{"called parent", "called generated", "called value"}, results)
+
+	// Generators are only run once.
+	assert.Len(t, GetSlice[testMiddleware](ctx), 3)
+	assert.Equal(t, 1, calls)
+
+	assert.Len(t, GetSlice[testMiddleware](parent), 1)
+	assert.Empty(t, GetSlice[*testDoodad](ctx))
+	// A contribution doesn't fill the regular slot for the type.
+	assert.False(t, Has[testMiddleware](ctx))
+}
+
+func Test_Contributes_Interface(t *testing.T) {
+	ctx := NewDependencyContext(context.Background(),
+		&testImpl{val: 1},
+		Contributes[testInterface](&testImpl{val: 2}),
+		Contributes[testInterface](func() (*testImpl, error) { return &testImpl{val: 3}, nil }))
+
+	var vals []int
+	for _, i := range GetSlice[testInterface](ctx) {
+		vals = append(vals, i.getVal())
+	}
+	assert.Equal(t, []int{2, 3}, vals)
+	assert.Equal(t, 1, Get[testInterface](ctx).getVal())
+}
+
+func Test_Contributes_Errors(t *testing.T) {
+	ctx := NewDependencyContext(context.Background(),
+		Contributes[*testWidget](func() (*testWidget, error) { return nil, errors.New("expected error") }))
+	_, err := GetSliceWithError[*testWidget](ctx)
+	assert.EqualError(t, err, "error running contribution generator: *ctxdep.testWidget (expected error)")
+	assert.Panics(t, func() {
+		GetSlice[*testWidget](ctx)
+	})
+
+	ctx = NewDependencyContext(context.Background(),
+		Contributes[*testWidget](func() *testWidget { return nil }))
+	_, err = GetSliceWithError[*testWidget](ctx)
+	assert.EqualError(t, err, "generator returned nil result: *ctxdep.testWidget")
+
+	assert.PanicsWithValue(t, "contribution for *ctxdep.testWidget must be a value of that type or a generator that returns one, got *ctxdep.testDoodad", func() {
+		Contributes[*testWidget](&testDoodad{})
+	})
+	assert.PanicsWithValue(t, "invalid nil contribution for type *ctxdep.testWidget", func() {
+		Contributes[*testWidget](nil)
+	})
+	assert.PanicsWithValue(t, "a contribution for *ctxdep.testWidget may not be immediate", func() {
+		NewDependencyContext(context.Background(), Immediate(Contributes[*testWidget](&testWidget{})))
+	})
+	assert.PanicsWithError(t, "contribution generator for (*ctxdep.testDoodad) *ctxdep.testWidget has dependencies that cannot be resolved", func() {
+		NewDependencyContext(context.Background(), Contributes[*testWidget](func(*testDoodad) *testWidget { return &testWidget{} }))
+	})
+}
+
+func Test_Contributes_Merge(t *testing.T) {
+	a := GetDependencyContext(NewDependencyContext(context.Background(), Contributes[*testWidget](&testWidget{Val: 1})))
+	b := GetDependencyContext(NewDependencyContext(context.Background(), Contributes[*testWidget](&testWidget{Val: 2})))
+	merged := Merge(context.Background(), a, b)
+
+	widgets := GetSlice[*testWidget](merged.Context())
+	assert.Len(t, widgets, 2)
+	assert.Equal(t, 1, widgets[0].Val)
+	assert.Equal(t, 2, widgets[1].Val)
+}
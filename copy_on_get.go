@@ -0,0 +1,106 @@
+package ctxdep
+
+import (
+	"bytes"
+	"encoding/gob"
+	"reflect"
+	"sync"
+)
+
+// copiers holds the functions registered with RegisterCopier, keyed by type.
+var copiers sync.Map
+
+// RegisterCopier registers the function that is used to copy values of type t for the
+// dependencies that are marked with WithCopyOnGet. Without a registered copier, values are
+// copied by round-tripping them through encoding/gob, which only copies exported fields.
+// Registering a copier is needed for types that can't be copied that way, or where a
+// faster copy is wanted. The copier must return a value of type t.
+func RegisterCopier(t reflect.Type, copier func(any) any) {
+	copiers.Store(t, copier)
+}
+
+// WithCopyOnGet causes every request for a dependency of type T to get its own copy of the
+// value instead of the shared instance. This applies to the dependency context it's passed to
+// and to its children. Values are copied with the function registered with RegisterCopier
+// for the type, or with a gob round-trip if there isn't one.
+//
+// The copy is made on every request for T, including when T is a parameter of a generator,
+// so this is intended for types with value semantics that consumers may modify.
+func WithCopyOnGet[T any]() ContextOption {
+	copyType := reflect.TypeOf((*T)(nil)).Elem()
+	return func(o *contextOptions) {
+		if o.copyOnGet == nil {
+			o.copyOnGet = map[reflect.Type]bool{}
+		}
+		o.copyOnGet[copyType] = true
+	}
+}
+
+// inheritCopyOnGet adds the types that the parent dependency contexts copy on get to the
+// ones of this dependency context. This is done once when the dependency context is created
+// so that the check for a type doesn't have to go through the parents.
+func (d *DependencyContext) inheritCopyOnGet() {
+	pdc := d.parentDependencyContext()
+	if pdc == nil || len(pdc.options.copyOnGet) == 0 {
+		return
+	}
+	copyOnGet := map[reflect.Type]bool{}
+	for t := range pdc.options.copyOnGet {
+		copyOnGet[t] = true
+	}
+	for t := range d.options.copyOnGet {
+		copyOnGet[t] = true
+	}
+	d.options.copyOnGet = copyOnGet
+}
+
+// isCopyOnGet returns if values of type t are copied when they are requested.
+func (d *DependencyContext) isCopyOnGet(t reflect.Type) bool {
+	return d.options.copyOnGet[t]
+}
+
+// copyTarget replaces the value that the target points to with a copy of it if it is of a
+// type that is copied on get. The requested type and the type of the value are both checked
+// since an interface may have been requested.
+func (d *DependencyContext) copyTarget(requestedType reflect.Type, target any) error {
+	if len(d.options.copyOnGet) == 0 {
+		return nil
+	}
+	targetVal := reflect.ValueOf(target).Elem()
+	value := targetVal.Interface()
+	if value == nil {
+		return nil
+	}
+	valueType := reflect.TypeOf(value)
+	if !d.isCopyOnGet(requestedType) && !d.isCopyOnGet(valueType) {
+		return nil
+	}
+	copied, err := copyValue(valueType, value)
+	if err != nil {
+		return &DependencyError{
+			Message:        "unable to copy dependency",
+			ReferencedType: valueType,
+			Status:         d.Status(),
+			SourceError:    err,
+		}
+	}
+	targetVal.Set(reflect.ValueOf(copied))
+	return nil
+}
+
+// copyValue makes a copy of the value with the registered copier for its type, or with a
+// gob round-trip.
+func copyValue(t reflect.Type, value any) (any, error) {
+	if copier, ok := copiers.Load(t); ok {
+		return copier.(func(any) any)(value), nil
+	}
+	buf := bytes.Buffer{}
+	if err := gob.NewEncoder(&buf).EncodeValue(reflect.ValueOf(value)); err != nil {
+		return nil, err
+	}
+	result := reflect.New(t)
+	if err := gob.NewDecoder(&buf).DecodeValue(result); err != nil {
+		return nil, err
+	}
+	return result.Elem().Interface(), nil
+}
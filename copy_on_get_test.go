@@ -0,0 +1,78 @@
+package ctxdep
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"reflect"
+	"testing"
+)
+
+type copyOnGetValue struct {
+	Val   int
+	Items []string
+}
+
+func Test_WithCopyOnGet(t *testing.T) {
+	original := &copyOnGetValue{Val: 42, Items: []string{"a"}}
+	ctx := NewDependencyContext(context.Background(), WithCopyOnGet[*copyOnGetValue](), original, &testWidget{Val: 1})
+
+	first := Get[*copyOnGetValue](ctx)
+	first.Val = 1
+	first.Items[0] = "changed"
+	second := Get[*copyOnGetValue](ctx)
+
+	assert.NotSame(t, original, first)
+	assert.NotSame(t, first, second)
+	assert.Equal(t, &copyOnGetValue{Val: 42, Items: []string{"a"}}, second)
+	assert.Equal(t, &copyOnGetValue{Val: 42, Items: []string{"a"}}, original)
+
+	// Other types are still shared.
+	assert.Same(t, Get[*testWidget](ctx), Get[*testWidget](ctx))
+}
+
+func Test_WithCopyOnGet_Generator(t *testing.T) {
+	ctx := NewDependencyContext(context.Background(), WithCopyOnGet[*copyOnGetValue](), func() *copyOnGetValue {
+		return &copyOnGetValue{Val: 42}
+	}, func(v *copyOnGetValue) *testWidget {
+		v.Val = 0
+		return &testWidget{Val: 1}
+	})
+
+	_ = Get[*testWidget](ctx)
+	first := Get[*copyOnGetValue](ctx)
+	assert.Equal(t, 42, first.Val)
+	assert.NotSame(t, first, Get[*copyOnGetValue](ctx))
+}
+
+func Test_WithCopyOnGet_Child(t *testing.T) {
+	parent := NewDependencyContext(context.Background(), WithCopyOnGet[*copyOnGetValue](), &copyOnGetValue{Val: 42})
+	child := NewDependencyContext(parent, &testWidget{Val: 1})
+
+	first := Get[*copyOnGetValue](child)
+	first.Val = 0
+	second := Get[*copyOnGetValue](child)
+
+	assert.Equal(t, 42, second.Val)
+	assert.NotSame(t, first, second)
+}
+
+func Test_RegisterCopier(t *testing.T) {
+	valueType := reflect.TypeOf(&testImpl{})
+	RegisterCopier(valueType, func(v any) any {
+		return &testImpl{val: v.(*testImpl).val + 1}
+	})
+	defer copiers.Delete(valueType)
+
+	ctx := NewDependencyContext(context.Background(), WithCopyOnGet[*testImpl](), &testImpl{val: 42})
+
+	assert.Equal(t, 43, Get[*testImpl](ctx).val)
+	// Requesting an interface copies the value it's assigned from.
+	assert.Equal(t, 43, Get[testInterface](ctx).getVal())
+}
+
+func Test_WithCopyOnGet_Error(t *testing.T) {
+	ctx := NewDependencyContext(context.Background(), WithCopyOnGet[*testImpl](), &testImpl{val: 42})
+
+	_, err := GetWithError[*testImpl](ctx)
+	assert.ErrorContains(t, err, "unable to copy dependency: *ctxdep.testImpl")
+}
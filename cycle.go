@@ -2,7 +2,9 @@ package ctxdep
 
 import (
 	"context"
+	"fmt"
 	"reflect"
+	"strings"
 	"sync"
 )
 
@@ -13,9 +15,11 @@ const cycleKey cycle = 0
 type unlocker func()
 
 // cycleChecker detects cyclic dependencies in DependencyContext by tracking
-// inProcess generator types and managing concurrent access.
+// inProcess generator types and managing concurrent access. It also keeps the path of
+// slot types that are being resolved to limit the depth of the resolution.
 type cycleChecker struct {
 	inProcess map[reflect.Type]bool
+	path      []reflect.Type
 	lock      sync.Mutex
 }
 
@@ -52,13 +56,48 @@ func (d *DependencyContext) enterSlotProcessing(ctx context.Context, s *slot) (c
 		}
 	}
 
+	if maxDepth := d.maxDepth(); maxDepth > 0 && len(checker.path) >= maxDepth {
+		return nil, func() {}, &DependencyError{
+			Message:        fmt.Sprintf("max resolution depth of %d exceeded (%s)", maxDepth, formatResolutionPath(append(checker.path, s.slotType))),
+			ReferencedType: s.slotType,
+			Status:         d.Status(),
+		}
+	}
+
 	// Mark the generator type as inProcess
 	checker.inProcess[genType] = true
+	checker.path = append(checker.path, s.slotType)
 
 	return checkerCtx, func() {
 		checker.lock.Lock()
 		// Remove the generator type from the inProcess map
 		delete(checker.inProcess, genType)
+		for i := len(checker.path) - 1; i >= 0; i-- {
+			if checker.path[i] == s.slotType {
+				checker.path = append(checker.path[:i], checker.path[i+1:]...)
+				break
+			}
+		}
 		checker.lock.Unlock()
 	}, nil
 }
+
+// maxDepth returns the maximum depth of resolution that was set with WithMaxDepth on this
+// dependency context or any of its parents. If there is no maximum, this returns 0.
+func (d *DependencyContext) maxDepth() int {
+	for dc := d; dc != nil; dc = dc.parentDependencyContext() {
+		if dc.options.maxDepth > 0 {
+			return dc.options.maxDepth
+		}
+	}
+	return 0
+}
+
+// formatResolutionPath returns the path of the types that are being resolved.
+func formatResolutionPath(path []reflect.Type) string {
+	names := make([]string, len(path))
+	for i, t := range path {
+		names[i] = t.String()
+	}
+	return strings.Join(names, " -> ")
+}
@@ -5,7 +5,10 @@ import (
 	"fmt"
 	"github.com/gburgyan/go-timing"
 	"reflect"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type key int
@@ -52,6 +55,11 @@ type DependencyContext struct {
 	// lifetimes.
 	parentContext context.Context
 
+	// parentDC is the DependencyContext of the parentContext, if there is one. This is
+	// looked up once when the parentContext is set to save walking the context chain on
+	// every request that goes to the parent.
+	parentDC *DependencyContext
+
 	// selfContext is the context that contains this DependencyContext.
 	selfContext context.Context
 
@@ -59,6 +67,10 @@ type DependencyContext struct {
 	// generated by a generator.
 	slots sync.Map
 
+	// keyedSlots holds the dependencies that were added with Keyed. These are keyed on both
+	// the type and the name of the dependency.
+	keyedSlots sync.Map
+
 	// loose controls if slots can be overridden during the construction of the DependencyContext.
 	// The general use case of this would be for unit tests where there may be a general
 	// set of dependencies that are added, but certain ones are overridden for use by the test
@@ -69,16 +81,107 @@ type DependencyContext struct {
 	// parentFixed controls if we are in a position to override the parent context. This
 	// is only usable by the first added dependency.
 	parentFixed bool
+
+	// immediateDone is closed once all the immediate dependencies are resolved. Any
+	// errors from resolving them are sent on it first.
+	immediateDone chan error
+
+	// options are the settings from any ContextOption that was passed in when the
+	// DependencyContext was created.
+	options contextOptions
+
+	// contributions holds the elements for GetSlice that were added with Contributes, keyed
+	// by their type. This is only modified while the DependencyContext is being created.
+	contributions map[reflect.Type][]*contribution
+
+	// streams holds the trackers of the stream dependencies that were requested with
+	// GetLatest, keyed by the channel type.
+	streams sync.Map
+
+	// generatorRuns counts the number of times a generator was run by this
+	// DependencyContext. This is accessed atomically.
+	generatorRuns int32
+
+	// generatorCount is the number of generator slots that have been added, which gives
+	// each of them its order.
+	generatorCount int
+
+	// bindings are the interfaces from Bind that are registered under their concrete
+	// types once all the dependencies are added. This is only used while the
+	// DependencyContext is being created.
+	bindings []*boundDependency
 }
 
 // slot stored the internal state of a dependency slot.
 type slot struct {
-	value     any
+	// value holds the slotValue with the value of the slot. This is read without a lock,
+	// so it must only be accessed with loadValue and storeValue.
+	value     atomic.Value
 	generator any
 	slotType  reflect.Type
 	lock      sync.Mutex
 	immediate *immediateDependencies
-	status    SlotStatus
+
+	// status is how the slot is provided. This is set before the slot is stored in the
+	// dependency context and never changes afterward, so it may be read without a lock.
+	status SlotStatus
+
+	// generatorLock is shared by all the slots of the same generator, so that a generator
+	// with several results only needs one lock to be taken while it runs. If this is nil,
+	// the lock of the slot is used instead.
+	generatorLock *sync.Mutex
+
+	// order is the order in which the slot of a generator was added.
+	order int
+
+	// fallback is an optional generator that is invoked if the primary generator
+	// returns an error. This is set by wrapping generators with Fallback().
+	fallback any
+
+	// optional is true if the generator is dropped if its parameters can't be resolved.
+	// This is set by wrapping generators with OptionalGenerator().
+	optional bool
+
+	// retry holds the settings for retrying the generator if it returns an error. This
+	// is set by wrapping generators with Retry().
+	retry *retryGenerator
+
+	// breaker is the circuit breaker for the generator. This is set by wrapping
+	// generators with CircuitBreaker().
+	breaker *circuitBreaker
+
+	// timeout is how long each run of the generator is given before its context times
+	// out. This is set by wrapping generators with WithTimeout().
+	timeout time.Duration
+}
+
+// slotValue is what is held in the value of a slot. The value is wrapped since atomic.Value
+// can't hold a nil value.
+type slotValue struct {
+	value any
+}
+
+// newValueSlot returns a slot of the type that holds the value.
+func newValueSlot(slotType reflect.Type, value any, status SlotStatus) *slot {
+	s := &slot{
+		slotType: slotType,
+		status:   status,
+	}
+	s.storeValue(value)
+	return s
+}
+
+// loadValue returns the value of the slot, or nil if it doesn't have one.
+func (s *slot) loadValue() any {
+	if sv, ok := s.value.Load().(slotValue); ok {
+		return sv.value
+	}
+	return nil
+}
+
+// storeValue sets the value of the slot. A nil value clears it.
+func (s *slot) storeValue(value any) {
+	s.value.Store(slotValue{value: value})
 }
 
 type SlotStatus int
@@ -87,10 +190,13 @@ const (
 	StatusDirect     SlotStatus = iota // directly set dependency
 	StatusGenerator                    // a generator ran to create this dependency
 	StatusFromParent                   // imported from a parent dependency context (optimization)
+	StatusRequired                     // declared with Require but not provided
+	StatusHidden                       // hidden from the parent dependency context with Without
 )
 
 var errorType = reflect.TypeOf((*error)(nil)).Elem()
 var contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+var dependencyContextType = reflect.TypeOf((*DependencyContext)(nil))
 
 // addDependenciesAndInitialize adds the given dependencies to the context. This will add
 // all the dependencies passed in and treat them as a generator if it's a function or
@@ -100,21 +206,155 @@ var contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
 //
 // After adding the dependencies to the context, any immediate dependencies will be resolved.
 func (d *DependencyContext) addDependenciesAndInitialize(ctx context.Context, deps ...any) {
+	d.applyOptions(deps)
+	if d.options.overrides {
+		d.loose = true
+	}
 	d.addDependencies(deps, nil)
-	d.validateDependencies()
+	d.applyBindings()
+	d.initialize(ctx, hasDependencies(deps))
+}
+
+// initialize checks the dependencies once they're all added and starts resolving the
+// immediate ones. addsDependencies is whether this dependency context has any dependencies
+// of its own, which isn't allowed under a frozen parent.
+func (d *DependencyContext) initialize(ctx context.Context, addsDependencies bool) {
+	d.inheritCopyOnGet()
+	if errs := d.constructionErrors(addsDependencies); len(errs) > 0 {
+		panic(errs[0])
+	}
 	d.resolveImmediateDependencies(ctx)
 }
 
-// validateDependencies ensures that everything that was added is in a consistent state. If
-// any dependencies exist that can't be fulfilled, this will `panic`.
-func (d *DependencyContext) validateDependencies() {
+// constructionErrors checks the dependencies once they're all added and returns the problems
+// that keep the dependency context from being created. Any optional generators that can't be
+// resolved are dropped first.
+func (d *DependencyContext) constructionErrors(addsDependencies bool) []*ConstructionError {
+	if addsDependencies && d.parentDependencyContext().isFrozen() {
+		return []*ConstructionError{{
+			Category: ConstructionFrozen,
+			Message:  "cannot add dependencies to a child of a frozen dependency context",
+		}}
+	}
+	d.dropInvalidOptionalGenerators()
+	errs := d.unresolvedDependencies()
+	if d.isStrictShadowCheck() {
+		errs = append(errs, d.shadowedParentValues()...)
+	}
+	return errs
+}
+
+// isFrozen checks if WithFreeze was used on this dependency context or any of its parents.
+func (d *DependencyContext) isFrozen() bool {
+	found := false
+	d.WalkParents(func(dc *DependencyContext) bool {
+		found = dc.options.frozen
+		return !found
+	})
+	return found
+}
+
+// isStrictShadowCheck checks if WithStrictShadowCheck was used on this dependency context or
+// any of its parents.
+func (d *DependencyContext) isStrictShadowCheck() bool {
+	found := false
+	d.WalkParents(func(dc *DependencyContext) bool {
+		found = dc.options.strictShadowCheck
+		return !found
+	})
+	return found
+}
+
+// shadowedParentValues returns an error for each generator of this dependency context that
+// makes a type that can be assigned to a direct value of a parent dependency context.
+func (d *DependencyContext) shadowedParentValues() []*ConstructionError {
+	var errs []*ConstructionError
+	d.slots.Range(func(key, sa any) bool {
+		s := sa.(*slot)
+		if key.(reflect.Type) != s.slotType || s.generator == nil {
+			return true
+		}
+		for pdc := d.parentDependencyContext(); pdc != nil; pdc = pdc.parentDependencyContext() {
+			pdc.slots.Range(func(pkey, psa any) bool {
+				ps := psa.(*slot)
+				if pkey.(reflect.Type) != ps.slotType || ps.status != StatusDirect {
+					return true
+				}
+				if s.slotType == ps.slotType || (ps.slotType.Kind() == reflect.Interface && s.slotType.Implements(ps.slotType)) {
+					generator := formatGeneratorDebug(s.generator)
+					errs = append(errs, &ConstructionError{
+						Category:       ConstructionShadowed,
+						Generator:      generator,
+						ReferencedType: ps.slotType,
+						Message:        fmt.Sprintf("generator %s shadows the %v direct value of a parent dependency context", generator, ps.slotType),
+					})
+				}
+				return true
+			})
+		}
+		return true
+	})
+	return errs
+}
+
+// hasDependencies checks if there are any dependencies other than contexts and options.
+func hasDependencies(deps []any) bool {
+	for _, dep := range deps {
+		switch v := dep.(type) {
+		case context.Context, ContextOption:
+			continue
+		case []any:
+			if hasDependencies(v) {
+				return true
+			}
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+// unresolvedDependencies returns an error for each generator, including contribution
+// generators, that has parameters that can't be fulfilled by the dependencies present.
+func (d *DependencyContext) unresolvedDependencies() []*ConstructionError {
+	var errs []*ConstructionError
+	// A generator has a slot for each of its results, and a slot can be stored under more
+	// than one type, but each generator is only reported once.
+	reported := map[string]bool{}
 	d.slots.Range(func(_, sa any) bool {
 		s := sa.(*slot)
-		if !d.isSlotValid(s) {
-			panic(fmt.Sprintf("generator for %s has dependencies that cannot be resolved", formatGeneratorDebug(s.generator)))
+		if gen, inType, unresolved := d.slotUnresolvedParameter(s); unresolved {
+			generator := formatGeneratorDebug(gen)
+			if reported[generator] {
+				return true
+			}
+			reported[generator] = true
+			errs = append(errs, &ConstructionError{
+				Category:       ConstructionUnresolved,
+				Generator:      generator,
+				ReferencedType: inType,
+				Message:        fmt.Sprintf("generator for %s has dependencies that cannot be resolved", generator),
+			})
 		}
 		return true
 	})
+	for _, cs := range d.contributions {
+		for _, c := range cs {
+			if c.generator == nil {
+				continue
+			}
+			if inType, unresolved := d.unresolvedParameter(c.generator); unresolved {
+				generator := formatGeneratorDebug(c.generator)
+				errs = append(errs, &ConstructionError{
+					Category:       ConstructionUnresolved,
+					Generator:      generator,
+					ReferencedType: inType,
+					Message:        fmt.Sprintf("contribution generator for %s has dependencies that cannot be resolved", generator),
+				})
+			}
+		}
+	}
+	return errs
 }
 
 // addDependencies adds the given dependencies to the context. This will add all the deps
@@ -130,13 +370,56 @@ func (d *DependencyContext) addDependencies(deps []any, immediate *immediateDepe
 			if d.parentFixed {
 				panic("cannot override parent context")
 			}
-			d.parentContext = ctx
+			d.setParentContext(ctx)
 			d.parentFixed = true
 			continue
 		}
+		if _, ok := dep.(ContextOption); ok {
+			// Options have already been applied.
+			continue
+		}
 		if immediateWrapper, ok := dep.(*immediateDependencies); ok {
 			d.parentFixed = true
 			d.addDependencies(immediateWrapper.dependencies, immediateWrapper)
+		} else if aliased, ok := dep.(*aliasedDependency); ok {
+			d.parentFixed = true
+			d.addAliasedValue(aliased)
+		} else if bound, ok := dep.(*boundDependency); ok {
+			d.parentFixed = true
+			d.bindings = append(d.bindings, bound)
+		} else if typed, ok := dep.(*typedDependency); ok {
+			d.parentFixed = true
+			d.addTypedValue(typed)
+		} else if keyed, ok := dep.(*keyedDependency); ok {
+			d.parentFixed = true
+			d.addKeyedValue(keyed)
+		} else if required, ok := dep.(*requiredDependency); ok {
+			d.parentFixed = true
+			d.addRequired(required.requiredType)
+		} else if hidden, ok := dep.(*hiddenDependency); ok {
+			d.parentFixed = true
+			d.addHidden(hidden.hiddenType)
+		} else if fallbackWrapper, ok := dep.(*fallbackGenerators); ok {
+			d.parentFixed = true
+			d.addFallbackGenerator(fallbackWrapper, immediate)
+		} else if retryWrapper, ok := dep.(*retryGenerator); ok {
+			d.parentFixed = true
+			d.addRetryGenerator(retryWrapper, immediate)
+		} else if breaker, ok := dep.(*circuitBreaker); ok {
+			d.parentFixed = true
+			d.addCircuitBreakerGenerator(breaker, immediate)
+		} else if tg, ok := dep.(*timeoutGenerator); ok {
+			d.parentFixed = true
+			d.addTimeoutGenerator(tg, immediate)
+		} else if optional, ok := dep.(*optionalGenerator); ok {
+			d.parentFixed = true
+			d.addOptionalGenerator(optional, immediate)
+		} else if c, ok := dep.(*contribution); ok {
+			d.parentFixed = true
+			d.addContribution(c, immediate)
+		} else if conditional, ok := dep.(*conditionalDependency); ok {
+			d.parentFixed = true
+			d.addConditionalDependency(conditional, immediate)
 		} else if subSlice, ok := dep.([]any); ok {
 			d.addDependencies(subSlice, immediate)
 			d.parentFixed = true
@@ -169,16 +452,29 @@ func (d *DependencyContext) addValue(depType reflect.Type, dep any) {
 	if (kind == reflect.Pointer || kind == reflect.Interface) && reflect.ValueOf(dep).IsNil() {
 		panic(fmt.Sprintf("invalid nil value dependency for type %v", depType))
 	}
-	if _, existing := d.slots.Load(depType); existing && !d.loose {
-		panic(fmt.Sprintf("a slot for type %v already exists--value may not override an existing slot", depType))
+	if existing, ok := d.slots.Load(depType); ok {
+		if d.isIdempotentValue(existing.(*slot), dep) {
+			return
+		}
+		if !d.loose && existing.(*slot).status != StatusRequired {
+			panic(fmt.Sprintf("a slot for type %v already exists--value may not override an existing slot", depType))
+		}
 	}
 	// A value may override an existing slot.
-	s := &slot{
-		value:    dep,
-		slotType: depType,
-		status:   StatusDirect,
+	d.slots.Store(depType, newValueSlot(depType, dep, StatusDirect))
+}
+
+// isIdempotentValue returns if adding the value to the existing slot should be a no-op
+// because the dependency context has the WithIdempotentValues option and the slot already
+// holds the exact same value.
+func (d *DependencyContext) isIdempotentValue(existing *slot, dep any) bool {
+	if !d.options.idempotentValues || existing.status != StatusDirect {
+		return false
+	}
+	if !reflect.TypeOf(dep).Comparable() || reflect.TypeOf(existing.loadValue()) != reflect.TypeOf(dep) {
+		return false
 	}
-	d.slots.Store(depType, s)
+	return existing.loadValue() == dep
 }
 
 // GetBatch behaves like GetBatchWithError except it will panic if the requested dependencies are not
@@ -210,18 +506,27 @@ func (d *DependencyContext) FillDependency(ctx context.Context, target any) erro
 	s, t, err := d.findApplicableSlot(target)
 	if err != nil {
 		pdc := d.parentDependencyContext()
+		if pdc == nil || !pdc.hasType(t) {
+			if d.options.configResolver != nil {
+				found, cErr := d.resolveWithConfig(ctx, t, target)
+				if found || cErr != nil {
+					return cErr
+				}
+			}
+			if d.options.fallbackResolver != nil {
+				return d.resolveWithFallback(ctx, t, target)
+			}
+		}
 		if pdc != nil {
 			err = pdc.GetBatchWithError(ctx, target)
-			if err == nil {
+			if err == nil && !d.options.noParentHoisting {
 				// Hoist the parent dependency to this level to save time on future calls.
 				// At this point the target is a pointer to a pointer to the value, so we
 				// have to unwrap one level of indirection.
-				d.slots.Store(t, &slot{
-					value:     reflect.ValueOf(target).Elem().Interface(),
-					generator: nil,
-					slotType:  t,
-					status:    StatusFromParent,
-				})
+				d.slots.Store(t, newValueSlot(t, reflect.ValueOf(target).Elem().Interface(), StatusFromParent))
+			}
+			if err == nil {
+				err = d.copyTarget(t, target)
 			}
 		}
 		return err
@@ -231,23 +536,144 @@ func (d *DependencyContext) FillDependency(ctx context.Context, target any) erro
 	if err != nil {
 		return err
 	}
-	return nil
+	return d.copyTarget(t, target)
+}
+
+// resolveWithConfig uses the config resolver of the dependency context to get a value for a
+// type that isn't otherwise available. This returns false, with no error, if the resolver
+// isn't responsible for the type. If it succeeds, the value is saved as a direct value so the
+// resolver isn't called again for the type.
+func (d *DependencyContext) resolveWithConfig(ctx context.Context, t reflect.Type, target any) (bool, error) {
+	value, found, err := d.options.configResolver(ctx, t)
+	if err != nil {
+		return true, &DependencyError{
+			Message:        "config resolver failed",
+			ReferencedType: t,
+			Status:         d.Status(),
+			SourceError:    err,
+		}
+	}
+	if !found {
+		return false, nil
+	}
+	return true, d.saveResolvedValue(t, value, target, "config resolver")
+}
+
+// resolveWithFallback uses the fallback resolver of the dependency context to make a value for
+// a type that isn't otherwise available. If it succeeds, the value is saved as a direct value
+// so the resolver isn't called again for the type.
+func (d *DependencyContext) resolveWithFallback(ctx context.Context, t reflect.Type, target any) error {
+	value, err := d.options.fallbackResolver(ctx, t)
+	if err != nil {
+		return &DependencyError{
+			Message:        "fallback resolver failed",
+			ReferencedType: t,
+			Status:         d.Status(),
+			SourceError:    err,
+		}
+	}
+	return d.saveResolvedValue(t, value, target, "fallback resolver")
+}
+
+// saveResolvedValue checks the value that a resolver returned for the type and saves it as a
+// direct value before filling in the target with it.
+func (d *DependencyContext) saveResolvedValue(t reflect.Type, value any, target any, resolverName string) error {
+	valueType := reflect.TypeOf(value)
+	if valueType == nil || !valueType.AssignableTo(t) || isNilValue(value) {
+		return &DependencyError{
+			Message:        fmt.Sprintf("%s returned invalid value %v", resolverName, valueType),
+			ReferencedType: t,
+			Status:         d.Status(),
+		}
+	}
+	// If another request resolved the same type first, use the value that was saved.
+	sa, _ := d.slots.LoadOrStore(t, newValueSlot(t, value, StatusDirect))
+	reflect.ValueOf(target).Elem().Set(reflect.ValueOf(sa.(*slot).loadValue()))
+	return d.copyTarget(t, target)
+}
+
+// isNilValue returns if the value is a nil pointer, interface, or other nillable type.
+func isNilValue(value any) bool {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+		return v.IsNil()
+	}
+	return false
 }
 
 // hasApplicableDependency returns if this, or a parent dependency context, as a slot that
 // can fulfil that dependency.
 func (d *DependencyContext) hasApplicableDependency(target any) bool {
-	s, _, _ := d.findApplicableSlot(target)
-	if s != nil {
+	found := false
+	d.WalkParents(func(dc *DependencyContext) bool {
+		s, _, _ := dc.findApplicableSlot(target)
+		if s != nil {
+			found = s.status != StatusHidden
+			return false
+		}
+		if dc.options.fallbackResolver != nil || dc.options.configResolver != nil {
+			found = true
+			return false
+		}
+		return true
+	})
+	return found
+}
+
+// hasType returns if this, or a parent dependency context, has a slot that can fulfil a
+// request for the type. Unlike hasApplicableDependency, this does not record anything if
+// a slot is found that is assignable to the type, so the dependency context is left as is.
+func (d *DependencyContext) hasType(requestedType reflect.Type) bool {
+	if sa, ok := d.slots.Load(requestedType); ok {
+		return sa.(*slot).providesValue()
+	}
+	found := false
+	if requestedType.Kind() == reflect.Interface {
+		d.slots.Range(func(key, sa any) bool {
+			if key.(reflect.Type).AssignableTo(requestedType) && sa.(*slot).providesValue() {
+				found = true
+				return false
+			}
+			return true
+		})
+	}
+	if found {
 		return true
 	}
 	pdc := d.parentDependencyContext()
 	if pdc != nil {
-		return pdc.hasApplicableDependency(target)
+		return pdc.hasType(requestedType)
 	}
 	return false
 }
 
+// findTypeBy returns the first slot type, ordered by name, in this or a parent dependency
+// context that satisfies the matcher. If there isn't one, this returns nil.
+func (d *DependencyContext) findTypeBy(matcher func(reflect.Type) bool) reflect.Type {
+	var types []reflect.Type
+	d.slots.Range(func(key, sa any) bool {
+		s := sa.(*slot)
+		if key.(reflect.Type) == s.slotType && s.providesValue() {
+			types = append(types, s.slotType)
+		}
+		return true
+	})
+	sort.Slice(types, func(i, j int) bool {
+		return types[i].String() < types[j].String()
+	})
+	for _, t := range types {
+		if matcher(t) {
+			return t
+		}
+	}
+	pdc := d.parentDependencyContext()
+	if pdc != nil {
+		return pdc.findTypeBy(matcher)
+	}
+	return nil
+}
+
 // findApplicableSlot looks for an appropriate slot that can fulfil the requested target. If
 // the slot is directly found by the request type, simply return it. Otherwise, look for another
 // slot that can be assigned to the target and return that if fount. Returns nil if
@@ -269,7 +695,7 @@ func (d *DependencyContext) findApplicableSlot(target any) (*slot, reflect.Type,
 	d.slots.Range(func(slotTargetA, sa any) bool {
 		slotTarget = slotTargetA.(reflect.Type)
 		s = sa.(*slot)
-		if requestedType.Kind() == reflect.Interface && slotTarget.AssignableTo(requestedType) {
+		if requestedType.Kind() == reflect.Interface && slotTarget.AssignableTo(requestedType) && s.status != StatusHidden {
 			// Create a new reference to this slot showing that this slot is assignable
 			// to the target. Essentially this caches the slow lookup of the `AssignableTo`
 			// check we just did. This is safe because the slot is still the same slot with
@@ -296,7 +722,7 @@ func (d *DependencyContext) findApplicableSlot(target any) (*slot, reflect.Type,
 // either use the generator to make a value or delegate to an upstream DependencyContext.
 // The precondition for the function is that the slot's type matches the target such that the
 // slot can be assigned to target.
-func (d *DependencyContext) getValue(ctx context.Context, activeSlot *slot, targetType reflect.Type, target any) error {
+func (d *DependencyContext) getValue(ctx context.Context, activeSlot *slot, targetType reflect.Type, target any) (err error) {
 	targetVal := reflect.ValueOf(target)
 
 	// If we have a value in this slot, then we can simply return it without any locking. This
@@ -305,16 +731,36 @@ func (d *DependencyContext) getValue(ctx context.Context, activeSlot *slot, targ
 	//
 	// This is here as an optimization to prevent the code from acquiring the locks if we
 	// don't need to.
-	if activeSlot.value != nil {
-		slotVal := reflect.ValueOf(activeSlot.value)
+	if value := activeSlot.loadValue(); value != nil {
+		slotVal := reflect.ValueOf(value)
 		targetVal.Elem().Set(slotVal)
 		return nil
 	}
 
+	if activeSlot.status == StatusRequired {
+		return &DependencyError{
+			Message:        "required dependency not provided",
+			ReferencedType: activeSlot.slotType,
+			Status:         d.Status(),
+		}
+	}
+	if activeSlot.status == StatusHidden {
+		return &DependencyError{
+			Message:        "dependency hidden with Without",
+			ReferencedType: activeSlot.slotType,
+			Status:         d.Status(),
+		}
+	}
+
 	var timingCtx *timing.Context
 	if EnableTiming >= TimingGenerators {
 		var complete timing.Complete
 		name := fmt.Sprintf("CtxGen(%v)", targetType)
+		if _, nested := ctx.(*secureContext); !nested && callerTimingEnabled(ctx) {
+			if caller := callerName(); caller != "" {
+				name = fmt.Sprintf("CtxGen(%s → %v)", caller, targetType)
+			}
+		}
 		timingCtx, complete = timing.Start(ctx, name)
 		timingCtx.AddDetails("generator", formatGeneratorDebug(activeSlot.generator))
 		defer complete()
@@ -330,19 +776,39 @@ func (d *DependencyContext) getValue(ctx context.Context, activeSlot *slot, targ
 		return err
 	}
 
-	// Preemptively lock all the potential outputs from a generator for this slot, if it exists. We
-	// need to ensure that the locks are acquired in the same order in all cases to prevent potential
-	// deadlocks.
+	// Preemptively lock all the potential outputs from a generator for this slot, if it exists. The
+	// outputs of a generator normally share one lock, so this usually takes a single lock. If they
+	// don't, such as when a loose dependency context replaced some of them, we need to ensure that
+	// the locks are acquired in the same order in all cases to prevent potential deadlocks.
 	resultSlots := d.getGeneratorOutputSlots(activeSlot)
-	for _, resultSlot := range resultSlots {
-		resultSlot.lock.Lock()
+
+	// The resolve hook is deferred before the locks so that it is called after they are
+	// released. It is only called if the generator runs in this call.
+	generated := false
+	if hook := d.options.resolveHook; hook != nil {
+		defer func() {
+			if !generated {
+				return
+			}
+			if err != nil {
+				hook(activeSlot.slotType, nil, err)
+				return
+			}
+			for _, resultSlot := range resultSlots {
+				hook(resultSlot.slotType, resultSlot.loadValue(), nil)
+			}
+		}()
+	}
+
+	for _, lock := range slotLocks(resultSlots) {
+		lock.Lock()
 		//goland:noinspection GoDeferInLoop
-		defer resultSlot.lock.Unlock()
+		defer lock.Unlock()
 	}
 
 	// This is the same check as above, but now completely thread safe.
-	if activeSlot.value != nil {
-		slotVal := reflect.ValueOf(activeSlot.value)
+	if value := activeSlot.loadValue(); value != nil {
+		slotVal := reflect.ValueOf(value)
 		targetVal.Elem().Set(slotVal)
 		if timingCtx != nil {
 			timingCtx.AddDetails("wait", "parallel")
@@ -351,13 +817,23 @@ func (d *DependencyContext) getValue(ctx context.Context, activeSlot *slot, targ
 	}
 
 	// A slot either has a value or a generator. We don't have a value, so call the generator.
-	results, err := d.invokeSlotGenerator(cycleCtx, activeSlot)
+	generated = true
+	atomic.AddInt32(&d.generatorRuns, 1)
+	results, params, err := d.invokeSlotGenerator(cycleCtx, activeSlot, targetType)
 	if err != nil {
 		return err
 	}
 
-	// Check if there's an error that was returned.
+	// Check if there's an error that was returned. If the slot has a fallback generator,
+	// give that a chance to produce the results with the same parameters before surfacing
+	// the error.
 	err = d.getGeneratorError(results)
+	if err != nil && activeSlot.fallback != nil {
+		results, err = d.invokeFallbackGenerator(cycleCtx, activeSlot, params, err)
+		if err != nil {
+			return err
+		}
+	}
 	if err != nil {
 		return &DependencyError{
 			Message:        "error running generator",
@@ -384,12 +860,33 @@ func (d *DependencyContext) getValue(ctx context.Context, activeSlot *slot, targ
 // parentDependencyContext returns the next DependencyContext up the context stack if it
 // exists. Otherwise, this returns nil.
 func (d *DependencyContext) parentDependencyContext() *DependencyContext {
-	pdcAny := d.parentContext.Value(dependencyContextKey)
+	return d.parentDC
+}
+
+// WalkParents calls the visitor with this dependency context and then each of its parents in
+// turn, up to the root. The walk stops early if the visitor returns false. This is a building
+// block for tools that report on the whole chain, such as finding which dependency context
+// first provides a type.
+func (d *DependencyContext) WalkParents(visitor func(dc *DependencyContext) bool) {
+	for dc := d; dc != nil; dc = dc.parentDependencyContext() {
+		if !visitor(dc) {
+			return
+		}
+	}
+}
+
+// setParentContext sets the context this DependencyContext is built on top of and looks up
+// the DependencyContext of it, if there is one.
+func (d *DependencyContext) setParentContext(ctx context.Context) {
+	d.parentContext = ctx
+	d.parentDC = nil
+	pdcAny := ctx.Value(dependencyContextKey)
 	if pdcAny == nil {
-		return nil
+		return
 	}
 	if pdc, ok := pdcAny.(*DependencyContext); ok {
-		return pdc
+		d.parentDC = pdc
+		return
 	}
 	// There should be no normal way to get to this point.
 	panic("unexpected context value of parent dependency context")
@@ -2,11 +2,17 @@ package ctxdep
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/gburgyan/go-timing"
 	"github.com/stretchr/testify/assert"
+	"log"
+	"reflect"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 type testWidget struct {
@@ -315,7 +321,7 @@ func Test_MultipleGenerators_UnresolvedDependency(t *testing.T) {
 	f := func(_ *testDoodad) *testWidget { return nil }
 
 	// The function needs a testDoodad, but there is no such thing in the context.
-	assert.PanicsWithValue(t, "generator for (*ctxdep.testDoodad) *ctxdep.testWidget has dependencies that cannot be resolved", func() {
+	assert.PanicsWithError(t, "generator for (*ctxdep.testDoodad) *ctxdep.testWidget has dependencies that cannot be resolved", func() {
 		_ = NewDependencyContext(context.Background(), f)
 	})
 }
@@ -474,6 +480,18 @@ func Test_ParentContextOverride(t *testing.T) {
 	assert.Equal(t, "wo0t", doodad.Val)
 }
 
+func Test_ParentDependencyContext(t *testing.T) {
+	rootCtx := NewDependencyContext(context.Background(), &testWidget{Val: 23})
+	otherCtx := NewDependencyContext(context.Background(), &testImpl{val: 42})
+
+	ctx := NewDependencyContext(otherCtx, rootCtx, &testDoodad{Val: "wo0t"})
+	assert.Same(t, GetDependencyContext(rootCtx), GetDependencyContext(ctx).parentDependencyContext())
+	assert.False(t, Has[*testImpl](ctx))
+
+	// A context without a dependency context in it has no parent.
+	assert.Nil(t, GetDependencyContext(rootCtx).parentDependencyContext())
+}
+
 func Test_ParentContextOverride_error(t *testing.T) {
 	widget := &testWidget{
 		Val: 23,
@@ -484,3 +502,499 @@ func Test_ParentContextOverride_error(t *testing.T) {
 		NewDependencyContext(context.Background(), &testDoodad{Val: "wo0t"}, rootCtx)
 	})
 }
+
+func Test_Has(t *testing.T) {
+	calls := 0
+	parent := NewDependencyContext(context.Background(), &testImpl{val: 42})
+	ctx := NewDependencyContext(parent, func() *testWidget {
+		calls++
+		return &testWidget{Val: 42}
+	}, Require[*testDoodad]())
+
+	assert.True(t, Has[*testWidget](ctx))
+	assert.True(t, Has[*testImpl](ctx))
+	assert.True(t, Has[testInterface](ctx))
+	assert.False(t, Has[*testDoodad](ctx))
+	assert.False(t, Has[fmt.Stringer](ctx))
+
+	// Nothing was run or recorded.
+	assert.Equal(t, 0, calls)
+	assert.Equal(t, "*ctxdep.testImpl - direct value set", Status(parent))
+	assert.Equal(t, "*ctxdep.testDoodad - required - unsatisfied\n*ctxdep.testWidget - uninitialized - generator: () *ctxdep.testWidget\n----\nparent dependency context:\n*ctxdep.testImpl - direct value set", Status(ctx))
+}
+
+func Test_SetPanicOnMissing(t *testing.T) {
+	logger := &testLogger{}
+	SetLogger(logger)
+	defer SetLogger(log.Default())
+
+	ctx := NewDependencyContext(context.Background(), &testWidget{Val: 42})
+
+	assert.True(t, PanicOnMissing())
+	assert.Panics(t, func() {
+		Get[*testDoodad](ctx)
+	})
+
+	SetPanicOnMissing(false)
+	defer SetPanicOnMissing(true)
+	assert.False(t, PanicOnMissing())
+
+	assert.Nil(t, Get[*testDoodad](ctx))
+	assert.Equal(t, 42, Get[*testWidget](ctx).Val)
+	assert.Equal(t, []string{"error getting dependency: slot not found for requested type: *ctxdep.testDoodad"}, logger.getMessages())
+}
+
+func Test_GetWithTimeout(t *testing.T) {
+	ctx := NewDependencyContext(context.Background(), func(ctx context.Context) (*testWidget, error) {
+		_, hasDeadline := ctx.Deadline()
+		assert.True(t, hasDeadline)
+		return &testWidget{Val: 42}, nil
+	})
+
+	widget, err := GetWithTimeout[*testWidget](ctx, time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, 42, widget.Val)
+}
+
+func Test_GetWithTimeout_Expired(t *testing.T) {
+	ctx := NewDependencyContext(context.Background(), func(ctx context.Context) (*testWidget, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	start := time.Now()
+	widget, err := GetWithTimeout[*testWidget](ctx, 10*time.Millisecond)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Nil(t, widget)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func Test_GetWithTimeout_Blocked(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	ctx := NewDependencyContext(context.Background(), func() *testWidget {
+		// This ignores the context, and holds the lock of the generator until it's released.
+		close(started)
+		<-release
+		return &testWidget{Val: 42}
+	})
+	defer close(release)
+
+	go Get[*testWidget](ctx)
+	<-started
+
+	start := time.Now()
+	widget, err := GetWithTimeout[*testWidget](ctx, 10*time.Millisecond)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Nil(t, widget)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func Test_GetWithTimeout_Error(t *testing.T) {
+	ctx := NewDependencyContext(context.Background(), func() (*testWidget, error) {
+		return nil, errors.New("expected error")
+	})
+
+	_, err := GetWithTimeout[*testWidget](ctx, time.Second)
+	assert.EqualError(t, err, "error running generator: *ctxdep.testWidget (expected error)")
+}
+
+func Test_Snapshot(t *testing.T) {
+	widget := &testWidget{Val: 42}
+	parent := NewDependencyContext(context.Background(), &testImpl{val: 1})
+	ctx := NewDependencyContext(parent, widget, func(i *testImpl) *testDoodad {
+		return &testDoodad{Val: strconv.Itoa(i.val)}
+	}, func() *outputValue {
+		return &outputValue{}
+	})
+	dc := GetDependencyContext(ctx)
+
+	snapshot := dc.Snapshot()
+	assert.Equal(t, map[reflect.Type]any{
+		reflect.TypeOf(widget): widget,
+	}, snapshot)
+
+	doodad := Get[*testDoodad](ctx)
+	assert.Equal(t, map[reflect.Type]any{
+		reflect.TypeOf(widget):      widget,
+		reflect.TypeOf(doodad):      doodad,
+		reflect.TypeOf(&testImpl{}): Get[*testImpl](parent),
+	}, dc.Snapshot())
+
+	// The earlier snapshot is not changed.
+	assert.Len(t, snapshot, 1)
+}
+
+func Test_StatusGrouped(t *testing.T) {
+	parent := NewDependencyContext(context.Background(), &testImpl{val: 1})
+	ctx := NewDependencyContext(parent, &testWidget{Val: 42}, Keyed("name", "value"), Require[*inputValue](),
+		func(i *testImpl) *testDoodad {
+			return &testDoodad{Val: strconv.Itoa(i.val)}
+		}, func() *outputValue {
+			return &outputValue{}
+		})
+	_ = Get[*testDoodad](ctx)
+	_ = Get[testInterface](ctx)
+
+	assert.Equal(t, `direct values:
+*ctxdep.testWidget - direct value set
+keyed values:
+string[name] - direct value set
+generators:
+*ctxdep.outputValue - uninitialized - generator: () *ctxdep.outputValue
+*ctxdep.testDoodad - created from generator: (*ctxdep.testImpl) *ctxdep.testDoodad
+imported from parent:
+*ctxdep.testImpl - imported from parent context
+assigned:
+ctxdep.testInterface - assigned from *ctxdep.testImpl
+required:
+*ctxdep.inputValue - required - unsatisfied
+----
+parent dependency context:
+direct values:
+*ctxdep.testImpl - direct value set`, GetDependencyContext(ctx).StatusGrouped())
+}
+
+func Test_Stats(t *testing.T) {
+	parent := NewDependencyContext(context.Background(), &testImpl{val: 1})
+	ctx := NewDependencyContext(parent, &testWidget{Val: 42}, Keyed("name", "value"), Require[*inputValue](),
+		func(i *testImpl) *testDoodad {
+			return &testDoodad{Val: strconv.Itoa(i.val)}
+		}, func() (*outputValue, error) {
+			return nil, errors.New("expected error")
+		})
+	dc := GetDependencyContext(ctx)
+
+	assert.Equal(t, ContextStats{
+		TotalSlots:    4,
+		Direct:        1,
+		Uninitialized: 2,
+		Required:      1,
+		Keyed:         1,
+	}, dc.Stats())
+
+	_ = Get[*testDoodad](ctx)
+	_, err := GetWithError[*outputValue](ctx)
+	assert.Error(t, err)
+	_ = Get[testInterface](ctx)
+
+	assert.Equal(t, ContextStats{
+		TotalSlots:    5,
+		Direct:        1,
+		Resolved:      1,
+		Uninitialized: 1,
+		ParentImports: 1,
+		Required:      1,
+		Keyed:         1,
+		GeneratorRuns: 2,
+	}, dc.Stats())
+}
+
+func Test_MustGetAs(t *testing.T) {
+	ctx := NewDependencyContext(context.Background(), AddAs[testInterface](&testImpl{val: 42}))
+
+	impl := MustGetAs[*testImpl, testInterface](ctx)
+	assert.Equal(t, 42, impl.val)
+
+	assert.PanicsWithValue(t, "dependency ctxdep.testInterface is *ctxdep.testImpl, not *ctxdep.testWidget", func() {
+		MustGetAs[*testWidget, testInterface](ctx)
+	})
+}
+
+func Test_Get2_Get3(t *testing.T) {
+	ctx := NewDependencyContext(context.Background(), &testWidget{Val: 42}, &testImpl{val: 1}, func() *testDoodad {
+		return &testDoodad{Val: "doodad"}
+	})
+
+	widget, impl := Get2[*testWidget, testInterface](ctx)
+	assert.Equal(t, 42, widget.Val)
+	assert.Equal(t, 1, impl.getVal())
+
+	widget, impl, doodad := Get3[*testWidget, testInterface, *testDoodad](ctx)
+	assert.Equal(t, 42, widget.Val)
+	assert.Equal(t, 1, impl.getVal())
+	assert.Equal(t, "doodad", doodad.Val)
+
+	assert.Panics(t, func() {
+		Get2[*testWidget, *outputValue](ctx)
+	})
+}
+
+func Test_Get2WithError_Get3WithError(t *testing.T) {
+	ctx := NewDependencyContext(context.Background(), &testWidget{Val: 42})
+
+	widget, ov, err := Get2WithError[*testWidget, *outputValue](ctx)
+	assert.Equal(t, 42, widget.Val)
+	assert.Nil(t, ov)
+	assert.EqualError(t, err, "slot not found for requested type: *ctxdep.outputValue")
+
+	widget, ov, doodad, err := Get3WithError[*testWidget, *outputValue, *testDoodad](ctx)
+	assert.Equal(t, 42, widget.Val)
+	assert.Nil(t, ov)
+	assert.Nil(t, doodad)
+	var multiErr *MultiError
+	if assert.ErrorAs(t, err, &multiErr) {
+		assert.Len(t, multiErr.Errors, 2)
+	}
+}
+
+func Test_directValue(t *testing.T) {
+	widget := &testWidget{Val: 42}
+	ctx := NewDependencyContext(context.Background(), widget, AddAs[testInterface](&testImpl{val: 1}), func() *testDoodad {
+		return &testDoodad{}
+	})
+	dc := GetDependencyContext(ctx)
+
+	value, ok := directValue[*testWidget](dc)
+	assert.True(t, ok)
+	assert.Same(t, widget, value)
+
+	impl, ok := directValue[testInterface](dc)
+	assert.True(t, ok)
+	assert.Equal(t, 1, impl.getVal())
+
+	// Generators and missing types use the regular lookup.
+	_, ok = directValue[*testDoodad](dc)
+	assert.False(t, ok)
+	_, ok = directValue[*outputValue](dc)
+	assert.False(t, ok)
+}
+
+func Test_FindBy(t *testing.T) {
+	parent := NewDependencyContext(context.Background(), &testImpl{val: 1})
+	ctx := NewDependencyContext(parent, &testWidget{Val: 42}, func() *testDoodad {
+		return &testDoodad{Val: "doodad"}
+	})
+
+	stringerType := reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+	value, valueType, ok := FindBy(ctx, func(t reflect.Type) bool {
+		return t.Implements(stringerType)
+	})
+	assert.True(t, ok)
+	assert.Equal(t, reflect.TypeOf(&testDoodad{}), valueType)
+	assert.Equal(t, "doodad", value.(*testDoodad).Val)
+
+	interfaceType := reflect.TypeOf((*testInterface)(nil)).Elem()
+	value, _, ok = FindBy(ctx, func(t reflect.Type) bool {
+		return t.Implements(interfaceType)
+	})
+	assert.True(t, ok)
+	assert.Equal(t, 1, value.(testInterface).getVal())
+
+	_, _, ok = FindBy(ctx, func(t reflect.Type) bool {
+		return false
+	})
+	assert.False(t, ok)
+}
+
+func Test_DependencyContext_Cancellation(t *testing.T) {
+	parent, cancel := context.WithCancel(context.Background())
+	ctx := NewDependencyContext(parent, &testWidget{})
+
+	assert.NoError(t, ctx.Err())
+	select {
+	case <-ctx.Done():
+		assert.Fail(t, "dependency context done before cancel")
+	default:
+	}
+
+	// Canceling the parent cancels the context of the dependency context.
+	cancel()
+	<-ctx.Done()
+	<-GetDependencyContext(ctx).Context().Done()
+	assert.Equal(t, context.Canceled, ctx.Err())
+	assert.NotNil(t, Get[*testWidget](ctx))
+}
+
+func Test_DependencyContext_Deadline(t *testing.T) {
+	deadline := time.Now().Add(time.Hour)
+	parent, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+	ctx := NewDependencyContext(parent)
+
+	d, ok := ctx.Deadline()
+	assert.True(t, ok)
+	assert.Equal(t, deadline, d)
+}
+
+func Test_Diff(t *testing.T) {
+	parent := NewDependencyContext(context.Background(), &testImpl{})
+	a := GetDependencyContext(NewDependencyContext(parent,
+		&testWidget{},
+		func() *testDoodad { return &testDoodad{} },
+		Keyed("primary", &testWidget{}),
+	))
+	b := GetDependencyContext(NewDependencyContext(parent,
+		func() *testWidget { return &testWidget{} },
+		func() *testDoodad { return &testDoodad{} },
+		&inputValue{},
+	))
+
+	// Running a generator or importing from the parent doesn't count as a difference.
+	Get[*testDoodad](a.Context())
+	Get[*testImpl](b.Context())
+
+	diffs := Diff(a, b)
+	assert.Len(t, diffs, 3)
+
+	assert.Equal(t, reflect.TypeOf(&inputValue{}), diffs[0].Type)
+	assert.Nil(t, diffs[0].A)
+	assert.Equal(t, StatusDirect, diffs[0].B.Status)
+
+	assert.Equal(t, reflect.TypeOf(&testWidget{}), diffs[1].Type)
+	assert.Equal(t, StatusDirect, diffs[1].A.Status)
+	assert.Equal(t, StatusGenerator, diffs[1].B.Status)
+	assert.Equal(t, "() *ctxdep.testWidget", diffs[1].B.Generator)
+
+	assert.Equal(t, "primary", diffs[2].Key)
+	assert.NotNil(t, diffs[2].A)
+	assert.Nil(t, diffs[2].B)
+
+	assert.Empty(t, Diff(a, a))
+}
+
+func Test_ConcurrentMultiResultGenerator(t *testing.T) {
+	var calls int32
+	ctx := NewDependencyContext(context.Background(), func() (*testWidget, *testDoodad) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(10 * time.Millisecond)
+		return &testWidget{Val: 42}, &testDoodad{Val: "doodad"}
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if i%2 == 0 {
+				assert.Equal(t, 42, Get[*testWidget](ctx).Val)
+			} else {
+				assert.Equal(t, "doodad", Get[*testDoodad](ctx).Val)
+			}
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	dc := GetDependencyContext(ctx)
+	widgetSlot, _ := dc.slots.Load(reflect.TypeOf(&testWidget{}))
+	doodadSlot, _ := dc.slots.Load(reflect.TypeOf(&testDoodad{}))
+	assert.Same(t, widgetSlot.(*slot).runLock(), doodadSlot.(*slot).runLock())
+}
+
+func Test_WalkParents(t *testing.T) {
+	root := NewDependencyContext(context.Background(), WithName("root"), &testWidget{Val: 1})
+	middle := NewDependencyContext(root, WithName("middle"), &testDoodad{Val: "doodad"})
+	leaf := NewDependencyContext(middle, WithName("leaf"), &testImpl{val: 3})
+
+	var names []string
+	GetDependencyContext(leaf).WalkParents(func(dc *DependencyContext) bool {
+		names = append(names, dc.options.name)
+		return true
+	})
+	assert.Equal(t, []string{"leaf", "middle", "root"}, names)
+
+	names = nil
+	GetDependencyContext(leaf).WalkParents(func(dc *DependencyContext) bool {
+		names = append(names, dc.options.name)
+		_, ok := dc.slots.Load(reflect.TypeOf(&testDoodad{}))
+		return !ok
+	})
+	assert.Equal(t, []string{"leaf", "middle"}, names)
+}
+
+func Test_Origin(t *testing.T) {
+	root := NewDependencyContext(context.Background(), &testWidget{Val: 42}, &testImpl{val: 7})
+	middle := NewDependencyContext(root, func(w *testWidget) *testDoodad {
+		return &testDoodad{Val: strconv.Itoa(w.Val)}
+	})
+	leaf := NewDependencyContext(middle, Without[*testImpl]())
+	rootDC := GetDependencyContext(root)
+	middleDC := GetDependencyContext(middle)
+	leafDC := GetDependencyContext(leaf)
+
+	status, owner := Origin[*testWidget](root)
+	assert.Equal(t, StatusDirect, status)
+	assert.Same(t, rootDC, owner)
+
+	// Before and after the value is imported from the parent.
+	status, owner = Origin[*testWidget](leaf)
+	assert.Equal(t, StatusFromParent, status)
+	assert.Same(t, rootDC, owner)
+	_ = Get[*testWidget](leaf)
+	status, owner = Origin[*testWidget](leaf)
+	assert.Equal(t, StatusFromParent, status)
+	assert.Same(t, rootDC, owner)
+
+	status, owner = Origin[*testDoodad](middle)
+	assert.Equal(t, StatusGenerator, status)
+	assert.Same(t, middleDC, owner)
+	status, owner = Origin[*testDoodad](leaf)
+	assert.Equal(t, StatusFromParent, status)
+	assert.Same(t, middleDC, owner)
+
+	status, owner = Origin[testInterface](middle)
+	assert.Equal(t, StatusFromParent, status)
+	assert.Same(t, rootDC, owner)
+
+	_, owner = Origin[*testImpl](leaf)
+	assert.Nil(t, owner)
+	_, owner = leafDC.Origin(reflect.TypeOf(&outputValue{}))
+	assert.Nil(t, owner)
+}
+
+func Test_ConstructionError(t *testing.T) {
+	recovered := func(f func()) (r any) {
+		defer func() {
+			r = recover()
+		}()
+		f()
+		return nil
+	}
+
+	r := recovered(func() {
+		NewDependencyContext(context.Background(), &testWidget{}, func(w *testWidget, d *testDoodad) *outputValue {
+			return &outputValue{}
+		})
+	})
+	ce, ok := r.(*ConstructionError)
+	assert.True(t, ok)
+	assert.Equal(t, ConstructionUnresolved, ce.Category)
+	assert.Equal(t, "(*ctxdep.testWidget, *ctxdep.testDoodad) *ctxdep.outputValue", ce.Generator)
+	assert.Equal(t, reflect.TypeOf(&testDoodad{}), ce.ReferencedType)
+	assert.EqualError(t, ce, "generator for (*ctxdep.testWidget, *ctxdep.testDoodad) *ctxdep.outputValue has dependencies that cannot be resolved")
+
+	parent := NewDependencyContext(context.Background(), WithStrictShadowCheck(), &testWidget{})
+	r = recovered(func() {
+		NewDependencyContext(parent, func() *testWidget { return &testWidget{} })
+	})
+	ce, ok = r.(*ConstructionError)
+	assert.True(t, ok)
+	assert.Equal(t, ConstructionShadowed, ce.Category)
+	assert.Equal(t, reflect.TypeOf(&testWidget{}), ce.ReferencedType)
+
+	_, err := NewBuilder(context.Background()).
+		WithGenerator(func(w *testWidget) *testDoodad { return &testDoodad{} }).
+		BuildWithValidation()
+	assert.ErrorAs(t, err, &ce)
+	assert.Equal(t, reflect.TypeOf(&testWidget{}), ce.ReferencedType)
+}
+
+func Test_GeneratorWithDependencyContext(t *testing.T) {
+	var got *DependencyContext
+	ctx := NewDependencyContext(context.Background(), &testWidget{Val: 42}, func(ctx context.Context, dc *DependencyContext) (*testDoodad, error) {
+		got = dc
+		var w *testWidget
+		if err := dc.FillDependency(ctx, &w); err != nil {
+			return nil, err
+		}
+		var impl *testImpl
+		if err := dc.FillDependency(ctx, &impl); err == nil {
+			return &testDoodad{Val: "impl"}, nil
+		}
+		return &testDoodad{Val: strconv.Itoa(w.Val)}, nil
+	})
+
+	assert.Equal(t, "42", Get[*testDoodad](ctx).Val)
+	assert.Same(t, GetDependencyContext(ctx), got)
+}
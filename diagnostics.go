@@ -1,10 +1,13 @@
 package ctxdep
 
 import (
+	"context"
 	"fmt"
 	"reflect"
+	"runtime"
 	"sort"
 	"strings"
+	"sync/atomic"
 )
 
 // Status is a diagnostic tool that returns a string describing the state of the dependency
@@ -15,8 +18,99 @@ import (
 // or can be cast to another type, and that type hasn't been asked for yet, the other
 // type is not yet known.
 func (d *DependencyContext) Status() string {
-	slotVals := map[string]string{}
-	var slotKeys []string
+	result := strings.Builder{}
+	d.WalkParents(func(dc *DependencyContext) bool {
+		if dc != d {
+			result.WriteString(statusParentSeparator)
+		}
+		lines := dc.statusLines()
+		sort.Slice(lines, func(i, j int) bool {
+			return lines[i].key < lines[j].key
+		})
+		for i, line := range lines {
+			if i > 0 {
+				result.WriteString("\n")
+			}
+			result.WriteString(line.line)
+		}
+		return true
+	})
+	return result.String()
+}
+
+// StatusGrouped is the same as Status, except the dependencies are grouped by their state
+// first, then sorted by type within each group. Each group starts with a heading line. The
+// groups are, in order: direct values, keyed values, generators, imported from the parent,
+// assigned from another type, required, and hidden. Empty groups are left out. This is easier to scan
+// for large dependency contexts, and is stable for golden-file tests.
+func (d *DependencyContext) StatusGrouped() string {
+	result := strings.Builder{}
+	d.WalkParents(func(dc *DependencyContext) bool {
+		if dc != d {
+			result.WriteString(statusParentSeparator)
+		}
+		lines := dc.statusLines()
+		sort.Slice(lines, func(i, j int) bool {
+			if lines[i].group != lines[j].group {
+				return lines[i].group < lines[j].group
+			}
+			return lines[i].key < lines[j].key
+		})
+		for i, line := range lines {
+			if i == 0 || lines[i-1].group != line.group {
+				if i > 0 {
+					result.WriteString("\n")
+				}
+				result.WriteString(statusGroupNames[line.group])
+				result.WriteString(":")
+			}
+			result.WriteString("\n")
+			result.WriteString(line.line)
+		}
+		return true
+	})
+	return result.String()
+}
+
+// statusParentSeparator is written between the status of a dependency context and the status
+// of its parent.
+const statusParentSeparator = "\n----\nparent dependency context:\n"
+
+// statusGroup is the category of a line of the status. The groups are listed in the order
+// that StatusGrouped lists them.
+type statusGroup int
+
+const (
+	statusGroupDirect statusGroup = iota
+	statusGroupKeyed
+	statusGroupGenerator
+	statusGroupFromParent
+	statusGroupAssigned
+	statusGroupRequired
+	statusGroupHidden
+)
+
+var statusGroupNames = map[statusGroup]string{
+	statusGroupDirect:     "direct values",
+	statusGroupKeyed:      "keyed values",
+	statusGroupGenerator:  "generators",
+	statusGroupFromParent: "imported from parent",
+	statusGroupAssigned:   "assigned",
+	statusGroupRequired:   "required",
+	statusGroupHidden:     "hidden",
+}
+
+// statusLine is a single line of the status of a dependency context.
+type statusLine struct {
+	key   string
+	line  string
+	group statusGroup
+}
+
+// statusLines returns the lines of the status of this dependency context, not including its
+// parents, in no particular order.
+func (d *DependencyContext) statusLines() []statusLine {
+	var lines []statusLine
 
 	d.slots.Range(func(key, value any) bool {
 		t := key.(reflect.Type)
@@ -24,47 +118,289 @@ func (d *DependencyContext) Status() string {
 		keyString := fmt.Sprintf("%v", t)
 		if t == s.slotType {
 			var slotLine string
+			var group statusGroup
 			switch s.status {
 			case StatusDirect:
 				slotLine = fmt.Sprintf("%v - direct value set", t)
+				group = statusGroupDirect
 			case StatusGenerator:
-				if s.value == nil {
+				if s.loadValue() == nil {
 					slotLine = fmt.Sprintf("%v - uninitialized - generator: %s", t, formatGeneratorDebug(s.generator))
 				} else {
 					slotLine = fmt.Sprintf("%v - created from generator: %s", t, formatGeneratorDebug(s.generator))
 				}
+				if s.fallback != nil {
+					slotLine += fmt.Sprintf(" - fallback: %s", formatGeneratorDebug(s.fallback))
+				}
+				group = statusGroupGenerator
 			case StatusFromParent:
 				slotLine = fmt.Sprintf("%v - imported from parent context", t)
+				group = statusGroupFromParent
+			case StatusRequired:
+				slotLine = fmt.Sprintf("%v - required - unsatisfied", t)
+				group = statusGroupRequired
+			case StatusHidden:
+				slotLine = fmt.Sprintf("%v - hidden from parent context", t)
+				group = statusGroupHidden
 			}
 			// original slots have matching keys and slot types
-			slotVals[keyString] = slotLine
+			lines = append(lines, statusLine{key: keyString, line: slotLine, group: group})
 		} else {
 			// non-matching keys and slot types are created when there is a fuzzier
 			// match between the actual slot type and the requested type. These are
 			// created lazily in findApplicableSlot.
-			slotVals[keyString] = fmt.Sprintf("%v - assigned from %v", t, s.slotType)
+			lines = append(lines, statusLine{
+				key:   keyString,
+				line:  fmt.Sprintf("%v - assigned from %v", t, s.slotType),
+				group: statusGroupAssigned,
+			})
 		}
-		slotKeys = append(slotKeys, keyString)
 		return true
 	})
 
-	sort.Strings(slotKeys)
+	d.keyedSlots.Range(func(key, _ any) bool {
+		k := key.(keyedSlotKey)
+		keyString := fmt.Sprintf("%v[%s]", k.slotType, k.name)
+		lines = append(lines, statusLine{
+			key:   keyString,
+			line:  fmt.Sprintf("%s - direct value set", keyString),
+			group: statusGroupKeyed,
+		})
+		return true
+	})
 
-	result := strings.Builder{}
-	for _, slotKey := range slotKeys {
-		if result.Len() > 0 {
-			result.WriteString("\n")
+	return lines
+}
+
+// Snapshot returns the values that are currently in this dependency context, keyed by their
+// type. This includes direct values, values that have been created by generators, and values
+// that have been imported from a parent dependency context. Generators that have not run are
+// not included, and no generators are run to make the snapshot. The returned map is a copy,
+// so it is not affected by any subsequent changes to the dependency context.
+func (d *DependencyContext) Snapshot() map[reflect.Type]any {
+	result := map[reflect.Type]any{}
+	d.slots.Range(func(key, value any) bool {
+		s := value.(*slot)
+		if key.(reflect.Type) != s.slotType {
+			return true
+		}
+		if value := s.loadValue(); value != nil {
+			result[s.slotType] = value
 		}
-		result.WriteString(slotVals[slotKey])
+		return true
+	})
+	return result
+}
+
+// Origin returns how the dependency of the type is provided to this dependency context, and
+// the dependency context that owns it. For a value that was imported from a parent, or that
+// is only in a parent so far, the status is StatusFromParent and the owner is the parent that
+// actually provides it. For a dependency of this dependency context, the owner is this one.
+// If t is an interface, a dependency that implements it is used, the same as with Has.
+// Nothing is run or recorded to determine this.
+//
+// If no dependency context provides the type, or it's hidden with Without, this returns nil
+// as the owner.
+func (d *DependencyContext) Origin(t reflect.Type) (SlotStatus, *DependencyContext) {
+	var status SlotStatus
+	var owner *DependencyContext
+	d.WalkParents(func(dc *DependencyContext) bool {
+		s := dc.ownSlot(t)
+		if s == nil || s.status == StatusFromParent || s.status == StatusRequired {
+			return true
+		}
+		if s.status != StatusHidden {
+			status = s.status
+			owner = dc
+		}
+		return false
+	})
+	if owner != nil && owner != d {
+		status = StatusFromParent
 	}
+	return status, owner
+}
 
-	pdc := d.parentDependencyContext()
-	if pdc != nil {
-		result.WriteString("\n----\nparent dependency context:\n")
-		result.WriteString(pdc.Status())
+// ownSlot returns the slot of this dependency context, not including its parents, for the
+// type. If t is an interface and there's no slot for it yet, the first slot that can be
+// assigned to it is returned. This returns nil if there is none.
+func (d *DependencyContext) ownSlot(t reflect.Type) *slot {
+	if sa, ok := d.slots.Load(t); ok {
+		return sa.(*slot)
 	}
+	var result *slot
+	if t.Kind() == reflect.Interface {
+		d.slots.Range(func(key, sa any) bool {
+			if key.(reflect.Type).AssignableTo(t) && sa.(*slot).providesValue() {
+				result = sa.(*slot)
+				return false
+			}
+			return true
+		})
+	}
+	return result
+}
 
-	return result.String()
+// SlotDiff is a difference in a dependency between two dependency contexts. This is
+// returned from Diff.
+type SlotDiff struct {
+	// Type is the type of the dependency.
+	Type reflect.Type
+
+	// Key is the name of the dependency if it was added with Keyed.
+	Key string
+
+	// A is the dependency in the first dependency context, or nil if it's only in the
+	// second.
+	A *SlotReport
+
+	// B is the dependency in the second dependency context, or nil if it's only in the
+	// first.
+	B *SlotReport
+}
+
+// Diff compares the wiring of two dependency contexts. The result has an entry for each
+// dependency that is only in one of them, and for each dependency that is in both but is
+// provided differently, such as by a generator in one and a direct value in the other, or
+// by generators with different signatures. Whether a generator has run yet is not compared.
+// Only the dependencies of the two dependency contexts themselves are compared, not those of
+// their parents, and values that were imported from a parent are ignored. The result is
+// ordered by type name and then key.
+func Diff(a, b *DependencyContext) []SlotDiff {
+	type slotKey struct {
+		t    reflect.Type
+		name string
+	}
+	bReports := map[slotKey]SlotReport{}
+	for _, sr := range b.slotReports() {
+		if sr.Status != StatusFromParent {
+			bReports[slotKey{sr.Type, sr.Key}] = sr
+		}
+	}
+
+	var diffs []SlotDiff
+	for _, sr := range a.slotReports() {
+		sr := sr
+		if sr.Status == StatusFromParent {
+			continue
+		}
+		k := slotKey{sr.Type, sr.Key}
+		other, ok := bReports[k]
+		delete(bReports, k)
+		if !ok {
+			diffs = append(diffs, SlotDiff{Type: sr.Type, Key: sr.Key, A: &sr})
+		} else if sr != other {
+			diffs = append(diffs, SlotDiff{Type: sr.Type, Key: sr.Key, A: &sr, B: &other})
+		}
+	}
+	for _, sr := range bReports {
+		sr := sr
+		diffs = append(diffs, SlotDiff{Type: sr.Type, Key: sr.Key, B: &sr})
+	}
+
+	sort.Slice(diffs, func(i, j int) bool {
+		ti, tj := diffs[i].Type.String(), diffs[j].Type.String()
+		if ti != tj {
+			return ti < tj
+		}
+		return diffs[i].Key < diffs[j].Key
+	})
+	return diffs
+}
+
+// ContextStats is a summary of the state of a dependency context. This is returned by
+// DependencyContext.Stats.
+type ContextStats struct {
+	// TotalSlots is the number of types that the dependency context can provide, not
+	// counting the types that were matched to an existing slot by assignment.
+	TotalSlots int
+
+	// Direct is the number of slots with values that were added directly.
+	Direct int
+
+	// Resolved is the number of slots whose generators have produced a value.
+	Resolved int
+
+	// Uninitialized is the number of slots whose generators have not yet produced a value.
+	Uninitialized int
+
+	// ParentImports is the number of slots with values that were imported from a parent
+	// dependency context.
+	ParentImports int
+
+	// Required is the number of required dependencies that have not been satisfied.
+	Required int
+
+	// Hidden is the number of types that are hidden from the parent with Without.
+	Hidden int
+
+	// Keyed is the number of keyed dependencies.
+	Keyed int
+
+	// GeneratorRuns is the number of times that generators have been run by the
+	// dependency context, including the runs that returned errors.
+	GeneratorRuns int
+}
+
+// Stats returns a summary of the slots of this dependency context. This is a cheap snapshot
+// that's suitable for dashboards or periodic logging where Status would be too verbose. Only
+// this dependency context is counted, not its parents. No generators are run to make the
+// summary.
+func (d *DependencyContext) Stats() ContextStats {
+	stats := ContextStats{
+		GeneratorRuns: int(atomic.LoadInt32(&d.generatorRuns)),
+	}
+	d.slots.Range(func(key, value any) bool {
+		s := value.(*slot)
+		if key.(reflect.Type) != s.slotType {
+			return true
+		}
+		stats.TotalSlots++
+		switch s.status {
+		case StatusDirect:
+			stats.Direct++
+		case StatusGenerator:
+			if s.loadValue() == nil {
+				stats.Uninitialized++
+			} else {
+				stats.Resolved++
+			}
+		case StatusFromParent:
+			stats.ParentImports++
+		case StatusRequired:
+			stats.Required++
+		case StatusHidden:
+			stats.Hidden++
+		}
+		return true
+	})
+	d.keyedSlots.Range(func(_, _ any) bool {
+		stats.Keyed++
+		return true
+	})
+	return stats
+}
+
+// UnusedGenerators returns the types that generators of this dependency context can make, but
+// have not been made yet. This is sorted by the names of the types. A test can check that this
+// is empty to make sure that none of the wiring is dead. The dependency context must have been
+// created with WithUsageTracking, otherwise this panics.
+func (d *DependencyContext) UnusedGenerators() []reflect.Type {
+	if !d.options.usageTracking {
+		panic("UnusedGenerators requires the WithUsageTracking option")
+	}
+	var result []reflect.Type
+	d.slots.Range(func(key, value any) bool {
+		s := value.(*slot)
+		if key.(reflect.Type) == s.slotType && s.status == StatusGenerator && s.loadValue() == nil {
+			result = append(result, s.slotType)
+		}
+		return true
+	})
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].String() < result[j].String()
+	})
+	return result
 }
 
 // formatGeneratorDebug simply returns a string representation of a generator. This is
@@ -96,3 +432,41 @@ func formatGeneratorDebug(gen any) string {
 	}
 	return builder.String()
 }
+
+// callerTimingEnabled returns if WithCallerTiming was set on the dependency context of ctx
+// or any of its parents.
+func callerTimingEnabled(ctx context.Context) bool {
+	dc, _ := ctx.Value(dependencyContextKey).(*DependencyContext)
+	for dc != nil {
+		if dc.options.callerTiming {
+			return true
+		}
+		dc = dc.parentDependencyContext()
+	}
+	return false
+}
+
+// packagePath is the import path of this package.
+var packagePath = reflect.TypeOf(DependencyContext{}).PkgPath()
+
+// callerName returns the name of the first function on the stack that isn't part of this
+// package, without its package path. Functions in test files are not considered part of the
+// package. If there isn't one, this returns an empty string.
+func callerName() string {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, packagePath+".") || strings.HasSuffix(frame.File, "_test.go") {
+			name := frame.Function
+			if i := strings.LastIndex(name, "/"); i >= 0 {
+				name = name[i+1:]
+			}
+			return name
+		}
+		if !more {
+			return ""
+		}
+	}
+}
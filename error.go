@@ -1,8 +1,10 @@
 package ctxdep
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
+	"strings"
 )
 
 // DependencyError is the standard error type returned from the context dependency library.
@@ -35,3 +37,103 @@ func (e *DependencyError) Error() string {
 func (e *DependencyError) Unwrap() error {
 	return e.SourceError
 }
+
+// MultiError is an error that collects several errors that occurred together, such as
+// when both a generator and its fallback fail.
+type MultiError struct {
+	// Errors are the individual errors in the order they occurred.
+	Errors []error
+}
+
+// Error returns the individual error messages joined together.
+func (e *MultiError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Unwrap returns the individual errors, so errors.Is and errors.As look through each of them.
+func (e *MultiError) Unwrap() []error {
+	return e.Errors
+}
+
+// Is returns if any of the individual errors matches the target. This is for versions of Go
+// before 1.20, where errors.Is doesn't use Unwrap returning a slice.
+func (e *MultiError) Is(target error) bool {
+	for _, err := range e.Errors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first individual error that matches the target, the same as errors.As. This
+// is for versions of Go before 1.20, where errors.As doesn't use Unwrap returning a slice.
+func (e *MultiError) As(target any) bool {
+	for _, err := range e.Errors {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// PanicError is the source of a DependencyError when a generator panics while the
+// dependency context was created with WithRecoverGenerators.
+type PanicError struct {
+	// Value is the value that was recovered from the panic.
+	Value any
+
+	// Stack is the stack trace of the goroutine at the time of the panic.
+	Stack []byte
+}
+
+// Error returns a string form of the recovered value. The stack trace is not included.
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// ConstructionErrorCategory is the kind of problem that a ConstructionError describes.
+type ConstructionErrorCategory int
+
+const (
+	ConstructionUnresolved ConstructionErrorCategory = iota // a generator has parameters that cannot be resolved
+	ConstructionFrozen                                      // dependencies were added to a child of a frozen dependency context
+	ConstructionShadowed                                    // a generator shadows a direct value of a parent with WithStrictShadowCheck
+)
+
+// ConstructionError is the value that NewDependencyContext panics with when the dependencies
+// fail validation, such as when a generator has parameters that can't be resolved. Code that
+// recovers from the panic can check for it to handle each case:
+//
+//	defer func() {
+//	    if ce, ok := recover().(*ctxdep.ConstructionError); ok && ce.Category == ctxdep.ConstructionUnresolved {
+//	        log.Printf("missing %v for %s", ce.ReferencedType, ce.Generator)
+//	    }
+//	}()
+//
+// Problems with the dependencies themselves, such as multiple dependencies for the same type,
+// still panic with a string.
+type ConstructionError struct {
+	// Category is the kind of problem.
+	Category ConstructionErrorCategory
+
+	// Generator is the signature of the generator that failed validation, or empty if the
+	// problem isn't with a generator.
+	Generator string
+
+	// ReferencedType is the parameter type that can't be resolved for ConstructionUnresolved,
+	// or the type of the parent's direct value for ConstructionShadowed.
+	ReferencedType reflect.Type
+
+	// Message is the description of the problem, which is what Error returns.
+	Message string
+}
+
+// Error returns the description of the problem.
+func (e *ConstructionError) Error() string {
+	return e.Message
+}
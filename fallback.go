@@ -0,0 +1,86 @@
+package ctxdep
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// fallbackGenerators is an internal wrapper to signal to the DependencyContext that the
+// secondary generator should be invoked if the primary one returns an error. This is
+// created by Fallback().
+type fallbackGenerators struct {
+	primary   any
+	secondary any
+}
+
+// Fallback wraps a primary generator with a secondary generator that is called if the
+// primary one returns an error. Both generators must take the same parameters and return the
+// same non-error types, in the same order. The parameters are resolved once for the primary
+// generator, and the secondary one is called with the same values. Whichever generator
+// succeeds provides the values stored in the dependency context. If both fail, the returned DependencyError's SourceError is a
+// MultiError containing both errors.
+//
+// The result of Fallback can be passed to NewDependencyContext or Immediate like any
+// other generator.
+func Fallback(primary, secondary any) *fallbackGenerators {
+	primaryType := reflect.TypeOf(primary)
+	secondaryType := reflect.TypeOf(secondary)
+	if primaryType == nil || primaryType.Kind() != reflect.Func || secondaryType == nil || secondaryType.Kind() != reflect.Func {
+		panic("fallback generators must be functions")
+	}
+	if primaryType.NumIn() != secondaryType.NumIn() || primaryType.IsVariadic() != secondaryType.IsVariadic() {
+		panic(fmt.Sprintf("fallback generator %s must take the same parameters as %s", formatGeneratorDebug(secondary), formatGeneratorDebug(primary)))
+	}
+	for i := 0; i < primaryType.NumIn(); i++ {
+		if primaryType.In(i) != secondaryType.In(i) {
+			panic(fmt.Sprintf("fallback generator %s must take the same parameters as %s", formatGeneratorDebug(secondary), formatGeneratorDebug(primary)))
+		}
+	}
+	primaryResults := generatorResultTypes(primaryType)
+	secondaryResults := generatorResultTypes(secondaryType)
+	if len(primaryResults) != len(secondaryResults) {
+		panic(fmt.Sprintf("fallback generator %s must return the same types as %s", formatGeneratorDebug(secondary), formatGeneratorDebug(primary)))
+	}
+	for i := range primaryResults {
+		if primaryResults[i] != secondaryResults[i] {
+			panic(fmt.Sprintf("fallback generator %s must return the same types as %s", formatGeneratorDebug(secondary), formatGeneratorDebug(primary)))
+		}
+	}
+	return &fallbackGenerators{
+		primary:   primary,
+		secondary: secondary,
+	}
+}
+
+// addFallbackGenerator adds the primary generator to the dependency context and records
+// the secondary generator on each of the resulting slots.
+func (d *DependencyContext) addFallbackGenerator(fallback *fallbackGenerators, immediate *immediateDependencies) {
+	slots := d.addGenerator(fallback.primary, immediate)
+	for _, s := range slots {
+		s.fallback = fallback.secondary
+	}
+}
+
+// invokeFallbackGenerator calls the slot's fallback generator with the params that the primary
+// generator was called with, after it failed with primaryErr. If the fallback also fails, the
+// error that is returned includes both errors.
+func (d *DependencyContext) invokeFallbackGenerator(ctx context.Context, activeSlot *slot, params []reflect.Value, primaryErr error) ([]reflect.Value, error) {
+	results, err := d.invokeWithMiddleware(ctx, activeSlot.slotType, func() ([]reflect.Value, error) {
+		return d.callGenerator(activeSlot.fallback, activeSlot.slotType, params)
+	})
+	if err == nil {
+		err = d.getGeneratorError(results)
+	}
+	if err != nil {
+		return nil, &DependencyError{
+			Message:        "error running generator and fallback",
+			ReferencedType: activeSlot.slotType,
+			Status:         d.Status(),
+			SourceError: &MultiError{
+				Errors: []error{primaryErr, err},
+			},
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,129 @@
+package ctxdep
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_Fallback_PrimarySucceeds(t *testing.T) {
+	secondaryCalls := 0
+	primary := func() (*testWidget, error) {
+		return &testWidget{Val: 42}, nil
+	}
+	secondary := func() (*testWidget, error) {
+		secondaryCalls++
+		return &testWidget{Val: 105}, nil
+	}
+
+	ctx := NewDependencyContext(context.Background(), Fallback(primary, secondary))
+
+	widget := Get[*testWidget](ctx)
+	assert.Equal(t, 42, widget.Val)
+	assert.Equal(t, 0, secondaryCalls)
+	assert.Equal(t, "*ctxdep.testWidget - created from generator: () *ctxdep.testWidget, error - fallback: () *ctxdep.testWidget, error", Status(ctx))
+}
+
+func Test_Fallback_PrimaryFails(t *testing.T) {
+	primaryCalls := 0
+	doodadCalls := 0
+	var primaryDoodad, secondaryDoodad *testDoodad
+	primary := func(ctx context.Context, doodad *testDoodad) (*testWidget, error) {
+		primaryCalls++
+		primaryDoodad = doodad
+		return nil, fmt.Errorf("primary error")
+	}
+	secondary := func(ctx context.Context, doodad *testDoodad) (*testWidget, error) {
+		secondaryDoodad = doodad
+		return &testWidget{Val: 105}, nil
+	}
+
+	ctx := NewDependencyContext(context.Background(), WithCopyOnGet[*testDoodad](), func() *testDoodad {
+		doodadCalls++
+		return &testDoodad{Val: "doodad"}
+	}, Fallback(primary, secondary))
+
+	widget := Get[*testWidget](ctx)
+	assert.Equal(t, 105, widget.Val)
+
+	// The fallback is called with the parameters that were resolved for the primary, even
+	// though every request for the doodad would otherwise get its own copy.
+	assert.Equal(t, "doodad", secondaryDoodad.Val)
+	assert.Same(t, primaryDoodad, secondaryDoodad)
+	assert.Equal(t, 1, doodadCalls)
+
+	// The fallback result is stored in the slot, so nothing is called again.
+	widget = Get[*testWidget](ctx)
+	assert.Equal(t, 105, widget.Val)
+	assert.Equal(t, 1, primaryCalls)
+}
+
+func Test_Fallback_BothFail(t *testing.T) {
+	primaryErr := errors.New("primary error")
+	secondaryErr := errors.New("secondary error")
+	primary := func() (*testWidget, error) {
+		return nil, primaryErr
+	}
+	secondary := func() (*testWidget, error) {
+		return nil, secondaryErr
+	}
+
+	ctx := NewDependencyContext(context.Background(), Fallback(primary, secondary))
+
+	_, err := GetWithError[*testWidget](ctx)
+	assert.EqualError(t, err, "error running generator and fallback: *ctxdep.testWidget (primary error; secondary error)")
+
+	var depErr *DependencyError
+	assert.ErrorAs(t, err, &depErr)
+	multiErr, ok := depErr.SourceError.(*MultiError)
+	assert.True(t, ok)
+	assert.Len(t, multiErr.Errors, 2)
+
+	// Both errors can be found through the error that is returned.
+	assert.ErrorIs(t, err, primaryErr)
+	assert.ErrorIs(t, err, secondaryErr)
+}
+
+func Test_Fallback_MultiOutput(t *testing.T) {
+	primary := func() (*testWidget, *testDoodad, error) {
+		return nil, nil, fmt.Errorf("primary error")
+	}
+	secondary := func() (*testWidget, *testDoodad, error) {
+		return &testWidget{Val: 105}, &testDoodad{Val: "fallback"}, nil
+	}
+
+	ctx := NewDependencyContext(context.Background(), Immediate(Fallback(primary, secondary)))
+
+	doodad := Get[*testDoodad](ctx)
+	widget := Get[*testWidget](ctx)
+	assert.Equal(t, "fallback", doodad.Val)
+	assert.Equal(t, 105, widget.Val)
+}
+
+func Test_Fallback_MismatchedTypes(t *testing.T) {
+	primary := func() (*testWidget, error) { return nil, nil }
+	secondary := func() (*testDoodad, error) { return nil, nil }
+
+	assert.PanicsWithValue(t, "fallback generator () *ctxdep.testDoodad, error must return the same types as () *ctxdep.testWidget, error", func() {
+		Fallback(primary, secondary)
+	})
+
+	assert.PanicsWithValue(t, "fallback generator (*ctxdep.testDoodad) *ctxdep.testWidget, error must take the same parameters as () *ctxdep.testWidget, error", func() {
+		Fallback(primary, func(_ *testDoodad) (*testWidget, error) { return nil, nil })
+	})
+
+	assert.PanicsWithValue(t, "fallback generators must be functions", func() {
+		Fallback(primary, &testWidget{})
+	})
+}
+
+func Test_Fallback_UnresolvedDependency(t *testing.T) {
+	primary := func(_ *testDoodad) (*testWidget, error) { return nil, nil }
+	secondary := func(_ *testDoodad) (*testWidget, error) { return &testWidget{}, nil }
+
+	assert.PanicsWithError(t, "generator for (*ctxdep.testDoodad) *ctxdep.testWidget, error has dependencies that cannot be resolved", func() {
+		NewDependencyContext(context.Background(), Fallback(primary, secondary))
+	})
+}
@@ -0,0 +1,55 @@
+package ctxdep
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// Future is the result of a dependency that is being resolved in the background. This is
+// returned by GetAsync.
+type Future[T any] struct {
+	done  chan struct{}
+	value T
+	err   error
+}
+
+// GetAsync starts resolving the dependency of type T in a new goroutine and returns a Future
+// for it. This allows expensive generators to be started early and their results collected
+// later, such as at the start of a request:
+//
+//	profile := ctxdep.GetAsync[*UserProfile](ctx)
+//	// ... other work ...
+//	p, err := profile.Get()
+//
+// The dependency is resolved as with GetWithError, so if the generator for T is already
+// running, because of another GetAsync or any other request, the work is shared rather than
+// done again. A panic while resolving the dependency is returned as an error from Get.
+func GetAsync[T any](ctx context.Context) *Future[T] {
+	f := &Future[T]{
+		done: make(chan struct{}),
+	}
+	go func() {
+		defer close(f.done)
+		defer func() {
+			if r := recover(); r != nil {
+				f.err = fmt.Errorf("panic resolving dependency for %v: %v", reflect.TypeOf((*T)(nil)).Elem(), r)
+			}
+		}()
+		f.value, f.err = GetWithError[T](ctx)
+	}()
+	return f
+}
+
+// Get waits for the dependency to be resolved and returns it, or the error that kept it from
+// being resolved. This may be called any number of times, and from any goroutine.
+func (f *Future[T]) Get() (T, error) {
+	<-f.done
+	return f.value, f.err
+}
+
+// Done returns a channel that is closed once the dependency has been resolved, or has failed
+// to be resolved. This allows waiting for the Future in a select.
+func (f *Future[T]) Done() <-chan struct{} {
+	return f.done
+}
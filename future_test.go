@@ -0,0 +1,46 @@
+package ctxdep
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_GetAsync(t *testing.T) {
+	var calls int32
+	ctx := NewDependencyContext(context.Background(), func() *testWidget {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(20 * time.Millisecond)
+		return &testWidget{Val: 42}
+	})
+
+	f1 := GetAsync[*testWidget](ctx)
+	f2 := GetAsync[*testWidget](ctx)
+
+	w1, err := f1.Get()
+	assert.NoError(t, err)
+	w2, err := f2.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, 42, w1.Val)
+	assert.Same(t, w1, w2)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	<-f1.Done()
+	w1, _ = f1.Get()
+	assert.Same(t, w2, w1)
+}
+
+func Test_GetAsync_Error(t *testing.T) {
+	ctx := NewDependencyContext(context.Background(), &testWidget{})
+
+	_, err := GetAsync[*testDoodad](ctx).Get()
+	assert.EqualError(t, err, "slot not found for requested type: *ctxdep.testDoodad")
+
+	ctx = NewDependencyContext(context.Background(), func() *testDoodad {
+		panic("expected panic")
+	})
+	_, err = GetAsync[*testDoodad](ctx).Get()
+	assert.EqualError(t, err, "panic resolving dependency for *ctxdep.testDoodad: expected panic")
+}
@@ -4,11 +4,15 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"runtime"
+	"sync"
+	"time"
 )
 
 // addGenerator validates the generator function and adds it to the dependency context
-// assuming it's valid. If it's not valid this function panics.
-func (d *DependencyContext) addGenerator(generatorFunction any, immediate *immediateDependencies) {
+// assuming it's valid. If it's not valid this function panics. The slots that were
+// created for the generator's results are returned.
+func (d *DependencyContext) addGenerator(generatorFunction any, immediate *immediateDependencies) []*slot {
 	funcType := reflect.TypeOf(generatorFunction)
 
 	if funcType.Kind() != reflect.Func {
@@ -17,6 +21,40 @@ func (d *DependencyContext) addGenerator(generatorFunction any, immediate *immed
 		panic("generator must be a function")
 	}
 
+	resultTypes := generatorResultTypes(funcType)
+
+	var slots []*slot
+	generatorLock := &sync.Mutex{}
+	for _, resultType := range resultTypes {
+		if existingSlotA, existing := d.slots.Load(resultType); existing {
+			existingSlot := existingSlotA.(*slot)
+			if !d.loose && existingSlot.status != StatusRequired {
+				panic(fmt.Sprintf("generator result type %v already exists--a generator may not override an existing slot", resultType))
+			}
+			if existingSlot.loadValue() != nil {
+				// Never override a concrete value
+				return slots
+			}
+		}
+
+		s := &slot{
+			generator:     generatorFunction,
+			slotType:      resultType,
+			immediate:     immediate,
+			status:        StatusGenerator,
+			order:         d.generatorCount,
+			generatorLock: generatorLock,
+		}
+		d.generatorCount++
+		d.slots.Store(resultType, s)
+		slots = append(slots, s)
+	}
+	return slots
+}
+
+// generatorResultTypes returns the non-error result types of a generator function. If the
+// function has no non-error results or has multiple error results, this panics.
+func generatorResultTypes(funcType reflect.Type) []reflect.Type {
 	hasError := false
 	var resultTypes []reflect.Type
 
@@ -35,29 +73,7 @@ func (d *DependencyContext) addGenerator(generatorFunction any, immediate *immed
 	if len(resultTypes) == 0 {
 		panic("generator must have at least one result value")
 	}
-
-	for _, resultType := range resultTypes {
-		if existingSlotA, existing := d.slots.Load(resultType); existing {
-			existingSlot := existingSlotA.(*slot)
-			if !d.loose {
-				panic(fmt.Sprintf("generator result type %v already exists--a generator may not override an existing slot", resultType))
-			}
-			if existingSlot.value != nil {
-				// Never override a concrete value
-				return
-			}
-		}
-
-		s := &slot{
-			value:     nil,
-			generator: generatorFunction,
-			slotType:  resultType,
-			immediate: immediate,
-			status:    StatusGenerator,
-		}
-		d.slots.Store(resultType, s)
-	}
-	return
+	return resultTypes
 }
 
 // getGeneratorError finds the error result from a generator, if it exists. If no error is present,
@@ -71,8 +87,43 @@ func (d *DependencyContext) getGeneratorError(results []reflect.Value) error {
 	return nil
 }
 
-// invokeSlotGenerator calls the slot's generator function and returns the results of the call.
-func (d *DependencyContext) invokeSlotGenerator(ctx context.Context, activeSlot *slot) ([]reflect.Value, error) {
+// invokeSlotGenerator calls the slot's generator function and returns the results of the call,
+// along with the parameters it was called with. The requestedType is the type that was
+// requested from the dependency context.
+func (d *DependencyContext) invokeSlotGenerator(ctx context.Context, activeSlot *slot, requestedType reflect.Type) ([]reflect.Value, []reflect.Value, error) {
+	var params []reflect.Value
+	results, err := d.invokeWithMiddleware(ctx, activeSlot.slotType, func() (results []reflect.Value, err error) {
+		if activeSlot.retry != nil {
+			results, params, err = d.invokeRetryGenerator(ctx, activeSlot, requestedType)
+		} else if activeSlot.breaker != nil {
+			results, params, err = d.invokeCircuitBreakerGenerator(ctx, activeSlot, requestedType)
+		} else {
+			results, params, err = d.invokeGenerator(ctx, activeSlot.generator, activeSlot.slotType, requestedType, activeSlot.timeout)
+		}
+		return results, err
+	})
+	return results, params, err
+}
+
+// invokeGenerator resolves the parameters for the generator function from the dependency
+// context, calls it, and returns the results of the call along with the parameters. The
+// slotType is the type that the generator is being invoked for, and the requestedType is the
+// type that was requested from the dependency context, which is given to any RequestedType
+// parameter. If the timeout is set, the generator is given a context that times out after it
+// once its parameters are resolved, so the generators of the parameters don't see the
+// deadline.
+func (d *DependencyContext) invokeGenerator(ctx context.Context, generator any, slotType reflect.Type, requestedType reflect.Type, timeout time.Duration) ([]reflect.Value, []reflect.Value, error) {
+	params, err := d.resolveGeneratorParams(ctx, generator, requestedType)
+	if err != nil {
+		return nil, nil, err
+	}
+	results, err := d.callGeneratorWithTimeout(generator, slotType, params, timeout)
+	return results, params, err
+}
+
+// resolveGeneratorParams resolves the parameters for the generator function from the
+// dependency context.
+func (d *DependencyContext) resolveGeneratorParams(ctx context.Context, generator any, requestedType reflect.Type) ([]reflect.Value, error) {
 	var sc context.Context
 	if prevSc, ok := ctx.(*secureContext); ok {
 		// We don't need to keep wrapping contexts if they are already wrapped.
@@ -85,13 +136,33 @@ func (d *DependencyContext) invokeSlotGenerator(ctx context.Context, activeSlot
 		}
 	}
 
-	genType := reflect.TypeOf(activeSlot.generator)
+	genType := reflect.TypeOf(generator)
 	inCount := genType.NumIn()
 	params := make([]reflect.Value, inCount)
 	for i := 0; i < inCount; i++ {
 		inType := genType.In(i)
 		if inType == contextType {
 			params[i] = reflect.ValueOf(sc)
+		} else if inType == requestedTypeType {
+			params[i] = reflect.ValueOf(RequestedType{Type: requestedType})
+		} else if inType == attributesType {
+			params[i] = reflect.ValueOf(d.attributes())
+		} else if inType == dependencyContextType {
+			params[i] = reflect.ValueOf(d)
+		} else if isKeyedParameter(inType) {
+			key, paramPointerValue := keyedParameterKey(inType)
+			value, err := d.getKeyedValue(key)
+			if err != nil {
+				return nil, err
+			}
+			paramPointerValue.Interface().(keyedParameter).setKeyedValue(value)
+			params[i] = paramPointerValue.Elem()
+		} else if isOptionalParameter(inType) {
+			paramValue, err := d.getOptionalParameter(sc, inType)
+			if err != nil {
+				return nil, err
+			}
+			params[i] = paramValue
 		} else {
 			paramPointerValue := reflect.New(inType)
 			targetTypePointer := paramPointerValue.Interface()
@@ -103,9 +174,57 @@ func (d *DependencyContext) invokeSlotGenerator(ctx context.Context, activeSlot
 		}
 	}
 
-	gv := reflect.ValueOf(activeSlot.generator)
-	results := gv.Call(params)
-	return results, nil
+	return params, nil
+}
+
+// callGeneratorWithTimeout calls the generator function with the given parameters. If the
+// timeout is set, the context parameters are replaced with a context that times out after it.
+// The params themselves aren't changed.
+func (d *DependencyContext) callGeneratorWithTimeout(generator any, slotType reflect.Type, params []reflect.Value, timeout time.Duration) ([]reflect.Value, error) {
+	if timeout > 0 {
+		genType := reflect.TypeOf(generator)
+		timed := make([]reflect.Value, len(params))
+		copy(timed, params)
+		var timeoutCtx context.Context
+		for i := range params {
+			if genType.In(i) != contextType {
+				continue
+			}
+			if timeoutCtx == nil {
+				var cancel context.CancelFunc
+				timeoutCtx, cancel = context.WithTimeout(params[i].Interface().(context.Context), timeout)
+				defer cancel()
+			}
+			timed[i] = reflect.ValueOf(timeoutCtx)
+		}
+		params = timed
+	}
+	return d.callGenerator(generator, slotType, params)
+}
+
+// callGenerator calls the generator function with the given parameters. If the dependency
+// context recovers from panics in generators, a panic is converted to a DependencyError.
+func (d *DependencyContext) callGenerator(generator any, slotType reflect.Type, params []reflect.Value) (results []reflect.Value, err error) {
+	if d.options.recoverGenerators {
+		defer func() {
+			if r := recover(); r != nil {
+				buf := make([]byte, 1<<16)
+				stackSize := runtime.Stack(buf, false)
+				results = nil
+				err = &DependencyError{
+					Message:        "generator panicked",
+					ReferencedType: slotType,
+					Status:         d.Status(),
+					SourceError: &PanicError{
+						Value: r,
+						Stack: buf[:stackSize],
+					},
+				}
+			}
+		}()
+	}
+	gv := reflect.ValueOf(generator)
+	return gv.Call(params), nil
 }
 
 // mapGeneratorResults takes the results returned from the generator and fills in the various slots' values
@@ -117,7 +236,7 @@ func (d *DependencyContext) mapGeneratorResults(results []reflect.Value, targetT
 			// already handled
 			continue
 		}
-		if result.Kind() == reflect.Pointer && result.IsNil() {
+		if (result.Kind() == reflect.Pointer || result.Kind() == reflect.Interface) && result.IsNil() {
 			return &DependencyError{
 				Message:        "generator returned nil result",
 				ReferencedType: resultType,
@@ -133,14 +252,15 @@ func (d *DependencyContext) mapGeneratorResults(results []reflect.Value, targetT
 		// Now save the result value to the slot for later use.
 		if resultSlotA, ok := d.slots.Load(resultType); ok {
 			resultSlot := resultSlotA.(*slot)
-			if resultSlot.value == nil {
-				resultSlot.value = result.Interface()
-				resultSlot.status = StatusGenerator
+			if resultSlot.loadValue() == nil {
+				// The status of the slot is already StatusGenerator, and it isn't changed
+				// here since directValue reads it without any lock.
+				resultSlot.storeValue(result.Interface())
 			}
 		} else {
 			// We should never get this since the addGenerator call
 			// should have pre-created these.
-			d.slots.Store(resultType, &slot{value: result.Interface(), status: StatusGenerator})
+			d.slots.Store(resultType, newValueSlot(resultType, result.Interface(), StatusGenerator))
 		}
 	}
 	return nil
@@ -172,31 +292,83 @@ func (d *DependencyContext) getGeneratorOutputSlots(activeSlot *slot) []*slot {
 	return result
 }
 
+// slotLocks returns the distinct locks that need to be held to run the generator of the
+// slots, in the order of the slots.
+func slotLocks(slots []*slot) []*sync.Mutex {
+	var locks []*sync.Mutex
+	seen := map[*sync.Mutex]bool{}
+	for _, s := range slots {
+		lock := s.runLock()
+		if !seen[lock] {
+			seen[lock] = true
+			locks = append(locks, lock)
+		}
+	}
+	return locks
+}
+
+// runLock returns the lock that is held while the generator of the slot runs.
+func (s *slot) runLock() *sync.Mutex {
+	if s.generatorLock != nil {
+		return s.generatorLock
+	}
+	return &s.lock
+}
+
 // isSlotValid verifies that the generator's dependencies can nominally be
 // fulfilled by the dependencies present. This does not check for cyclic dependencies as
 // that would be more expensive.
 func (d *DependencyContext) isSlotValid(s *slot) bool {
-	if s.value != nil {
-		return true
+	_, _, unresolved := d.slotUnresolvedParameter(s)
+	return !unresolved
+}
+
+// slotUnresolvedParameter returns the first parameter of the slot's generator that can't be
+// fulfilled by the dependencies present, along with the generator, and if there is one. A
+// fallback takes the same parameters as the generator, so it doesn't need to be checked.
+func (d *DependencyContext) slotUnresolvedParameter(s *slot) (any, reflect.Type, bool) {
+	if s.loadValue() != nil || !s.providesValue() {
+		return nil, nil, false
+	}
+	if inType, unresolved := d.unresolvedParameter(s.generator); unresolved {
+		return s.generator, inType, true
 	}
-	genType := reflect.TypeOf(s.generator)
+	return nil, nil, false
+}
+
+// isGeneratorValid verifies that all the parameters of the generator function can nominally
+// be fulfilled by the dependencies present.
+func (d *DependencyContext) isGeneratorValid(generator any) bool {
+	_, unresolved := d.unresolvedParameter(generator)
+	return !unresolved
+}
+
+// unresolvedParameter returns the first parameter of the generator function that can't be
+// fulfilled by the dependencies present, and if there is one.
+func (d *DependencyContext) unresolvedParameter(generator any) (reflect.Type, bool) {
+	genType := reflect.TypeOf(generator)
 	if genType.Kind() != reflect.Func {
 		// There should be no way of getting here.
-		return false
+		return nil, true
 	}
 	inCount := genType.NumIn()
 	for i := 0; i < inCount; i++ {
 		inType := genType.In(i)
-		if inType == contextType {
+		if inType == contextType || inType == requestedTypeType || inType == attributesType || inType == dependencyContextType || isOptionalParameter(inType) {
 			continue
+		} else if isKeyedParameter(inType) {
+			key, _ := keyedParameterKey(inType)
+			if !d.hasKeyedDependency(key) {
+				return inType, true
+			}
 		} else {
 			paramPointerValue := reflect.New(inType)
 			targetTypePointer := paramPointerValue.Interface()
 			hasDependency := d.hasApplicableDependency(targetTypePointer)
 			if !hasDependency {
-				return false
+				return inType, true
 			}
 		}
 	}
-	return true
+	return nil, false
 }
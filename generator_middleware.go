@@ -0,0 +1,52 @@
+package ctxdep
+
+import (
+	"context"
+	"reflect"
+)
+
+// GeneratorInvoker runs a generator of a dependency context. The slotType is the type the
+// generator is being run for, and call runs the generator, returning its results and any
+// error from resolving its parameters. This is what is wrapped by WithGeneratorMiddleware.
+type GeneratorInvoker func(ctx context.Context, slotType reflect.Type, call func() ([]reflect.Value, error)) ([]reflect.Value, error)
+
+// WithGeneratorMiddleware wraps every generator that is run by the dependency context, or
+// any of its children, with the middleware. This allows for behavior such as logging,
+// tracing, or metrics around all the generators without changing any of them:
+//
+//	ctxdep.WithGeneratorMiddleware(func(next ctxdep.GeneratorInvoker) ctxdep.GeneratorInvoker {
+//	    return func(ctx context.Context, slotType reflect.Type, call func() ([]reflect.Value, error)) ([]reflect.Value, error) {
+//	        start := time.Now()
+//	        defer func() { metrics.Observe(slotType.String(), time.Since(start)) }()
+//	        return next(ctx, slotType, call)
+//	    }
+//	})
+//
+// The middleware must call next to run the generator. If it's used more than once, or by a
+// parent dependency context as well, the middleware of the parents is outermost, and within
+// a dependency context the first middleware that was given is outermost. The results include
+// the error result of the generator, if it has one.
+func WithGeneratorMiddleware(middleware func(next GeneratorInvoker) GeneratorInvoker) ContextOption {
+	return func(o *contextOptions) {
+		o.generatorMiddleware = append(o.generatorMiddleware, middleware)
+	}
+}
+
+// invokeWithMiddleware runs the call through the generator middleware of this dependency
+// context and its parents.
+func (d *DependencyContext) invokeWithMiddleware(ctx context.Context, slotType reflect.Type, call func() ([]reflect.Value, error)) ([]reflect.Value, error) {
+	var invoker GeneratorInvoker = func(_ context.Context, _ reflect.Type, call func() ([]reflect.Value, error)) ([]reflect.Value, error) {
+		return call()
+	}
+	wrapped := false
+	for dc := d; dc != nil; dc = dc.parentDependencyContext() {
+		for i := len(dc.options.generatorMiddleware) - 1; i >= 0; i-- {
+			invoker = dc.options.generatorMiddleware[i](invoker)
+			wrapped = true
+		}
+	}
+	if !wrapped {
+		return call()
+	}
+	return invoker(ctx, slotType, call)
+}
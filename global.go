@@ -2,7 +2,11 @@ package ctxdep
 
 import (
 	"context"
+	"fmt"
+	"reflect"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type TimingMode int
@@ -23,6 +27,31 @@ const (
 
 var EnableTiming = TimingDisable
 
+// panicOnMissing is 1 if Get panics when a dependency cannot be provided. This is accessed
+// atomically so the mode can be changed safely at any time.
+var panicOnMissing int32 = 1
+
+// SetPanicOnMissing controls what Get does when a dependency cannot be provided. By default,
+// or when this is set to true, Get panics. When this is set to false, Get logs the error and
+// returns the zero value of the requested type instead. This is safe to call concurrently
+// with Get.
+//
+// This does not affect the panic that occurs if there is no dependency context at all, as
+// that is a violation of the preconditions of the call.
+func SetPanicOnMissing(enabled bool) {
+	var v int32
+	if enabled {
+		v = 1
+	}
+	atomic.StoreInt32(&panicOnMissing, v)
+}
+
+// PanicOnMissing returns if Get panics when a dependency cannot be provided. See
+// SetPanicOnMissing for details.
+func PanicOnMissing() bool {
+	return atomic.LoadInt32(&panicOnMissing) == 1
+}
+
 // NewDependencyContext adds a new dependency context to the context stack and returns
 // the new context. It also adds any dependencies that are also passed in to the new
 // dependency context. For a further discussion on what dependencies do and how
@@ -32,9 +61,9 @@ var EnableTiming = TimingDisable
 // will `panic`.
 func NewDependencyContext(ctx context.Context, dependencies ...any) context.Context {
 	dc := &DependencyContext{
-		parentContext: ctx,
-		slots:         sync.Map{},
+		slots: sync.Map{},
 	}
+	dc.setParentContext(ctx)
 	newContext := context.WithValue(ctx, dependencyContextKey, dc)
 	dc.selfContext = newContext
 	dc.addDependenciesAndInitialize(newContext, dependencies...)
@@ -50,10 +79,10 @@ func NewDependencyContext(ctx context.Context, dependencies ...any) context.Cont
 // be used. In case there is no concrete value, the last generator will win.
 func NewLooseDependencyContext(ctx context.Context, dependencies ...any) context.Context {
 	dc := &DependencyContext{
-		parentContext: ctx,
-		slots:         sync.Map{},
-		loose:         true,
+		slots: sync.Map{},
+		loose: true,
 	}
+	dc.setParentContext(ctx)
 	newContext := context.WithValue(ctx, dependencyContextKey, dc)
 	dc.selfContext = newContext
 	dc.addDependenciesAndInitialize(newContext, dependencies...)
@@ -88,17 +117,170 @@ func GetBatch(ctx context.Context, target ...any) {
 }
 
 // Get returns the value of type T from the dependency context. It otherwise behaves exactly like
-// GetBatch, but it only has the capability of returning a single value.
+// GetBatch, but it only has the capability of returning a single value. If SetPanicOnMissing
+// has been used to turn off panics, an error is logged and the zero value is returned instead.
 func Get[T any](ctx context.Context) T {
 	dc := GetDependencyContext(ctx)
+	if value, ok := directValue[T](dc); ok {
+		return value
+	}
 	var target T
 	err := dc.FillDependency(ctx, &target)
 	if err != nil {
-		panic(err)
+		if PanicOnMissing() {
+			panic(err)
+		}
+		logf("error getting dependency: %v", err)
+		var zero T
+		return zero
 	}
 	return target
 }
 
+// directValue is a fast path for getting a value of type T that was added directly to the
+// dependency context. This avoids the reflection that is needed to resolve generators or
+// interfaces. If the value isn't a direct value in this dependency context, this returns
+// false and the regular lookup should be done.
+//
+// No lock is needed since the status of a slot never changes once it's stored, and the value
+// is read atomically.
+func directValue[T any](dc *DependencyContext) (T, bool) {
+	var zero T
+	sa, ok := dc.slots.Load(reflect.TypeOf((*T)(nil)).Elem())
+	if !ok {
+		return zero, false
+	}
+	s := sa.(*slot)
+	if s.status != StatusDirect || dc.isCopyOnGet(s.slotType) {
+		return zero, false
+	}
+	value, ok := s.loadValue().(T)
+	return value, ok
+}
+
+// MustGetAs gets the dependency of type Iface, as with Get, then returns it as the type
+// Concrete. This is for when a dependency is exposed as an interface, but the concrete type
+// that implements it is known, such as in tests. If the dependency isn't a Concrete, this
+// panics with a message that includes the actual type, regardless of SetPanicOnMissing.
+func MustGetAs[Concrete, Iface any](ctx context.Context) Concrete {
+	value := Get[Iface](ctx)
+	concrete, ok := any(value).(Concrete)
+	if !ok {
+		panic(fmt.Sprintf("dependency %v is %T, not %v", reflect.TypeOf((*Iface)(nil)).Elem(), value, reflect.TypeOf((*Concrete)(nil)).Elem()))
+	}
+	return concrete
+}
+
+// Get2 returns the values of types A and B from the dependency context. It otherwise behaves
+// exactly like Get.
+func Get2[A, B any](ctx context.Context) (A, B) {
+	a, b, err := Get2WithError[A, B](ctx)
+	if err != nil {
+		if PanicOnMissing() {
+			panic(err)
+		}
+		logf("error getting dependency: %v", err)
+		var zeroA A
+		var zeroB B
+		return zeroA, zeroB
+	}
+	return a, b
+}
+
+// Get3 returns the values of types A, B, and C from the dependency context. It otherwise
+// behaves exactly like Get.
+func Get3[A, B, C any](ctx context.Context) (A, B, C) {
+	a, b, c, err := Get3WithError[A, B, C](ctx)
+	if err != nil {
+		if PanicOnMissing() {
+			panic(err)
+		}
+		logf("error getting dependency: %v", err)
+		var zeroA A
+		var zeroB B
+		var zeroC C
+		return zeroA, zeroB, zeroC
+	}
+	return a, b, c
+}
+
+// Get2WithError returns the values of types A and B from the dependency context. All the
+// values are attempted even if one fails. If more than one fails, the returned error is a
+// MultiError of the individual errors.
+func Get2WithError[A, B any](ctx context.Context) (A, B, error) {
+	var a A
+	var b B
+	err := fillAll(ctx, &a, &b)
+	return a, b, err
+}
+
+// Get3WithError returns the values of types A, B, and C from the dependency context. All the
+// values are attempted even if one fails. If more than one fails, the returned error is a
+// MultiError of the individual errors.
+func Get3WithError[A, B, C any](ctx context.Context) (A, B, C, error) {
+	var a A
+	var b B
+	var c C
+	err := fillAll(ctx, &a, &b, &c)
+	return a, b, c, err
+}
+
+// fillAll fills each of the targets from the dependency context and combines the errors.
+func fillAll(ctx context.Context, targets ...any) error {
+	dc := GetDependencyContext(ctx)
+	var errs []error
+	for _, target := range targets {
+		if err := dc.FillDependency(ctx, target); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	default:
+		return &MultiError{Errors: errs}
+	}
+}
+
+// Has returns if a dependency of type T can be provided by the dependency context or any of
+// its parents. This is true if there is a value for T, a generator that can create T, or if
+// T is an interface that one of the dependencies implements. Nothing is run to determine
+// this, so a generator for T may still fail when T is requested. This does not modify the
+// dependency context in any way.
+func Has[T any](ctx context.Context) bool {
+	dc := GetDependencyContext(ctx)
+	return dc.hasType(reflect.TypeOf((*T)(nil)).Elem())
+}
+
+// Origin returns how the dependency of type T is provided to the dependency context, and the
+// dependency context that owns it. This is useful for finding out where a dependency that is
+// imported from a parent really comes from. See DependencyContext.Origin for the details.
+func Origin[T any](ctx context.Context) (SlotStatus, *DependencyContext) {
+	dc := GetDependencyContext(ctx)
+	return dc.Origin(reflect.TypeOf((*T)(nil)).Elem())
+}
+
+// FindBy finds a dependency whose type satisfies the matcher, such as one that implements
+// several interfaces. The matcher is called with the type of each dependency in the dependency
+// context, in the order of the type names, then with the types in the parent dependency
+// contexts. The first dependency that matches is resolved, running its generator if needed,
+// and returned along with its type. If there is no match, or the dependency can't be
+// resolved, this returns false.
+func FindBy(ctx context.Context, matcher func(reflect.Type) bool) (any, reflect.Type, bool) {
+	dc := GetDependencyContext(ctx)
+	matchType := dc.findTypeBy(matcher)
+	if matchType == nil {
+		return nil, nil, false
+	}
+	target := reflect.New(matchType)
+	if err := dc.FillDependency(ctx, target.Interface()); err != nil {
+		return nil, nil, false
+	}
+	return target.Elem().Interface(), matchType, true
+}
+
 // GetBatchWithError will try to get the requested dependencies from the context's
 // DependencyContext. If it fails to do so it will return an error. If the context's
 // DependencyContext is not found, this will still panic as its preconditions were
@@ -113,11 +295,59 @@ func GetBatchWithError(ctx context.Context, target ...any) error {
 // GetBatchWithError, but it only has the capability of returning a single value and an error object.
 func GetWithError[T any](ctx context.Context) (T, error) {
 	dc := GetDependencyContext(ctx)
+	if value, ok := directValue[T](dc); ok {
+		return value, nil
+	}
 	var target T
 	err := dc.FillDependency(ctx, &target)
 	return target, err
 }
 
+// GetWithTimeout gets a single value of type T from the dependency context, but gives up if
+// it isn't resolved within the timeout. Any generators that are called to resolve the value
+// are passed a context with the timeout. If the value isn't resolved within the timeout, the
+// error of that context is returned, which is context.DeadlineExceeded.
+//
+// The value is resolved in a new goroutine so that this returns once the timeout passes, even
+// if a generator doesn't respect the cancellation of its context or another request holds the
+// lock of the generator. That goroutine is abandoned when the timeout passes, but it keeps
+// running until the generator returns, and the results are still saved in the dependency
+// context. A panic while resolving the value is returned as an error.
+func GetWithTimeout[T any](ctx context.Context, timeout time.Duration) (T, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	dc := GetDependencyContext(ctx)
+
+	type result struct {
+		value T
+		err   error
+	}
+	// The channel is buffered so an abandoned goroutine doesn't block when it finishes.
+	results := make(chan result, 1)
+	go func() {
+		var r result
+		defer func() {
+			if p := recover(); p != nil {
+				r.err = fmt.Errorf("panic resolving dependency for %v: %v", reflect.TypeOf((*T)(nil)).Elem(), p)
+			}
+			results <- r
+		}()
+		r.err = dc.FillDependency(timeoutCtx, &r.value)
+	}()
+
+	select {
+	case r := <-results:
+		if ctxErr := timeoutCtx.Err(); ctxErr != nil {
+			var zero T
+			return zero, ctxErr
+		}
+		return r.value, r.err
+	case <-timeoutCtx.Done():
+		var zero T
+		return zero, timeoutCtx.Err()
+	}
+}
+
 // Status is a diagnostic tool that returns a string describing the state of the dependency
 // context. The result is each dependency type that is known about, and if it has a value
 // and if it has a generator that is capable of making that value.
@@ -0,0 +1,121 @@
+package ctxdep
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// GraphDOT returns a graph of the dependencies of this dependency context in the DOT
+// language of Graphviz. This is useful for documentation, or for understanding large sets of
+// dependencies where Status is too hard to read. The output can be rendered with something
+// like `dot -Tsvg`.
+//
+// The nodes are the types in the dependency context; the ones that are made by generators
+// are boxes. Each generator has an edge from its results to each of its parameters. If a
+// parameter is an interface that is provided by another type, there is a dashed edge from the
+// interface to that type. Optional parameters are dotted edges, and parameters that aren't in
+// this dependency context, such as those from a parent, are gray nodes.
+func (d *DependencyContext) GraphDOT() string {
+	nodes := map[string]string{}
+	edges := map[string]bool{}
+	addEdge := func(from, to string, style string) {
+		edge := fmt.Sprintf("%q -> %q", from, to)
+		if style != "" {
+			edge += fmt.Sprintf(" [style=%s]", style)
+		}
+		edges[edge] = true
+	}
+
+	var slots []*slot
+	d.slots.Range(func(key, value any) bool {
+		s := value.(*slot)
+		if key.(reflect.Type) != s.slotType {
+			return true
+		}
+		slots = append(slots, s)
+		if s.generator != nil {
+			nodes[s.slotType.String()] = " [shape=box]"
+		} else {
+			nodes[s.slotType.String()] = ""
+		}
+		return true
+	})
+	d.keyedSlots.Range(func(key, _ any) bool {
+		k := key.(keyedSlotKey)
+		nodes[fmt.Sprintf("%v[%s]", k.slotType, k.name)] = ""
+		return true
+	})
+
+	// Parameters are resolved after all the nodes are known so that the ones that aren't in
+	// this dependency context can be told apart.
+	for _, s := range slots {
+		for _, gen := range []any{s.generator, s.fallback} {
+			if gen == nil {
+				continue
+			}
+			genType := reflect.TypeOf(gen)
+			for i := 0; i < genType.NumIn(); i++ {
+				inType := genType.In(i)
+				style := ""
+				switch {
+				case inType == contextType || inType == requestedTypeType || inType == attributesType || inType == dependencyContextType:
+					continue
+				case isKeyedParameter(inType):
+					key, _ := keyedParameterKey(inType)
+					addGraphParam(nodes, addEdge, s.slotType, fmt.Sprintf("%v[%s]", key.slotType, key.name), "")
+					continue
+				case isOptionalParameter(inType):
+					inType = reflect.New(inType).Interface().(optionalParameter).optionalType()
+					style = "dotted"
+				}
+				if sa, ok := d.slots.Load(inType); ok && sa.(*slot).slotType != inType {
+					// The parameter was already assigned from another type.
+					nodes[inType.String()] = ""
+					addEdge(inType.String(), sa.(*slot).slotType.String(), "dashed")
+				} else if !ok && inType.Kind() == reflect.Interface {
+					for _, impl := range slots {
+						if impl.slotType.AssignableTo(inType) && impl.providesValue() {
+							nodes[inType.String()] = ""
+							addEdge(inType.String(), impl.slotType.String(), "dashed")
+						}
+					}
+				}
+				addGraphParam(nodes, addEdge, s.slotType, inType.String(), style)
+			}
+		}
+	}
+
+	var nodeLines []string
+	for name, attrs := range nodes {
+		nodeLines = append(nodeLines, fmt.Sprintf("\t%q%s;\n", name, attrs))
+	}
+	sort.Strings(nodeLines)
+	var edgeLines []string
+	for edge := range edges {
+		edgeLines = append(edgeLines, fmt.Sprintf("\t%s;\n", edge))
+	}
+	sort.Strings(edgeLines)
+
+	result := strings.Builder{}
+	result.WriteString("digraph dependencies {\n")
+	for _, line := range nodeLines {
+		result.WriteString(line)
+	}
+	for _, line := range edgeLines {
+		result.WriteString(line)
+	}
+	result.WriteString("}\n")
+	return result.String()
+}
+
+// addGraphParam adds the edge from a generator's result to one of its parameters. If the
+// parameter isn't a node yet, it's added as one that comes from outside this dependency
+// context.
+func addGraphParam(nodes map[string]string, addEdge func(from, to, style string), resultType reflect.Type, param string, style string) {
+	if _, ok := nodes[param]; !ok {
+		nodes[param] = " [color=gray]"
+	}
+	addEdge(resultType.String(), param, style)
+}
@@ -0,0 +1,37 @@
+package ctxdep
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_GraphDOT(t *testing.T) {
+	parent := NewDependencyContext(context.Background(), &inputValue{Value: "parent"})
+	ctx := NewDependencyContext(parent,
+		&testImpl{val: 42},
+		Keyed("primary", &testWidget{Val: 1}),
+		func(ctx context.Context, i testInterface, in *inputValue) *testWidget {
+			return &testWidget{Val: i.getVal()}
+		},
+		func(w *testWidget, primary primaryWidget, o OptionalParam[*outputValue]) *testDoodad {
+			return &testDoodad{Val: "doodad"}
+		})
+
+	assert.Equal(t, `digraph dependencies {
+	"*ctxdep.inputValue" [color=gray];
+	"*ctxdep.outputValue" [color=gray];
+	"*ctxdep.testDoodad" [shape=box];
+	"*ctxdep.testImpl";
+	"*ctxdep.testWidget" [shape=box];
+	"*ctxdep.testWidget[primary]";
+	"ctxdep.testInterface";
+	"*ctxdep.testDoodad" -> "*ctxdep.outputValue" [style=dotted];
+	"*ctxdep.testDoodad" -> "*ctxdep.testWidget";
+	"*ctxdep.testDoodad" -> "*ctxdep.testWidget[primary]";
+	"*ctxdep.testWidget" -> "*ctxdep.inputValue";
+	"*ctxdep.testWidget" -> "ctxdep.testInterface";
+	"ctxdep.testInterface" -> "*ctxdep.testImpl" [style=dashed];
+}
+`, GetDependencyContext(ctx).GraphDOT())
+}
@@ -0,0 +1,36 @@
+package ctxdep
+
+import (
+	"net/http"
+)
+
+// Middleware returns HTTP middleware that gives each request its own dependency context. For
+// every request, the dependencies are called with the request, and the results are added to a
+// new dependency context that is stored in the context of the request. The parent of the new
+// dependency context is base, so the handler can get both the request-scoped dependencies and
+// those of base:
+//
+//	base := ctxdep.GetDependencyContext(ctxdep.NewDependencyContext(ctx, db, logger))
+//	handler = ctxdep.Middleware(base, func(r *http.Request) []any {
+//	    return []any{&RequestInfo{Path: r.URL.Path}, userGenerator}
+//	})(handler)
+//
+// The context of the request is still used for its deadline, cancellation, and values. If
+// base is nil, the dependency context of the request's context, if any, is the parent. If the
+// dependencies are invalid, creating the dependency context panics, the same as with
+// NewDependencyContext.
+func Middleware(base *DependencyContext, dependencies func(*http.Request) []any) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var deps []any
+			if base != nil {
+				deps = append(deps, base.Context())
+			}
+			if dependencies != nil {
+				deps = append(deps, dependencies(r)...)
+			}
+			ctx := NewDependencyContext(r.Context(), deps...)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
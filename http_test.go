@@ -0,0 +1,42 @@
+package ctxdep
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type requestKey struct{}
+
+func Test_Middleware(t *testing.T) {
+	widget := &testWidget{Val: 42}
+	base := GetDependencyContext(NewDependencyContext(context.Background(), widget))
+
+	var gotWidget *testWidget
+	var gotInput *inputValue
+	var gotValue any
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotWidget = Get[*testWidget](r.Context())
+		gotInput = Get[*inputValue](r.Context())
+		gotValue = r.Context().Value(requestKey{})
+	})
+	wrapped := Middleware(base, func(r *http.Request) []any {
+		return []any{&inputValue{Value: r.URL.Path}}
+	})(handler)
+
+	req := httptest.NewRequest("GET", "/users", nil)
+	req = req.WithContext(context.WithValue(req.Context(), requestKey{}, "request"))
+	wrapped.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Same(t, widget, gotWidget)
+	assert.Equal(t, "/users", gotInput.Value)
+	assert.Equal(t, "request", gotValue)
+
+	// Each request gets its own dependency context.
+	wrapped.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/orders", nil))
+	assert.Equal(t, "/orders", gotInput.Value)
+	_, err := GetWithError[*inputValue](base.Context())
+	assert.Error(t, err)
+}
@@ -2,9 +2,11 @@ package ctxdep
 
 import (
 	"context"
+	"fmt"
 	"github.com/gburgyan/go-timing"
-	"log"
 	"reflect"
+	"sort"
+	"sync"
 )
 
 // immediateDependencies is an internal wrapper to signal to the DependencyContext
@@ -12,6 +14,7 @@ import (
 // Immediate() or ImmediateCtxMutator()
 type immediateDependencies struct {
 	dependencies []any
+	priority     int
 }
 
 // Immediate is used to signal the DependencyContext to call the specified generators
@@ -22,67 +25,143 @@ func Immediate(deps ...any) *immediateDependencies {
 	}
 }
 
+// ImmediatePriority is the same as Immediate, but gives the generators a priority. The
+// immediate generators are run in batches by priority, from highest to lowest, with each
+// batch waiting for the previous one to complete. Generators with the same priority are run
+// concurrently. Immediate() has a priority of zero.
+func ImmediatePriority(priority int, deps ...any) *immediateDependencies {
+	return &immediateDependencies{
+		dependencies: deps,
+		priority:     priority,
+	}
+}
+
+// ImmediateDone returns a channel that is closed once all the immediate dependencies of
+// this dependency context have been resolved. Any errors, including panics, that occurred
+// while resolving them are delivered on the channel before it is closed. If there are no
+// immediate dependencies, the channel is already closed.
+//
+// This allows for blocking until the immediate dependencies are ready, for instance during
+// the startup of a service:
+//
+//	for err := range dc.ImmediateDone() {
+//	    log.Printf("warmup failed: %v", err)
+//	}
+func (d *DependencyContext) ImmediateDone() <-chan error {
+	return d.immediateDone
+}
+
 // resolveImmediateDependencies goes through all the slots on forces the generator
 // to get run for each of the immediate slots.
 func (d *DependencyContext) resolveImmediateDependencies(ctx context.Context) {
-	// Check if there are any immediate dependencies. If not, we can return early.
-	anyImmediate := false
-	d.slots.Range(func(_, sa any) bool {
-		slot := sa.(*slot)
-		if slot.immediate != nil {
-			anyImmediate = true
-			return false
+	// Gather the immediate slots by priority. If there are none, we can return early.
+	batches := map[int][]*slot{}
+	var priorities []int
+	immediateCount := 0
+	d.slots.Range(func(key, sa any) bool {
+		s := sa.(*slot)
+		if s.immediate != nil && key.(reflect.Type) == s.slotType {
+			if _, ok := batches[s.immediate.priority]; !ok {
+				priorities = append(priorities, s.immediate.priority)
+			}
+			batches[s.immediate.priority] = append(batches[s.immediate.priority], s)
+			immediateCount++
 		}
 		return true
 	})
-	if !anyImmediate {
+	// The channel is buffered so the goroutines never block when reporting errors.
+	done := make(chan error, immediateCount)
+	d.immediateDone = done
+	if immediateCount == 0 {
+		close(done)
 		return
 	}
+	sort.Sort(sort.Reverse(sort.IntSlice(priorities)))
+	for _, batch := range batches {
+		sort.Slice(batch, func(i, j int) bool {
+			return batch[i].order < batch[j].order
+		})
+	}
 
 	var effectiveContext context.Context
 	if EnableTiming >= TimingImmediate {
 		tCtx := timing.ForName(ctx, "ImmediateDeps")
-		tCtx.Async = true
+		tCtx.Async = !d.options.synchronousImmediate
 		effectiveContext = tCtx
 	} else {
 		effectiveContext = ctx
 	}
 
-	// We can be nonchalant in calling all the slots at this time even if there
-	// are multiple slots that are created by the same generator. Whichever one
-	// gets called first will lock the slot and the other ones will block. When
-	// they eventually unblocked the dependency will already have been resolved
-	// so the generation will not get invoked again. The additional overhead is
-	// the cost of creation of the extra goroutines and the locks.
-	d.slots.Range(func(_, sa any) bool {
-		slot := sa.(*slot)
-		if slot.immediate != nil {
-			go func() {
-				defer func() {
-					// Catch panics
-					if r := recover(); r != nil {
-						// The best we can do is ignore this for now since we're
-						// inside nested goroutines and the original call has returned.
-						// By ignoring this error now, the dependency remains unset
-						// and the call to fetch it will retry the call and either
-						// succeed or (likely) fail again. The new failure will at
-						// least be in a better place to report this though.
-						log.Printf("panic resolving immediate dependency for %v: %v", slot.slotType, r)
-					}
-				}()
-				target := reflect.New(slot.slotType)
-				err := d.getValue(effectiveContext, slot, slot.slotType, target.Interface())
-				if err != nil {
-					// The best we can do is ignore this for now since we're
-					// inside nested goroutines and the original call has returned.
-					// By ignoring this error now, the dependency remains unset
-					// and the call to fetch it will retry the call and either
-					// succeed or (likely) fail again. The new failure will at
-					// least be in a better place to report this though.
-					log.Printf("error resolving immediate dependency: %v", err)
-				}
-			}()
+	if d.options.synchronousImmediate {
+		defer close(done)
+		for _, priority := range priorities {
+			for _, s := range batches[priority] {
+				d.resolveImmediateSlot(effectiveContext, s, done)
+			}
 		}
-		return true
-	})
+		return
+	}
+
+	go func() {
+		// We can be nonchalant in calling all the slots at this time even if there
+		// are multiple slots that are created by the same generator. Whichever one
+		// gets called first will lock the slot and the other ones will block. When
+		// they eventually unblocked the dependency will already have been resolved
+		// so the generation will not get invoked again. The additional overhead is
+		// the cost of creation of the extra goroutines and the locks.
+		for _, priority := range priorities {
+			wg := sync.WaitGroup{}
+			for _, s := range batches[priority] {
+				wg.Add(1)
+				go func(s *slot) {
+					defer wg.Done()
+					d.resolveImmediateSlot(effectiveContext, s, done)
+				}(s)
+			}
+			wg.Wait()
+		}
+		close(done)
+	}()
+}
+
+// resolveImmediateSlot runs the generator for an immediate slot and reports any errors on
+// the done channel.
+func (d *DependencyContext) resolveImmediateSlot(ctx context.Context, slot *slot, done chan<- error) {
+	// failure is what was reported, which is what a synchronous resolution
+	// panics with once reporting is done.
+	var failure any
+	defer func() {
+		// Catch panics
+		if r := recover(); r != nil {
+			// In the background, the best we can do is report this since
+			// the original call has returned. The dependency remains unset
+			// and the call to fetch it will retry the call and either
+			// succeed or (likely) fail again. With synchronous immediate
+			// resolution, the panic continues out of NewDependencyContext.
+			err := fmt.Errorf("panic resolving immediate dependency for %v: %v", slot.slotType, r)
+			if d.options.immediateErrorHandler != nil {
+				d.options.immediateErrorHandler(slot.slotType, err)
+			} else {
+				logf("%v", err)
+			}
+			done <- err
+			failure = r
+		}
+		if failure != nil && d.options.synchronousImmediate {
+			panic(failure)
+		}
+	}()
+	target := reflect.New(slot.slotType)
+	err := d.getValue(ctx, slot, slot.slotType, target.Interface())
+	if err != nil {
+		// As with a panic, a background failure can only be reported
+		// and the dependency remains unset to be retried when fetched.
+		if d.options.immediateErrorHandler != nil {
+			d.options.immediateErrorHandler(slot.slotType, err)
+		} else {
+			logf("error resolving immediate dependency: %v", err)
+		}
+		done <- err
+		failure = err
+	}
 }
@@ -5,42 +5,46 @@ import (
 	"fmt"
 	"github.com/gburgyan/go-timing"
 	"github.com/stretchr/testify/assert"
+	"log"
+	"reflect"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
 
 func Test_ImmediateDependency(t *testing.T) {
-	callCount := 0
+	var callCount int32
 	f := func() *testWidget {
-		callCount++
+		atomic.AddInt32(&callCount, 1)
 		return &testWidget{Val: 42}
 	}
 
-	assert.Equal(t, 0, callCount)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&callCount))
 
 	ctx := NewDependencyContext(context.Background(), Immediate(f))
 
 	// Wait a bit to ensure the goroutine completes.
 	time.Sleep(100 * time.Millisecond)
 
-	assert.Equal(t, 1, callCount)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&callCount))
 
 	var widget *testWidget
 	GetBatch(ctx, &widget)
 
 	assert.Equal(t, 42, widget.Val)
-	assert.Equal(t, 1, callCount)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&callCount))
 }
 
 func Test_ImmediateDependency_LongCall(t *testing.T) {
-	callCount := 0
+	var callCount int32
 	f := func() *testWidget {
-		callCount++
+		atomic.AddInt32(&callCount, 1)
 		time.Sleep(100 * time.Millisecond)
 		return &testWidget{Val: 42}
 	}
 
-	assert.Equal(t, 0, callCount)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&callCount))
 
 	EnableTiming = TimingGenerators
 	timingCtx := timing.Root(context.Background())
@@ -59,27 +63,30 @@ func Test_ImmediateDependency_LongCall(t *testing.T) {
 	d := time.Since(start)
 
 	assert.Equal(t, 42, widget.Val)
-	assert.Equal(t, 1, callCount)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&callCount))
 	assert.InEpsilon(t, 50*time.Millisecond, d, .1)
 
+	// Wait for the immediate goroutine to finish its timing before printing it.
+	for range GetDependencyContext(ctx).ImmediateDone() {
+	}
 	fmt.Println(timingCtx.String())
 }
 
 func Test_ImmediateDependency_Error(t *testing.T) {
-	callCount := 0
+	var callCount int32
 	f := func() (*testWidget, error) {
-		callCount++
+		atomic.AddInt32(&callCount, 1)
 		return nil, fmt.Errorf("expected error")
 	}
 
-	assert.Equal(t, 0, callCount)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&callCount))
 
 	ctx := NewDependencyContext(context.Background(), Immediate(f))
 
 	// Wait a bit to ensure the goroutine completes.
 	time.Sleep(100 * time.Millisecond)
 
-	assert.Equal(t, 1, callCount)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&callCount))
 
 	var widget *testWidget
 	assert.Panics(t, func() {
@@ -87,24 +94,24 @@ func Test_ImmediateDependency_Error(t *testing.T) {
 	})
 
 	assert.Nil(t, widget)
-	assert.Equal(t, 2, callCount)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&callCount))
 }
 
 func Test_ImmediateDependency_Panic(t *testing.T) {
-	callCount := 0
+	var callCount int32
 	f := func() (*testWidget, error) {
-		callCount++
+		atomic.AddInt32(&callCount, 1)
 		panic("expected panic")
 	}
 
-	assert.Equal(t, 0, callCount)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&callCount))
 
 	ctx := NewDependencyContext(context.Background(), Immediate(f))
 
 	// Wait a bit to ensure the goroutine completes.
 	time.Sleep(100 * time.Millisecond)
 
-	assert.Equal(t, 1, callCount)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&callCount))
 
 	var widget *testWidget
 	assert.Panics(t, func() {
@@ -112,5 +119,175 @@ func Test_ImmediateDependency_Panic(t *testing.T) {
 	})
 
 	assert.Nil(t, widget)
-	assert.Equal(t, 2, callCount)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&callCount))
+}
+
+func Test_ImmediateDone(t *testing.T) {
+	f1 := func() *testWidget {
+		time.Sleep(50 * time.Millisecond)
+		return &testWidget{Val: 42}
+	}
+	f2 := func() (*testDoodad, error) {
+		return nil, fmt.Errorf("expected error")
+	}
+
+	ctx := NewDependencyContext(context.Background(), Immediate(f1, f2))
+	dc := GetDependencyContext(ctx)
+
+	var errs []error
+	for err := range dc.ImmediateDone() {
+		errs = append(errs, err)
+	}
+
+	assert.Len(t, errs, 1)
+	assert.EqualError(t, errs[0], "error running generator: *ctxdep.testDoodad (expected error)")
+	assert.Equal(t, "*ctxdep.testDoodad - uninitialized - generator: () *ctxdep.testDoodad, error\n*ctxdep.testWidget - created from generator: () *ctxdep.testWidget", dc.Status())
+}
+
+func Test_ImmediateDone_Panic(t *testing.T) {
+	f := func() *testWidget {
+		panic("expected panic")
+	}
+
+	ctx := NewDependencyContext(context.Background(), Immediate(f))
+
+	var errs []error
+	for err := range GetDependencyContext(ctx).ImmediateDone() {
+		errs = append(errs, err)
+	}
+
+	assert.Len(t, errs, 1)
+	assert.EqualError(t, errs[0], "panic resolving immediate dependency for *ctxdep.testWidget: expected panic")
+}
+
+func Test_ImmediateDone_NoImmediate(t *testing.T) {
+	ctx := NewDependencyContext(context.Background(), &testWidget{Val: 42})
+
+	_, open := <-GetDependencyContext(ctx).ImmediateDone()
+	assert.False(t, open)
+}
+
+func Test_ImmediatePriority(t *testing.T) {
+	var lock sync.Mutex
+	var order []string
+	record := func(name string) {
+		lock.Lock()
+		defer lock.Unlock()
+		order = append(order, name)
+	}
+
+	ctx := NewDependencyContext(context.Background(),
+		Immediate(func() *testWidget {
+			record("low")
+			return &testWidget{}
+		}),
+		ImmediatePriority(10, func() *testDoodad {
+			time.Sleep(20 * time.Millisecond)
+			record("high")
+			return &testDoodad{}
+		}),
+		ImmediatePriority(5, func() *testImpl {
+			time.Sleep(10 * time.Millisecond)
+			record("medium")
+			return &testImpl{}
+		}, func() *outputValue {
+			time.Sleep(10 * time.Millisecond)
+			record("medium")
+			return &outputValue{}
+		}))
+
+	for err := range GetDependencyContext(ctx).ImmediateDone() {
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, []string{"high", "medium", "medium", "low"}, order)
+}
+
+func Test_WithImmediateErrorHandler(t *testing.T) {
+	logger := &testLogger{}
+	SetLogger(logger)
+	defer SetLogger(log.Default())
+
+	var lock sync.Mutex
+	handled := map[reflect.Type]string{}
+	handler := func(slotType reflect.Type, err error) {
+		lock.Lock()
+		defer lock.Unlock()
+		handled[slotType] = err.Error()
+	}
+
+	ctx := NewDependencyContext(context.Background(), WithImmediateErrorHandler(handler), Immediate(func() (*testWidget, error) {
+		return nil, fmt.Errorf("expected error")
+	}, func() *testDoodad {
+		panic("expected panic")
+	}))
+	errCount := 0
+	for range GetDependencyContext(ctx).ImmediateDone() {
+		errCount++
+	}
+
+	assert.Equal(t, 2, errCount)
+	assert.Equal(t, map[reflect.Type]string{
+		reflect.TypeOf(&testWidget{}): "error running generator: *ctxdep.testWidget (expected error)",
+		reflect.TypeOf(&testDoodad{}): "panic resolving immediate dependency for *ctxdep.testDoodad: expected panic",
+	}, handled)
+	assert.Empty(t, logger.getMessages())
+}
+
+func Test_WithSynchronousImmediate(t *testing.T) {
+	var order []string
+	ctx := NewDependencyContext(context.Background(), WithSynchronousImmediate(),
+		Immediate(func() *testWidget {
+			order = append(order, "widget")
+			return &testWidget{Val: 42}
+		}, func() *testImpl {
+			order = append(order, "impl")
+			return &testImpl{}
+		}),
+		ImmediatePriority(1, func() *testDoodad {
+			order = append(order, "doodad")
+			return &testDoodad{}
+		}),
+	)
+
+	// Everything has run before the dependency context is returned.
+	assert.Equal(t, []string{"doodad", "widget", "impl"}, order)
+	dc := GetDependencyContext(ctx)
+	assert.Contains(t, dc.Status(), "*ctxdep.testWidget - created from generator")
+
+	for err := range dc.ImmediateDone() {
+		assert.NoError(t, err)
+	}
+}
+
+func Test_WithSynchronousImmediate_Failures(t *testing.T) {
+	logger := &testLogger{}
+	SetLogger(logger)
+	defer SetLogger(log.Default())
+
+	ran := false
+	assert.PanicsWithError(t, "error running generator: *ctxdep.testDoodad (expected error)", func() {
+		NewDependencyContext(context.Background(), WithSynchronousImmediate(),
+			Immediate(func() (*testDoodad, error) {
+				return nil, fmt.Errorf("expected error")
+			}, func() *testWidget {
+				ran = true
+				return &testWidget{}
+			}),
+		)
+	})
+	assert.False(t, ran)
+
+	assert.PanicsWithValue(t, "expected panic", func() {
+		NewDependencyContext(context.Background(), WithSynchronousImmediate(),
+			Immediate(func() *testDoodad {
+				panic("expected panic")
+			}),
+		)
+	})
+
+	// Both failures are still reported before the panic.
+	assert.Equal(t, []string{
+		"error resolving immediate dependency: error running generator: *ctxdep.testDoodad (expected error)",
+		"panic resolving immediate dependency for *ctxdep.testDoodad: expected panic",
+	}, logger.getMessages())
 }
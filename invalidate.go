@@ -0,0 +1,47 @@
+package ctxdep
+
+import (
+	"context"
+	"reflect"
+)
+
+// Invalidate clears the value that a generator made for type T in the dependency context
+// of ctx, so that the next request for T runs the generator again. See
+// DependencyContext.Invalidate for details.
+func Invalidate[T any](ctx context.Context) error {
+	return GetDependencyContext(ctx).Invalidate(reflect.TypeOf((*T)(nil)).Elem())
+}
+
+// Invalidate clears the value that a generator made for the type, so that the next request
+// for it runs the generator again. If the generator makes more than one type, only the value
+// for this type is regenerated; the others keep their values. Only values that were made by a
+// generator of this dependency context can be invalidated. If the slot holds a direct value,
+// or a value imported from a parent dependency context, an error is returned. Invalidating a
+// generator that hasn't run yet does nothing.
+//
+// The value is cleared atomically while holding the lock of the slot, so a concurrent
+// request either gets the old value or waits for the generator to make a new one. Child
+// dependency contexts that have already imported the old value keep it.
+func (d *DependencyContext) Invalidate(t reflect.Type) error {
+	sa, ok := d.slots.Load(t)
+	if !ok {
+		return &DependencyError{
+			Message:        "slot not found for requested type",
+			ReferencedType: t,
+			Status:         d.Status(),
+		}
+	}
+	s := sa.(*slot)
+	if s.status != StatusGenerator || s.generator == nil {
+		return &DependencyError{
+			Message:        "only values from generators can be invalidated",
+			ReferencedType: t,
+			Status:         d.Status(),
+		}
+	}
+	lock := s.runLock()
+	lock.Lock()
+	defer lock.Unlock()
+	s.storeValue(nil)
+	return nil
+}
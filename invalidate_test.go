@@ -0,0 +1,66 @@
+package ctxdep
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func Test_Invalidate(t *testing.T) {
+	var calls int32
+	ctx := NewDependencyContext(context.Background(), func() (*testWidget, *testDoodad) {
+		n := atomic.AddInt32(&calls, 1)
+		return &testWidget{Val: int(n)}, &testDoodad{Val: "doodad"}
+	})
+
+	// Invalidating before the generator runs does nothing.
+	assert.NoError(t, Invalidate[*testWidget](ctx))
+	assert.Equal(t, 1, Get[*testWidget](ctx).Val)
+	doodad := Get[*testDoodad](ctx)
+
+	assert.NoError(t, Invalidate[*testWidget](ctx))
+	assert.Equal(t, "*ctxdep.testDoodad - created from generator: () *ctxdep.testWidget, *ctxdep.testDoodad\n*ctxdep.testWidget - uninitialized - generator: () *ctxdep.testWidget, *ctxdep.testDoodad", Status(ctx))
+	assert.Equal(t, 2, Get[*testWidget](ctx).Val)
+	assert.Same(t, doodad, Get[*testDoodad](ctx))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func Test_Invalidate_Concurrent(t *testing.T) {
+	ctx := NewDependencyContext(context.Background(), func() *testWidget {
+		return &testWidget{Val: 42}
+	})
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			assert.Equal(t, 42, Get[*testWidget](ctx).Val)
+		}()
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, Invalidate[*testWidget](ctx))
+		}()
+	}
+	wg.Wait()
+}
+
+func Test_Invalidate_Errors(t *testing.T) {
+	parent := NewDependencyContext(context.Background(), &testImpl{val: 1})
+	ctx := NewDependencyContext(parent, &testWidget{Val: 42}, func(i *testImpl) *testDoodad {
+		return &testDoodad{}
+	})
+	_ = Get[*testDoodad](ctx)
+
+	err := Invalidate[*testWidget](ctx)
+	assert.ErrorContains(t, err, "only values from generators can be invalidated: *ctxdep.testWidget")
+
+	err = GetDependencyContext(ctx).Invalidate(reflect.TypeOf(&testImpl{}))
+	assert.ErrorContains(t, err, "only values from generators can be invalidated: *ctxdep.testImpl")
+
+	err = Invalidate[*inputValue](ctx)
+	assert.ErrorContains(t, err, "slot not found for requested type: *ctxdep.inputValue")
+}
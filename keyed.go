@@ -0,0 +1,152 @@
+package ctxdep
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// keyedSlotKey is the key for slots that are registered with a name in addition to the type.
+type keyedSlotKey struct {
+	slotType reflect.Type
+	name     string
+}
+
+// keyedDependency is an internal wrapper to signal to the DependencyContext that the value
+// should be stored under both its type and a name. This is created by Keyed().
+type keyedDependency struct {
+	key   keyedSlotKey
+	value any
+}
+
+// Keyed registers a value under the type T along with a name. This allows for multiple
+// dependencies of the same type to be in a dependency context, such as a primary and a
+// replica database connection:
+//
+//	ctx = NewDependencyContext(ctx, Keyed("primary", primaryDB), Keyed("replica", replicaDB))
+//
+// Keyed dependencies are only found by GetKeyed or by generators that take a parameter
+// that embeds KeyedParam. They are not found by a regular Get for T.
+func Keyed[T any](name string, value T) *keyedDependency {
+	return &keyedDependency{
+		key: keyedSlotKey{
+			slotType: reflect.TypeOf((*T)(nil)).Elem(),
+			name:     name,
+		},
+		value: value,
+	}
+}
+
+// KeyedParam allows a generator to request a keyed dependency as a parameter. A generator
+// can't name the dependency it needs in its signature, so a type is declared for each name
+// that embeds KeyedParam and returns the name from a DependencyKey method:
+//
+//	type ReplicaDB struct {
+//	    ctxdep.KeyedParam[*sql.DB]
+//	}
+//
+//	func (ReplicaDB) DependencyKey() string { return "replica" }
+//
+//	func ReportGenerator(db ReplicaDB) *Report {
+//	    return runReport(db.Value)
+//	}
+type KeyedParam[T any] struct {
+	// Value is the keyed dependency that was found.
+	Value T
+}
+
+func (k *KeyedParam[T]) keyedType() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+func (k *KeyedParam[T]) setKeyedValue(value any) {
+	k.Value = value.(T)
+}
+
+// keyedParameter is implemented by types that embed KeyedParam and provide a DependencyKey.
+type keyedParameter interface {
+	DependencyKey() string
+	keyedType() reflect.Type
+	setKeyedValue(value any)
+}
+
+var keyedParameterType = reflect.TypeOf((*keyedParameter)(nil)).Elem()
+
+// isKeyedParameter returns if the generator parameter type is a request for a keyed dependency.
+func isKeyedParameter(paramType reflect.Type) bool {
+	return paramType.Kind() == reflect.Struct && reflect.PointerTo(paramType).Implements(keyedParameterType)
+}
+
+// keyedParameterKey returns the slot key that a keyed parameter type is requesting along
+// with a new pointer to the parameter that can be filled in.
+func keyedParameterKey(paramType reflect.Type) (keyedSlotKey, reflect.Value) {
+	paramPointer := reflect.New(paramType)
+	kp := paramPointer.Interface().(keyedParameter)
+	return keyedSlotKey{slotType: kp.keyedType(), name: kp.DependencyKey()}, paramPointer
+}
+
+// GetKeyed returns the value of type T that was registered with the name using Keyed. If
+// it's not found, this panics.
+func GetKeyed[T any](ctx context.Context, name string) T {
+	result, err := GetKeyedWithError[T](ctx, name)
+	if err != nil {
+		panic(err)
+	}
+	return result
+}
+
+// GetKeyedWithError returns the value of type T that was registered with the name using
+// Keyed, or an error if it's not found.
+func GetKeyedWithError[T any](ctx context.Context, name string) (T, error) {
+	dc := GetDependencyContext(ctx)
+	key := keyedSlotKey{
+		slotType: reflect.TypeOf((*T)(nil)).Elem(),
+		name:     name,
+	}
+	var result T
+	value, err := dc.getKeyedValue(key)
+	if err != nil {
+		return result, err
+	}
+	return value.(T), nil
+}
+
+// addKeyedValue adds a keyed dependency to this dependency context.
+func (d *DependencyContext) addKeyedValue(keyed *keyedDependency) {
+	v := reflect.ValueOf(keyed.value)
+	if !v.IsValid() || ((v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && v.IsNil()) {
+		panic(fmt.Sprintf("invalid nil value dependency for type %v with key %q", keyed.key.slotType, keyed.key.name))
+	}
+	if _, existing := d.keyedSlots.Load(keyed.key); existing && !d.loose {
+		panic(fmt.Sprintf("a slot for type %v with key %q already exists--value may not override an existing slot", keyed.key.slotType, keyed.key.name))
+	}
+	d.keyedSlots.Store(keyed.key, newValueSlot(keyed.key.slotType, keyed.value, StatusDirect))
+}
+
+// getKeyedValue finds the keyed dependency in this, or a parent, dependency context.
+func (d *DependencyContext) getKeyedValue(key keyedSlotKey) (any, error) {
+	if sa, ok := d.keyedSlots.Load(key); ok {
+		return sa.(*slot).loadValue(), nil
+	}
+	pdc := d.parentDependencyContext()
+	if pdc != nil {
+		return pdc.getKeyedValue(key)
+	}
+	return nil, &DependencyError{
+		Message:        fmt.Sprintf("slot not found for requested key %q", key.name),
+		ReferencedType: key.slotType,
+		Status:         d.Status(),
+	}
+}
+
+// hasKeyedDependency returns if this, or a parent, dependency context has the keyed dependency.
+func (d *DependencyContext) hasKeyedDependency(key keyedSlotKey) bool {
+	if _, ok := d.keyedSlots.Load(key); ok {
+		return true
+	}
+	pdc := d.parentDependencyContext()
+	if pdc != nil {
+		return pdc.hasKeyedDependency(key)
+	}
+	return false
+}
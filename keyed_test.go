@@ -0,0 +1,86 @@
+package ctxdep
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type primaryWidget struct {
+	KeyedParam[*testWidget]
+}
+
+func (primaryWidget) DependencyKey() string { return "primary" }
+
+type replicaWidget struct {
+	KeyedParam[*testWidget]
+}
+
+func (replicaWidget) DependencyKey() string { return "replica" }
+
+func Test_Keyed(t *testing.T) {
+	ctx := NewDependencyContext(context.Background(),
+		Keyed("primary", &testWidget{Val: 1}),
+		Keyed("replica", &testWidget{Val: 2}),
+		&testWidget{Val: 3})
+
+	assert.Equal(t, 1, GetKeyed[*testWidget](ctx, "primary").Val)
+	assert.Equal(t, 2, GetKeyed[*testWidget](ctx, "replica").Val)
+	assert.Equal(t, 3, Get[*testWidget](ctx).Val)
+
+	_, err := GetKeyedWithError[*testWidget](ctx, "missing")
+	assert.EqualError(t, err, "slot not found for requested key \"missing\": *ctxdep.testWidget")
+
+	assert.Equal(t, "*ctxdep.testWidget - direct value set\n*ctxdep.testWidget[primary] - direct value set\n*ctxdep.testWidget[replica] - direct value set", Status(ctx))
+}
+
+func Test_Keyed_Interface(t *testing.T) {
+	ctx := NewDependencyContext(context.Background(), Keyed[testInterface]("impl", &testImpl{val: 42}))
+
+	assert.Equal(t, 42, GetKeyed[testInterface](ctx, "impl").getVal())
+	assert.Equal(t, "ctxdep.testInterface[impl] - direct value set", Status(ctx))
+}
+
+func Test_Keyed_Parent(t *testing.T) {
+	parent := NewDependencyContext(context.Background(), Keyed("primary", &testWidget{Val: 1}))
+	child := NewDependencyContext(parent, Keyed("replica", &testWidget{Val: 2}))
+
+	assert.Equal(t, 1, GetKeyed[*testWidget](child, "primary").Val)
+	assert.Equal(t, 2, GetKeyed[*testWidget](child, "replica").Val)
+}
+
+func Test_Keyed_GeneratorParam(t *testing.T) {
+	ctx := NewDependencyContext(context.Background(),
+		Keyed("primary", &testWidget{Val: 1}),
+		Keyed("replica", &testWidget{Val: 2}),
+		func(p primaryWidget, r replicaWidget) *testDoodad {
+			return &testDoodad{Val: fmt.Sprintf("%d-%d", p.Value.Val, r.Value.Val)}
+		})
+
+	assert.Equal(t, "1-2", Get[*testDoodad](ctx).Val)
+}
+
+func Test_Keyed_GeneratorParam_Missing(t *testing.T) {
+	assert.PanicsWithError(t, "generator for (ctxdep.replicaWidget) *ctxdep.testDoodad has dependencies that cannot be resolved", func() {
+		NewDependencyContext(context.Background(),
+			Keyed("primary", &testWidget{Val: 1}),
+			func(r replicaWidget) *testDoodad {
+				return &testDoodad{}
+			})
+	})
+}
+
+func Test_Keyed_Duplicate(t *testing.T) {
+	assert.PanicsWithValue(t, "a slot for type *ctxdep.testWidget with key \"primary\" already exists--value may not override an existing slot", func() {
+		NewDependencyContext(context.Background(), Keyed("primary", &testWidget{Val: 1}), Keyed("primary", &testWidget{Val: 2}))
+	})
+
+	ctx := NewLooseDependencyContext(context.Background(), Keyed("primary", &testWidget{Val: 1}), Keyed("primary", &testWidget{Val: 2}))
+	assert.Equal(t, 2, GetKeyed[*testWidget](ctx, "primary").Val)
+
+	var nilWidget *testWidget
+	assert.PanicsWithValue(t, "invalid nil value dependency for type *ctxdep.testWidget with key \"primary\"", func() {
+		NewDependencyContext(context.Background(), Keyed("primary", nilWidget))
+	})
+}
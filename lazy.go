@@ -0,0 +1,42 @@
+package ctxdep
+
+import (
+	"sync"
+)
+
+// Lazy returns a generator for a value of type T that is constructed by calling f the first
+// time it's needed. Unlike an ordinary generator, f is called at most once no matter how many
+// dependency contexts the generator is added to, so this is suited for expensive singletons
+// that should only be built if they're used:
+//
+//	var searchIndex = ctxdep.Lazy(func() *SearchIndex { return LoadSearchIndex() })
+//
+//	ctx = ctxdep.NewDependencyContext(ctx, searchIndex)
+//
+// f takes no context or parameters, since the value is shared by every dependency context.
+// If f panics, it is not called again, and every later call panics with the same value.
+func Lazy[T any](f func() T) func() T {
+	if f == nil {
+		panic("Lazy requires a function")
+	}
+	var once sync.Once
+	var value T
+	var panicked bool
+	var panicValue any
+	return func() T {
+		once.Do(func() {
+			defer func() {
+				if r := recover(); r != nil {
+					panicked = true
+					panicValue = r
+					panic(r)
+				}
+			}()
+			value = f()
+		})
+		if panicked {
+			panic(panicValue)
+		}
+		return value
+	}
+}
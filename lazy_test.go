@@ -0,0 +1,41 @@
+package ctxdep
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_Lazy(t *testing.T) {
+	calls := 0
+	lazy := Lazy(func() *testWidget {
+		calls++
+		return &testWidget{Val: 42}
+	})
+
+	ctx1 := NewDependencyContext(context.Background(), lazy)
+	ctx2 := NewDependencyContext(context.Background(), lazy)
+	assert.Equal(t, 0, calls)
+
+	w1 := Get[*testWidget](ctx1)
+	w2 := Get[*testWidget](ctx2)
+	assert.Equal(t, 42, w1.Val)
+	assert.Same(t, w1, w2)
+	assert.Equal(t, 1, calls)
+
+	assert.PanicsWithValue(t, "Lazy requires a function", func() {
+		Lazy[*testWidget](nil)
+	})
+}
+
+func Test_Lazy_Panic(t *testing.T) {
+	calls := 0
+	lazy := Lazy(func() *testWidget {
+		calls++
+		panic("expected panic")
+	})
+
+	assert.PanicsWithValue(t, "expected panic", func() { lazy() })
+	assert.PanicsWithValue(t, "expected panic", func() { lazy() })
+	assert.Equal(t, 1, calls)
+}
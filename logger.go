@@ -0,0 +1,39 @@
+package ctxdep
+
+import (
+	"log"
+	"sync/atomic"
+)
+
+// Logger is the interface used to write diagnostic messages from places where errors
+// cannot be returned to a caller, such as background cache refreshes and immediate
+// dependency resolution. The standard library's *log.Logger implements this interface.
+type Logger interface {
+	Printf(format string, args ...any)
+}
+
+// loggerHolder wraps the Logger so that atomic.Value always stores the same concrete type.
+type loggerHolder struct {
+	logger Logger
+}
+
+var currentLogger atomic.Value
+
+func init() {
+	currentLogger.Store(loggerHolder{logger: log.Default()})
+}
+
+// SetLogger sets the Logger that is used for diagnostic messages. By default, the standard
+// library's logger is used. Passing nil disables the diagnostic messages altogether. This
+// is safe to call concurrently with dependency resolution.
+func SetLogger(logger Logger) {
+	currentLogger.Store(loggerHolder{logger: logger})
+}
+
+// logf writes a diagnostic message to the current Logger, if there is one.
+func logf(format string, args ...any) {
+	holder := currentLogger.Load().(loggerHolder)
+	if holder.logger != nil {
+		holder.logger.Printf(format, args...)
+	}
+}
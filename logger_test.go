@@ -0,0 +1,51 @@
+package ctxdep
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"log"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testLogger struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (l *testLogger) Printf(format string, args ...any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.messages = append(l.messages, fmt.Sprintf(format, args...))
+}
+
+func (l *testLogger) getMessages() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string{}, l.messages...)
+}
+
+func Test_SetLogger(t *testing.T) {
+	logger := &testLogger{}
+	SetLogger(logger)
+	defer SetLogger(log.Default())
+
+	f := func() (*testWidget, error) {
+		return nil, fmt.Errorf("expected error")
+	}
+	_ = NewDependencyContext(context.Background(), Immediate(f))
+	time.Sleep(100 * time.Millisecond)
+
+	assert.Equal(t, []string{"error resolving immediate dependency: error running generator: *ctxdep.testWidget (expected error)"}, logger.getMessages())
+}
+
+func Test_SetLogger_Nil(t *testing.T) {
+	SetLogger(nil)
+	defer SetLogger(log.Default())
+
+	assert.NotPanics(t, func() {
+		logf("this goes nowhere: %d", 42)
+	})
+}
@@ -0,0 +1,197 @@
+package ctxdep
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryCache is a concurrency-safe, in-memory implementation of the Cache interface
+// that is bounded in size. When more than the maximum number of entries are stored,
+// the least recently used entry is evicted.
+//
+// Entries expire based on the saved time and TTL that CachedOpts stores at the end of
+// the cached values. If an entry does not have this information, the TTL passed to
+// SetTTL is used instead.
+type MemoryCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	entries    map[string]*list.Element
+	lru        *list.List
+
+	// tags holds the keys of the entries for each tag.
+	tags map[string]map[string]bool
+
+	// now is used for testing purposes to override the current time.
+	now func() time.Time
+}
+
+// memoryCacheEntry is what is stored in the LRU list of the MemoryCache.
+type memoryCacheEntry struct {
+	key       string
+	value     []any
+	expiresAt time.Time
+	tags      []string
+}
+
+// NewMemoryCache creates a new MemoryCache that holds at most maxEntries entries. If
+// maxEntries is 0 or less, the number of entries is not bounded and entries are only
+// removed when they expire.
+func NewMemoryCache(maxEntries int) *MemoryCache {
+	return &MemoryCache{
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+		now:        time.Now,
+	}
+}
+
+// Get returns the value for the given key, or nil if the key is not found or if the
+// entry has expired. The returned slice is a copy of what is stored.
+func (m *MemoryCache) Get(_ context.Context, key string) []any {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	elem, ok := m.entries[key]
+	if !ok {
+		return nil
+	}
+	entry := elem.Value.(*memoryCacheEntry)
+	if m.isExpired(entry) {
+		m.removeElement(elem)
+		return nil
+	}
+	m.lru.MoveToFront(elem)
+	return copyCacheValues(entry.value)
+}
+
+// SetTTL sets the value for the given key. If the TTL is 0, the key will not expire,
+// but it may still get evicted if the cache is full.
+func (m *MemoryCache) SetTTL(ctx context.Context, key string, value []any, ttl time.Duration) {
+	m.SetTTLTagged(ctx, key, value, ttl, nil)
+}
+
+// SetTTLTagged is the same as SetTTL, but also indexes the entry under each of the tags so
+// that it can be removed with DeleteByTag.
+func (m *MemoryCache) SetTTLTagged(_ context.Context, key string, value []any, ttl time.Duration, tags []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry := &memoryCacheEntry{
+		key:   key,
+		value: copyCacheValues(value),
+		tags:  tags,
+	}
+	if ttl > 0 {
+		entry.expiresAt = m.now().Add(ttl)
+	}
+
+	if elem, ok := m.entries[key]; ok {
+		m.removeTags(elem.Value.(*memoryCacheEntry))
+		elem.Value = entry
+		m.addTags(entry)
+		m.lru.MoveToFront(elem)
+		return
+	}
+	m.entries[key] = m.lru.PushFront(entry)
+	m.addTags(entry)
+
+	for m.maxEntries > 0 && m.lru.Len() > m.maxEntries {
+		m.removeElement(m.lru.Back())
+	}
+}
+
+// DeleteByTag removes all the entries that were set with the tag.
+func (m *MemoryCache) DeleteByTag(_ context.Context, tag string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key := range m.tags[tag] {
+		if elem, ok := m.entries[key]; ok {
+			m.removeElement(elem)
+		}
+	}
+}
+
+// Export returns copies of all the entries of the cache that haven't expired, keyed by their
+// cache keys. This is used by CacheSnapshot.
+func (m *MemoryCache) Export() map[string][]any {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := make(map[string][]any, len(m.entries))
+	for key, elem := range m.entries {
+		entry := elem.Value.(*memoryCacheEntry)
+		if !m.isExpired(entry) {
+			result[key] = copyCacheValues(entry.value)
+		}
+	}
+	return result
+}
+
+// Import adds all the entries to the cache, as with SetTTL with a TTL of 0. Entries that were
+// saved by CachedOpts still expire based on the saved time and TTL at the end of their values.
+// The tags of entries are not part of a snapshot, so imported entries have no tags. This is
+// used by RestoreCacheSnapshot.
+func (m *MemoryCache) Import(entries map[string][]any) {
+	for key, value := range entries {
+		m.SetTTL(context.Background(), key, value, 0)
+	}
+}
+
+// Len returns the number of entries in the cache, including any that have expired but
+// have not yet been removed.
+func (m *MemoryCache) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lru.Len()
+}
+
+// isExpired checks if the entry has expired. The saved time and TTL that CachedOpts
+// stores at the end of the values take precedence over the TTL passed to SetTTL.
+func (m *MemoryCache) isExpired(entry *memoryCacheEntry) bool {
+	now := m.now()
+	if savedTime, ttl, ok := cacheValueExpiry(entry.value); ok {
+		return ttl > 0 && now.After(savedTime.Add(ttl))
+	}
+	return !entry.expiresAt.IsZero() && now.After(entry.expiresAt)
+}
+
+// removeElement removes the element from both the LRU list and the map of entries.
+func (m *MemoryCache) removeElement(elem *list.Element) {
+	entry := m.lru.Remove(elem).(*memoryCacheEntry)
+	delete(m.entries, entry.key)
+	m.removeTags(entry)
+}
+
+// addTags indexes the entry under each of its tags.
+func (m *MemoryCache) addTags(entry *memoryCacheEntry) {
+	for _, tag := range entry.tags {
+		if m.tags == nil {
+			m.tags = map[string]map[string]bool{}
+		}
+		if m.tags[tag] == nil {
+			m.tags[tag] = map[string]bool{}
+		}
+		m.tags[tag][entry.key] = true
+	}
+}
+
+// removeTags removes the entry from the index of each of its tags.
+func (m *MemoryCache) removeTags(entry *memoryCacheEntry) {
+	for _, tag := range entry.tags {
+		delete(m.tags[tag], entry.key)
+		if len(m.tags[tag]) == 0 {
+			delete(m.tags, tag)
+		}
+	}
+}
+
+// copyCacheValues returns a copy of the slice so that the cache does not alias the
+// slice of the caller. The values themselves are not copied.
+func copyCacheValues(values []any) []any {
+	result := make([]any, len(values))
+	copy(result, values)
+	return result
+}
@@ -0,0 +1,145 @@
+package ctxdep
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_MemoryCache_GetSet(t *testing.T) {
+	cache := NewMemoryCache(10)
+	ctx := context.Background()
+
+	assert.Nil(t, cache.Get(ctx, "missing"))
+
+	value := []any{&testWidget{Val: 42}}
+	cache.SetTTL(ctx, "key", value, time.Minute)
+
+	result := cache.Get(ctx, "key")
+	assert.Len(t, result, 1)
+	assert.Equal(t, 42, result[0].(*testWidget).Val)
+
+	// Modifying the slices do not affect the stored values.
+	value[0] = &testWidget{Val: 105}
+	result[0] = &testWidget{Val: 105}
+	assert.Equal(t, 42, cache.Get(ctx, "key")[0].(*testWidget).Val)
+}
+
+func Test_MemoryCache_Eviction(t *testing.T) {
+	cache := NewMemoryCache(2)
+	ctx := context.Background()
+
+	cache.SetTTL(ctx, "a", []any{1}, 0)
+	cache.SetTTL(ctx, "b", []any{2}, 0)
+
+	// Touch "a" so "b" is the least recently used.
+	assert.NotNil(t, cache.Get(ctx, "a"))
+
+	cache.SetTTL(ctx, "c", []any{3}, 0)
+	assert.Equal(t, 2, cache.Len())
+	assert.NotNil(t, cache.Get(ctx, "a"))
+	assert.Nil(t, cache.Get(ctx, "b"))
+	assert.NotNil(t, cache.Get(ctx, "c"))
+
+	// Replacing an existing key doesn't evict anything.
+	cache.SetTTL(ctx, "c", []any{4}, 0)
+	assert.Equal(t, 2, cache.Len())
+	assert.Equal(t, []any{4}, cache.Get(ctx, "c"))
+}
+
+func Test_MemoryCache_Expiry(t *testing.T) {
+	cache := NewMemoryCache(0)
+	ctx := context.Background()
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	// The saved time and TTL trailer from CachedOpts are used for the expiry.
+	cache.SetTTL(ctx, "trailer", []any{1, now, time.Minute}, time.Hour)
+	// Otherwise the TTL passed in is used.
+	cache.SetTTL(ctx, "plain", []any{1}, time.Hour)
+
+	now = now.Add(30 * time.Minute)
+	assert.Nil(t, cache.Get(ctx, "trailer"))
+	assert.NotNil(t, cache.Get(ctx, "plain"))
+	assert.Equal(t, 1, cache.Len())
+
+	now = now.Add(time.Hour)
+	assert.Nil(t, cache.Get(ctx, "plain"))
+	assert.Equal(t, 0, cache.Len())
+}
+
+func Test_MemoryCache_Cached(t *testing.T) {
+	cache := NewMemoryCache(10)
+
+	callCount := 0
+	generator := func(ctx context.Context, key *inputValue) (*outputValue, error) {
+		callCount++
+		return &outputValue{Value: key.Value}, nil
+	}
+
+	input := &inputValue{Value: "1"}
+
+	ctx1 := NewDependencyContext(context.Background(), input, Cached(cache, generator, time.Minute))
+	r1 := Get[*outputValue](ctx1)
+
+	ctx2 := NewDependencyContext(context.Background(), input, Cached(cache, generator, time.Minute))
+	r2 := Get[*outputValue](ctx2)
+
+	assert.Equal(t, 1, callCount)
+	assert.Equal(t, "1", r1.Value)
+	assert.Equal(t, "1", r2.Value)
+}
+
+func Test_MemoryCache_Concurrent(t *testing.T) {
+	cache := NewMemoryCache(50)
+	ctx := context.Background()
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				key := fmt.Sprintf("key-%d", (i*100+j)%75)
+				cache.SetTTL(ctx, key, []any{j}, time.Minute)
+				_ = cache.Get(ctx, key)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 50, cache.Len())
+}
+
+func Test_MemoryCache_DeleteByTag(t *testing.T) {
+	cache := NewMemoryCache(10)
+	ctx := context.Background()
+
+	cache.SetTTLTagged(ctx, "a", []any{"1"}, time.Minute, []string{"user:1"})
+	cache.SetTTLTagged(ctx, "b", []any{"2"}, time.Minute, []string{"user:1", "user:2"})
+	cache.SetTTL(ctx, "c", []any{"3"}, time.Minute)
+
+	cache.DeleteByTag(ctx, "user:1")
+
+	assert.Nil(t, cache.Get(ctx, "a"))
+	assert.Nil(t, cache.Get(ctx, "b"))
+	assert.Equal(t, []any{"3"}, cache.Get(ctx, "c"))
+	assert.Empty(t, cache.tags)
+}
+
+func Test_MemoryCache_TagsReplaced(t *testing.T) {
+	cache := NewMemoryCache(10)
+	ctx := context.Background()
+
+	cache.SetTTLTagged(ctx, "a", []any{"1"}, time.Minute, []string{"old"})
+	cache.SetTTLTagged(ctx, "a", []any{"2"}, time.Minute, []string{"new"})
+
+	cache.DeleteByTag(ctx, "old")
+	assert.Equal(t, []any{"2"}, cache.Get(ctx, "a"))
+
+	cache.DeleteByTag(ctx, "new")
+	assert.Nil(t, cache.Get(ctx, "a"))
+}
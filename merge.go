@@ -0,0 +1,142 @@
+package ctxdep
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Merge creates a new DependencyContext on top of ctx that has all the dependencies from
+// both a and b. This is useful when dependencies are built up in separate places that
+// don't have a parent/child relationship with each other.
+//
+// Any values that a or b have already resolved are carried over. Generators that have not
+// yet run will run in the merged DependencyContext, so their parameters can be satisfied
+// by dependencies from either of the sources. Immediate generators that haven't run yet
+// are started again in the merged DependencyContext, and ImmediateDone reports on them.
+//
+// A dependency declared with Require in one of the sources can be provided by the other.
+// Otherwise, if a type is in both a and b, this panics unless WithOverrides is passed as an option,
+// in which case the dependency from b is used.
+func Merge(ctx context.Context, a, b *DependencyContext, opts ...ContextOption) *DependencyContext {
+	dc := &DependencyContext{
+		parentFixed: true,
+	}
+	dc.setParentContext(ctx)
+	for _, opt := range opts {
+		opt(&dc.options)
+	}
+	dc.loose = dc.options.overrides
+	dc.selfContext = context.WithValue(ctx, dependencyContextKey, dc)
+
+	derived := append(dc.mergeSlots(a), dc.mergeSlots(b)...)
+	// Slots that are stored under a type other than their own are either aliases, or were
+	// assigned to an interface when requested. These are kept only if the slot they refer
+	// to made it into the merged DependencyContext.
+	for _, ds := range derived {
+		if current, ok := dc.slots.Load(ds.s.slotType); !ok || current != ds.s {
+			continue
+		}
+		if _, existing := dc.slots.Load(ds.key); !existing {
+			dc.slots.Store(ds.key, ds.s)
+		}
+	}
+	dc.initialize(dc.selfContext, true)
+	return dc
+}
+
+// Context returns the context that contains this DependencyContext.
+func (d *DependencyContext) Context() context.Context {
+	return d.selfContext
+}
+
+// derivedSlot is a slot that is stored under a type other than its own.
+type derivedSlot struct {
+	key reflect.Type
+	s   *slot
+}
+
+// mergeSlots copies the slots from the source DependencyContext to this one. The slots
+// that are stored under a type other than their own are not added, but are returned so
+// they can be added after all the sources are merged.
+func (d *DependencyContext) mergeSlots(source *DependencyContext) []derivedSlot {
+	// Slots may be stored under more than one type, so keep track of the copies to
+	// keep that sharing intact.
+	copies := map[*slot]*slot{}
+	generatorLocks := map[*sync.Mutex]*sync.Mutex{}
+	copySlot := func(s *slot) *slot {
+		if cs, ok := copies[s]; ok {
+			return cs
+		}
+		lock := s.runLock()
+		lock.Lock()
+		cs := &slot{
+			generator: s.generator,
+			slotType:  s.slotType,
+			status:    s.status,
+			immediate: s.immediate,
+			order:     d.generatorCount + s.order,
+			fallback:  s.fallback,
+			optional:  s.optional,
+			retry:     s.retry,
+			breaker:   s.breaker,
+			timeout:   s.timeout,
+		}
+		cs.storeValue(s.loadValue())
+		lock.Unlock()
+		if s.generatorLock != nil {
+			// The copies of the slots of a generator share a new lock.
+			if generatorLocks[s.generatorLock] == nil {
+				generatorLocks[s.generatorLock] = &sync.Mutex{}
+			}
+			cs.generatorLock = generatorLocks[s.generatorLock]
+		}
+		copies[s] = cs
+		return cs
+	}
+
+	var derived []derivedSlot
+	source.slots.Range(func(key, value any) bool {
+		keyType := key.(reflect.Type)
+		s := value.(*slot)
+		if keyType != s.slotType {
+			derived = append(derived, derivedSlot{key: keyType, s: copySlot(s)})
+			return true
+		}
+		if existing, ok := d.slots.Load(keyType); ok {
+			if s.status == StatusRequired {
+				// A required dependency is satisfied by what is already there.
+				return true
+			}
+			if !d.loose && existing.(*slot).status != StatusRequired {
+				panic(fmt.Sprintf("a slot for type %v already exists--merged contexts may not both have the same type", keyType))
+			}
+		}
+		d.slots.Store(keyType, copySlot(s))
+		return true
+	})
+	source.keyedSlots.Range(func(key, value any) bool {
+		if _, existing := d.keyedSlots.Load(key); existing && !d.loose {
+			k := key.(keyedSlotKey)
+			panic(fmt.Sprintf("a slot for type %v with key %q already exists--merged contexts may not both have the same type", k.slotType, k.name))
+		}
+		s := value.(*slot)
+		d.keyedSlots.Store(key, newValueSlot(s.slotType, s.loadValue(), s.status))
+		return true
+	})
+	for contributionType, cs := range source.contributions {
+		for _, c := range cs {
+			c.lock.Lock()
+			d.addContribution(&contribution{
+				contributionType: contributionType,
+				value:            c.value,
+				generator:        c.generator,
+			}, nil)
+			c.lock.Unlock()
+		}
+	}
+	// The generators of the source come after the ones that are already merged.
+	d.generatorCount += source.generatorCount
+	return derived
+}
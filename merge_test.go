@@ -0,0 +1,106 @@
+package ctxdep
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"reflect"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+func Test_Merge(t *testing.T) {
+	a := GetDependencyContext(NewDependencyContext(context.Background(), &testWidget{Val: 42}))
+	b := GetDependencyContext(NewDependencyContext(context.Background(), Require[*testWidget](), func(w *testWidget) *testDoodad {
+		return &testDoodad{Val: strconv.Itoa(w.Val)}
+	}))
+
+	ctx := Merge(context.Background(), a, b).Context()
+
+	assert.Equal(t, "42", Get[*testDoodad](ctx).Val)
+	assert.Equal(t, "*ctxdep.testDoodad - created from generator: (*ctxdep.testWidget) *ctxdep.testDoodad\n*ctxdep.testWidget - direct value set", Status(ctx))
+
+	// The generator ran in the merged context, the source is unchanged.
+	_, err := GetWithError[*testDoodad](b.Context())
+	assert.EqualError(t, err, "required dependency not provided: *ctxdep.testWidget")
+}
+
+func Test_Merge_ResolvedValues(t *testing.T) {
+	calls := 0
+	a := GetDependencyContext(NewDependencyContext(context.Background(), func() *testWidget {
+		calls++
+		return &testWidget{Val: 42}
+	}))
+	assert.Equal(t, 42, Get[*testWidget](a.Context()).Val)
+
+	b := GetDependencyContext(NewDependencyContext(context.Background(), Keyed("name", &testDoodad{Val: "keyed"})))
+
+	ctx := Merge(context.Background(), a, b).Context()
+
+	assert.Equal(t, 42, Get[*testWidget](ctx).Val)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, "keyed", GetKeyed[*testDoodad](ctx, "name").Val)
+}
+
+func Test_Merge_Conflict(t *testing.T) {
+	a := GetDependencyContext(NewDependencyContext(context.Background(), &testWidget{Val: 1}))
+	b := GetDependencyContext(NewDependencyContext(context.Background(), &testWidget{Val: 2}))
+
+	assert.PanicsWithValue(t, "a slot for type *ctxdep.testWidget already exists--merged contexts may not both have the same type", func() {
+		Merge(context.Background(), a, b)
+	})
+
+	ctx := Merge(context.Background(), a, b, WithOverrides()).Context()
+	assert.Equal(t, 2, Get[*testWidget](ctx).Val)
+}
+
+func Test_Merge_Interfaces(t *testing.T) {
+	a := GetDependencyContext(NewDependencyContext(context.Background(), &testImpl{val: 1}))
+	assert.Equal(t, 1, Get[testInterface](a.Context()).getVal())
+	b := GetDependencyContext(NewDependencyContext(context.Background(), &testImpl{val: 2}))
+	assert.Equal(t, 2, Get[testInterface](b.Context()).getVal())
+
+	ctx := Merge(context.Background(), a, b, WithOverrides()).Context()
+	assert.Equal(t, 2, Get[*testImpl](ctx).val)
+	assert.Equal(t, 2, Get[testInterface](ctx).getVal())
+}
+
+func Test_Merge_Immediate(t *testing.T) {
+	var calls int32
+	a := GetDependencyContext(NewDependencyContext(context.Background(), Require[*testWidget](), WithImmediateErrorHandler(func(reflect.Type, error) {}), Immediate(func(w *testWidget) *testDoodad {
+		atomic.AddInt32(&calls, 1)
+		return &testDoodad{Val: strconv.Itoa(w.Val)}
+	})))
+	for range a.ImmediateDone() {
+	}
+	b := GetDependencyContext(NewDependencyContext(context.Background(), &testWidget{Val: 42}))
+
+	merged := Merge(context.Background(), a, b)
+
+	// The immediate generator couldn't run in a, but it runs in the merged context.
+	var errs []error
+	for err := range merged.ImmediateDone() {
+		errs = append(errs, err)
+	}
+	assert.Empty(t, errs)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	assert.Equal(t, "42", Get[*testDoodad](merged.Context()).Val)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	// Without any immediate dependencies, the channel is already closed.
+	empty := Merge(context.Background(), b, GetDependencyContext(NewDependencyContext(context.Background(), &testDoodad{})))
+	_, open := <-empty.ImmediateDone()
+	assert.False(t, open)
+}
+
+func Test_Merge_KeyedSlotsCopied(t *testing.T) {
+	a := GetDependencyContext(NewDependencyContext(context.Background(), Keyed("name", &testWidget{Val: 1})))
+	b := GetDependencyContext(NewDependencyContext(context.Background(), &testDoodad{}))
+
+	merged := Merge(context.Background(), a, b)
+
+	sa, _ := a.keyedSlots.Load(keyedSlotKey{slotType: reflect.TypeOf(&testWidget{}), name: "name"})
+	sm, _ := merged.keyedSlots.Load(keyedSlotKey{slotType: reflect.TypeOf(&testWidget{}), name: "name"})
+	assert.NotSame(t, sa, sm)
+	assert.Equal(t, 1, GetKeyed[*testWidget](merged.Context(), "name").Val)
+}
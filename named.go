@@ -0,0 +1,54 @@
+package ctxdep
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// GetFrom returns the value of type T from the dependency context that was named with
+// WithName. It otherwise behaves exactly like GetFromWithError, but panics if the value can't
+// be provided, or logs the error and returns the zero value if SetPanicOnMissing has been
+// used to turn off panics.
+func GetFrom[T any](ctx context.Context, name string) T {
+	value, err := GetFromWithError[T](ctx, name)
+	if err != nil {
+		if PanicOnMissing() {
+			panic(err)
+		}
+		logf("error getting dependency: %v", err)
+		var zero T
+		return zero
+	}
+	return value
+}
+
+// GetFromWithError returns the value of type T from the dependency context that was named
+// with WithName. The dependency contexts of ctx are searched, starting with the closest one,
+// for the first one with the name, and T is resolved there, bypassing any of its children that
+// also provide T. If T isn't in the named dependency context, its parents are searched as
+// usual. If there is no dependency context with the name, an error is returned.
+func GetFromWithError[T any](ctx context.Context, name string) (T, error) {
+	var target T
+	dc := GetDependencyContext(ctx).namedContext(name)
+	if dc == nil {
+		return target, &DependencyError{
+			Message:        fmt.Sprintf("no dependency context named %q", name),
+			ReferencedType: reflect.TypeOf((*T)(nil)).Elem(),
+			Status:         GetDependencyContext(ctx).Status(),
+		}
+	}
+	err := dc.FillDependency(ctx, &target)
+	return target, err
+}
+
+// namedContext finds the closest dependency context, starting with this one, that has the
+// name. If there is none, this returns nil.
+func (d *DependencyContext) namedContext(name string) *DependencyContext {
+	for dc := d; dc != nil; dc = dc.parentDependencyContext() {
+		if dc.options.name == name {
+			return dc
+		}
+	}
+	return nil
+}
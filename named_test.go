@@ -0,0 +1,33 @@
+package ctxdep
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_GetFrom(t *testing.T) {
+	outer := &testWidget{Val: 1}
+	inner := &testWidget{Val: 2}
+
+	ctx := NewDependencyContext(context.Background(), WithName("outer"), outer)
+	ctx = NewDependencyContext(ctx, WithName("middle"), &testDoodad{})
+	ctx = NewDependencyContext(ctx, inner)
+
+	assert.Same(t, inner, Get[*testWidget](ctx))
+	assert.Same(t, outer, GetFrom[*testWidget](ctx, "outer"))
+
+	// A type that the named context doesn't have is found in its parents.
+	assert.Same(t, outer, GetFrom[*testWidget](ctx, "middle"))
+}
+
+func Test_GetFrom_NotFound(t *testing.T) {
+	ctx := NewDependencyContext(context.Background(), WithName("outer"), &testWidget{})
+
+	_, err := GetFromWithError[*testWidget](ctx, "missing")
+	assert.EqualError(t, err, "no dependency context named \"missing\": *ctxdep.testWidget")
+
+	assert.Panics(t, func() {
+		GetFrom[*testWidget](ctx, "missing")
+	})
+}
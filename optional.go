@@ -0,0 +1,125 @@
+package ctxdep
+
+import (
+	"context"
+	"reflect"
+)
+
+// OptionalParam allows a generator to take a parameter that may not be in the dependency
+// context. If the dependency is present, Value is filled in and Present is true. Otherwise,
+// Value is the zero value of T and the generator is still called:
+//
+//	func ClientGenerator(cfg ctxdep.OptionalParam[*Config]) *Client {
+//	    if !cfg.Present {
+//	        return NewClient(defaultConfig)
+//	    }
+//	    return NewClient(cfg.Value)
+//	}
+//
+// Since an optional parameter can always be satisfied, it is never a reason for a generator
+// to fail validation.
+type OptionalParam[T any] struct {
+	// Value is the dependency that was found, or the zero value if it wasn't.
+	Value T
+
+	// Present is true if the dependency was found.
+	Present bool
+}
+
+func (o *OptionalParam[T]) optionalType() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+func (o *OptionalParam[T]) setOptionalValue(value any) {
+	o.Value = value.(T)
+	o.Present = true
+}
+
+// optionalParameter is implemented by OptionalParam.
+type optionalParameter interface {
+	optionalType() reflect.Type
+	setOptionalValue(value any)
+}
+
+var optionalParameterType = reflect.TypeOf((*optionalParameter)(nil)).Elem()
+
+// isOptionalParameter returns if the generator parameter type is a request for an optional
+// dependency.
+func isOptionalParameter(paramType reflect.Type) bool {
+	return paramType.Kind() == reflect.Struct && reflect.PointerTo(paramType).Implements(optionalParameterType)
+}
+
+// getOptionalParameter returns the value for an optional parameter of the given type. If the
+// dependency is not in the dependency context, the returned value is the zero value of the
+// parameter.
+func (d *DependencyContext) getOptionalParameter(ctx context.Context, paramType reflect.Type) (reflect.Value, error) {
+	paramPointerValue := reflect.New(paramType)
+	op := paramPointerValue.Interface().(optionalParameter)
+	if d.hasType(op.optionalType()) {
+		targetPointerValue := reflect.New(op.optionalType())
+		err := d.FillDependency(ctx, targetPointerValue.Interface())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		op.setOptionalValue(targetPointerValue.Elem().Interface())
+	}
+	return paramPointerValue.Elem(), nil
+}
+
+// optionalGenerator is an internal wrapper to signal to the DependencyContext that the
+// generator should be dropped, rather than failing validation, if its parameters can't be
+// resolved. This is created by OptionalGenerator().
+type optionalGenerator struct {
+	generator any
+}
+
+// OptionalGenerator wraps a generator so that it's only added to the dependency context if
+// all of its parameters can be resolved. Normally, a generator with a parameter that can't
+// be resolved makes NewDependencyContext panic. An optional generator is silently dropped
+// instead, so that requesting one of its results fails the same as any other dependency that
+// isn't in the dependency context:
+//
+//	ctx = ctxdep.NewDependencyContext(ctx, ctxdep.OptionalGenerator(func(cfg *MetricsConfig) *MetricsClient {
+//	    return NewMetricsClient(cfg)
+//	}))
+//
+// Other generators that need the results of a dropped generator still fail validation, unless
+// they are optional themselves or take the results as an OptionalParam.
+func OptionalGenerator(generator any) *optionalGenerator {
+	genType := reflect.TypeOf(generator)
+	if genType == nil || genType.Kind() != reflect.Func {
+		panic("optional generator must be a function")
+	}
+	return &optionalGenerator{
+		generator: generator,
+	}
+}
+
+// addOptionalGenerator adds the generator to the dependency context and marks each of the
+// resulting slots as optional.
+func (d *DependencyContext) addOptionalGenerator(optional *optionalGenerator, immediate *immediateDependencies) {
+	slots := d.addGenerator(optional.generator, immediate)
+	for _, s := range slots {
+		s.optional = true
+	}
+}
+
+// dropInvalidOptionalGenerators removes the slots of the optional generators whose parameters
+// can't be resolved. Since dropping one generator can make another one invalid, this repeats
+// until every remaining optional generator is valid.
+func (d *DependencyContext) dropInvalidOptionalGenerators() {
+	for {
+		dropped := false
+		d.slots.Range(func(key, sa any) bool {
+			s := sa.(*slot)
+			if s.optional && key.(reflect.Type) == s.slotType && !d.isSlotValid(s) {
+				d.slots.Delete(key)
+				dropped = true
+			}
+			return true
+		})
+		if !dropped {
+			return
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package ctxdep
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_OptionalParam_Absent(t *testing.T) {
+	ctx := NewDependencyContext(context.Background(), func(w OptionalParam[*testWidget]) *testDoodad {
+		if !w.Present {
+			return &testDoodad{Val: "default"}
+		}
+		return &testDoodad{Val: "widget"}
+	})
+
+	assert.Equal(t, "default", Get[*testDoodad](ctx).Val)
+}
+
+func Test_OptionalParam_Present(t *testing.T) {
+	parent := NewDependencyContext(context.Background(), &testImpl{val: 42})
+	ctx := NewDependencyContext(parent, func() *testWidget {
+		return &testWidget{Val: 1}
+	}, func(w OptionalParam[*testWidget], i OptionalParam[testInterface]) *testDoodad {
+		assert.True(t, w.Present)
+		assert.True(t, i.Present)
+		assert.Equal(t, 42, i.Value.getVal())
+		return &testDoodad{Val: "widget"}
+	})
+
+	assert.Equal(t, "widget", Get[*testDoodad](ctx).Val)
+}
+
+func Test_OptionalParam_Required(t *testing.T) {
+	ctx := NewDependencyContext(context.Background(), Require[*testWidget](), func(w OptionalParam[*testWidget]) *testDoodad {
+		assert.False(t, w.Present)
+		assert.Nil(t, w.Value)
+		return &testDoodad{Val: "default"}
+	})
+
+	assert.Equal(t, "default", Get[*testDoodad](ctx).Val)
+}
+
+func Test_OptionalParam_Error(t *testing.T) {
+	ctx := NewDependencyContext(context.Background(), func() (*testWidget, error) {
+		return nil, errors.New("expected error")
+	}, func(w OptionalParam[*testWidget]) *testDoodad {
+		return &testDoodad{Val: "default"}
+	})
+
+	_, err := GetWithError[*testDoodad](ctx)
+	assert.EqualError(t, err, "error running generator: *ctxdep.testWidget (expected error)")
+}
+
+func Test_OptionalGenerator(t *testing.T) {
+	gen := func(w *testWidget) (*testDoodad, *testImpl) {
+		return &testDoodad{Val: "doodad"}, &testImpl{val: w.Val}
+	}
+
+	ctx := NewDependencyContext(context.Background(), &testWidget{Val: 42}, OptionalGenerator(gen))
+	assert.Equal(t, "doodad", Get[*testDoodad](ctx).Val)
+	assert.Equal(t, 42, Get[*testImpl](ctx).val)
+
+	ctx = NewDependencyContext(context.Background(), OptionalGenerator(gen))
+	_, err := GetWithError[*testDoodad](ctx)
+	assert.EqualError(t, err, "slot not found for requested type: *ctxdep.testDoodad")
+	_, err = GetWithError[*testImpl](ctx)
+	assert.Error(t, err)
+}
+
+func Test_OptionalGenerator_Chained(t *testing.T) {
+	first := OptionalGenerator(func(w *testWidget) *testDoodad { return &testDoodad{} })
+	second := OptionalGenerator(func(d *testDoodad) *testImpl { return &testImpl{} })
+
+	// Dropping the first generator makes the second one invalid as well.
+	ctx := NewDependencyContext(context.Background(), second, first)
+	assert.False(t, Has[*testImpl](ctx))
+
+	// A generator that isn't optional still fails validation.
+	assert.Panics(t, func() {
+		NewDependencyContext(context.Background(), first, func(d *testDoodad) *testImpl { return &testImpl{} })
+	})
+
+	assert.PanicsWithValue(t, "optional generator must be a function", func() {
+		OptionalGenerator(&testWidget{})
+	})
+}
@@ -0,0 +1,293 @@
+package ctxdep
+
+import (
+	"context"
+	"reflect"
+)
+
+// ContextOption is an option that changes the behavior of a DependencyContext. Options
+// are passed to NewDependencyContext along with the dependencies. They may be anywhere
+// in the list of dependencies, including within slices, and are applied before any of
+// the dependencies are added.
+type ContextOption func(*contextOptions)
+
+// contextOptions holds the settings that can be changed by a ContextOption.
+type contextOptions struct {
+	// recoverGenerators converts panics in generators to errors.
+	recoverGenerators bool
+
+	// overrides allows dependencies to replace others of the same type.
+	overrides bool
+
+	// idempotentValues allows the exact same value to be added more than once.
+	idempotentValues bool
+
+	// cacheNamespace is prefixed to the keys of cached generators.
+	cacheNamespace string
+
+	// noParentHoisting stops values that are found in a parent dependency context from
+	// being saved in this one.
+	noParentHoisting bool
+
+	// copyOnGet holds the types whose values are copied on every request. This includes
+	// the types from the parent dependency contexts.
+	copyOnGet map[reflect.Type]bool
+
+	// callerTiming includes the name of the function that requested a dependency in the
+	// name of the timing span for its resolution.
+	callerTiming bool
+
+	// fallbackResolver is called to make values for types that aren't otherwise available.
+	fallbackResolver func(ctx context.Context, t reflect.Type) (any, error)
+
+	// configResolver is called to get values from a configuration source for types that
+	// aren't otherwise available, before the fallbackResolver.
+	configResolver func(ctx context.Context, t reflect.Type) (any, bool, error)
+
+	// usageTracking allows UnusedGenerators to be called.
+	usageTracking bool
+
+	// strictShadowCheck stops generators from shadowing the direct values of the parent
+	// dependency contexts.
+	strictShadowCheck bool
+
+	// attributes are given to generators that take an Attributes parameter.
+	attributes map[string]string
+
+	// synchronousImmediate runs the immediate generators while the dependency context is
+	// being created instead of in the background.
+	synchronousImmediate bool
+
+	// generatorMiddleware wraps every generator that is run.
+	generatorMiddleware []func(next GeneratorInvoker) GeneratorInvoker
+
+	// name identifies the dependency context for GetFrom.
+	name string
+
+	// frozen stops any child dependency context from adding dependencies.
+	frozen bool
+
+	// maxDepth is the most generators that may be nested when resolving a dependency. If
+	// this is 0, there is no limit.
+	maxDepth int
+
+	// immediateErrorHandler is called with the errors from immediate generators instead
+	// of logging them.
+	immediateErrorHandler func(slotType reflect.Type, err error)
+
+	// resolveHook is called whenever a generator produces, or fails to produce, a value.
+	resolveHook func(slotType reflect.Type, value any, err error)
+}
+
+// applyOptions finds all the ContextOption values in the dependencies and applies them
+// to the dependency context.
+func (d *DependencyContext) applyOptions(deps []any) {
+	for _, dep := range deps {
+		switch v := dep.(type) {
+		case ContextOption:
+			v(&d.options)
+		case []any:
+			d.applyOptions(v)
+		}
+	}
+}
+
+// WithRecoverGenerators causes panics in the generators of the dependency context to be
+// recovered from and returned as a DependencyError. The SourceError of that error is a
+// PanicError with the recovered value and the stack trace. By default, a panic in a
+// generator is not recovered from, so it propagates to the code that requested the
+// dependency.
+func WithRecoverGenerators() ContextOption {
+	return func(o *contextOptions) {
+		o.recoverGenerators = true
+	}
+}
+
+// WithOverrides allows dependencies to replace earlier dependencies of the same type, the
+// same as with NewLooseDependencyContext. When used with Merge, the dependencies from the
+// second DependencyContext replace those from the first.
+func WithOverrides() ContextOption {
+	return func(o *contextOptions) {
+		o.overrides = true
+	}
+}
+
+// WithResolveHook sets a function that is called whenever a generator in the dependency
+// context runs. It is called once for each of the values the generator produced. If the
+// generator fails, it is called once with the type that was requested, a nil value, and the
+// error. This is meant for observing when, and in what order, dependencies are created.
+//
+// The hook is called after the generator's results are stored, and may be called
+// concurrently from different goroutines.
+func WithResolveHook(hook func(slotType reflect.Type, value any, err error)) ContextOption {
+	return func(o *contextOptions) {
+		o.resolveHook = hook
+	}
+}
+
+// WithIdempotentValues allows the exact same value to be added to the dependency context more
+// than once. Normally, adding a second value for a type panics. With this option, if the value
+// is the same as the existing one, such as the same pointer to a shared singleton, it is
+// ignored instead. A different value for the type still panics.
+func WithIdempotentValues() ContextOption {
+	return func(o *contextOptions) {
+		o.idempotentValues = true
+	}
+}
+
+// WithCacheNamespace prefixes the cache keys of the cached generators that are called from the
+// dependency context, or any of its children, with the namespace. This keeps dependency
+// contexts that share the same Cache, such as one for each tenant, from using each other's
+// cache entries.
+func WithCacheNamespace(namespace string) ContextOption {
+	return func(o *contextOptions) {
+		o.cacheNamespace = namespace
+	}
+}
+
+// WithoutParentHoisting stops the dependency context from saving values that were found in a
+// parent dependency context. Normally, these are saved so that later requests for them don't
+// need to go to the parent, which shows in Status as "imported from parent context". With this
+// option, every request is delegated to the parent and the dependency context is never changed
+// by those lookups. This is mainly useful for debugging.
+func WithoutParentHoisting() ContextOption {
+	return func(o *contextOptions) {
+		o.noParentHoisting = true
+	}
+}
+
+// WithImmediateErrorHandler sets a function that is called when an immediate generator fails
+// or panics, instead of logging the error. This allows for the failures of background
+// generators to be counted or alerted on. The handler is called from the goroutine that ran
+// the generator, so it must be safe to call concurrently. The errors are still delivered on
+// the ImmediateDone channel.
+func WithImmediateErrorHandler(handler func(slotType reflect.Type, err error)) ContextOption {
+	return func(o *contextOptions) {
+		o.immediateErrorHandler = handler
+	}
+}
+
+// WithCallerTiming includes the name of the function that requested a dependency, such as
+// with Get or GetBatch, in the name of the timing span of its generator. For instance,
+// `CtxGen(*User)` becomes `CtxGen(handler.GetUser → *User)`. This makes it clear which
+// request caused a chain of generators to run. This only applies to the top-level request;
+// the spans for the generators that it needs are named as usual. This applies to the
+// dependency context and its children.
+//
+// This only has an effect if EnableTiming is TimingGenerators. Since finding the caller
+// requires walking the stack, this adds some cost to every request that runs a generator.
+func WithCallerTiming() ContextOption {
+	return func(o *contextOptions) {
+		o.callerTiming = true
+	}
+}
+
+// WithFallbackResolver sets a function that is called to make a value for a type that is
+// requested but isn't provided by the dependency context or any of its parents. This allows
+// for wiring by convention, such as constructing simple services that need no configuration.
+// If the resolver returns a value, it's saved as a direct value of the type so the resolver
+// is only called once for each type. If it returns an error, the request fails with it.
+//
+// Since any type may be made by the resolver, generators that have parameters which aren't
+// otherwise available pass validation. This does not affect Has, which only reports on the
+// dependencies that are actually present.
+func WithFallbackResolver(resolver func(ctx context.Context, t reflect.Type) (any, error)) ContextOption {
+	return func(o *contextOptions) {
+		o.fallbackResolver = resolver
+	}
+}
+
+// WithConfigResolver sets a function that is called to get a value from a configuration
+// source, such as the environment, for a type that is requested but isn't provided by the
+// dependency context or any of its parents. The resolver returns false if the type isn't one
+// that it's responsible for, in which case the request continues as if there were no resolver,
+// including calling the fallback resolver if there is one. If it returns a value, it's saved
+// as a direct value of the type so the configuration is only read once for each type.
+//
+// As with WithFallbackResolver, generators that have parameters which aren't otherwise
+// available pass validation since the resolver may provide them.
+func WithConfigResolver(resolver func(ctx context.Context, t reflect.Type) (any, bool, error)) ContextOption {
+	return func(o *contextOptions) {
+		o.configResolver = resolver
+	}
+}
+
+// WithUsageTracking allows DependencyContext.UnusedGenerators to be used to find the
+// generators that were never run. This is intended for tests that check that all of the
+// dependencies that are wired up are actually used.
+func WithUsageTracking() ContextOption {
+	return func(o *contextOptions) {
+		o.usageTracking = true
+	}
+}
+
+// WithMaxDepth limits how deeply generators may be nested when resolving a dependency. If
+// resolving a dependency requires running more than maxDepth generators, one inside the other,
+// the request fails with an error that names the path of types that were being resolved. This
+// guards against wiring that isn't cyclic, but is unreasonably deep. This applies to the
+// dependency context and its children.
+func WithMaxDepth(maxDepth int) ContextOption {
+	return func(o *contextOptions) {
+		o.maxDepth = maxDepth
+	}
+}
+
+// WithFreeze makes the dependency context, and all of its children, read-only. Creating a
+// child dependency context that adds any dependencies to it panics, so the children can only
+// look up the dependencies that are already there. Options may still be given to the
+// children. This is meant for handing a context to code that shouldn't be able to change
+// what it resolves.
+func WithFreeze() ContextOption {
+	return func(o *contextOptions) {
+		o.frozen = true
+	}
+}
+
+// WithName gives the dependency context a name so that dependencies can be resolved from it
+// with GetFrom, even when a child dependency context provides the same types.
+func WithName(name string) ContextOption {
+	return func(o *contextOptions) {
+		o.name = name
+	}
+}
+
+// WithStrictShadowCheck checks that none of the generators of the dependency context, or of
+// its children, make a type that a parent dependency context provides as a direct value,
+// including a direct value of an interface type that the generator's result implements. A
+// child normally shadows the parent's value with its own, which can go unnoticed until the
+// value is requested. With this option, the dependency context panics when it's created,
+// naming the type of the parent's value and the generator that would shadow it.
+func WithStrictShadowCheck() ContextOption {
+	return func(o *contextOptions) {
+		o.strictShadowCheck = true
+	}
+}
+
+// WithAttributes adds attributes to the dependency context that are given to generators with
+// an Attributes parameter. This is meant for scalar values of the request, such as the locale
+// or tenant, that don't need to be dependencies of their own. The attributes also apply to the
+// generators of the children of the dependency context. If this is used more than once, the
+// attributes are combined.
+func WithAttributes(attributes map[string]string) ContextOption {
+	return func(o *contextOptions) {
+		if o.attributes == nil {
+			o.attributes = map[string]string{}
+		}
+		for k, v := range attributes {
+			o.attributes[k] = v
+		}
+	}
+}
+
+// WithSynchronousImmediate runs the immediate generators of the dependency context one at a
+// time, in the order they were added, before NewDependencyContext returns, instead of in the
+// background. Priorities are still honored. An error or panic from an immediate generator is
+// reported the same as without this option, and then NewDependencyContext panics with it so
+// the failure shows up where the dependency context is built. The remaining immediate
+// generators are not run. This is meant for tests that need the immediate generators to have
+// run without waiting for them.
+func WithSynchronousImmediate() ContextOption {
+	return func(o *contextOptions) {
+		o.synchronousImmediate = true
+	}
+}
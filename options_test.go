@@ -0,0 +1,400 @@
+package ctxdep
+
+import (
+	"context"
+	"errors"
+	"github.com/gburgyan/go-timing"
+	"github.com/stretchr/testify/assert"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_WithRecoverGenerators(t *testing.T) {
+	f := func() *testWidget {
+		panic("expected panic")
+	}
+
+	ctx := NewDependencyContext(context.Background(), f, WithRecoverGenerators())
+
+	_, err := GetWithError[*testWidget](ctx)
+	assert.EqualError(t, err, "generator panicked: *ctxdep.testWidget (panic: expected panic)")
+
+	var panicErr *PanicError
+	assert.ErrorAs(t, err, &panicErr)
+	assert.Equal(t, "expected panic", panicErr.Value)
+	assert.Contains(t, string(panicErr.Stack), "callGenerator")
+
+	// The panic is not cached, so the generator is tried again.
+	_, err = GetWithError[*testWidget](ctx)
+	assert.Error(t, err)
+}
+
+func Test_WithRecoverGenerators_Default(t *testing.T) {
+	f := func() *testWidget {
+		panic("expected panic")
+	}
+
+	ctx := NewDependencyContext(context.Background(), f)
+
+	assert.PanicsWithValue(t, "expected panic", func() {
+		_, _ = GetWithError[*testWidget](ctx)
+	})
+}
+
+func Test_ContextOption_InSlice(t *testing.T) {
+	f := func() *testWidget {
+		panic("expected panic")
+	}
+
+	ctx := NewDependencyContext(context.Background(), []any{f, WithRecoverGenerators()})
+
+	_, err := GetWithError[*testWidget](ctx)
+	assert.Error(t, err)
+}
+
+func Test_ContextOption_ParentContextOverride(t *testing.T) {
+	rootCtx := NewDependencyContext(context.Background(), &testWidget{Val: 42})
+
+	// An option before the context does not prevent overriding the parent context.
+	ctx := NewDependencyContext(context.Background(), WithRecoverGenerators(), rootCtx, &testDoodad{Val: "wo0t"})
+
+	assert.Equal(t, 42, Get[*testWidget](ctx).Val)
+}
+
+func Test_WithOverrides(t *testing.T) {
+	ctx := NewDependencyContext(context.Background(), &testWidget{Val: 1}, &testWidget{Val: 2}, WithOverrides())
+
+	assert.Equal(t, 2, Get[*testWidget](ctx).Val)
+}
+
+func Test_WithResolveHook(t *testing.T) {
+	type event struct {
+		slotType reflect.Type
+		value    any
+		err      error
+	}
+	var lock sync.Mutex
+	var events []event
+	hook := func(slotType reflect.Type, value any, err error) {
+		lock.Lock()
+		defer lock.Unlock()
+		events = append(events, event{slotType, value, err})
+	}
+
+	widget := &testWidget{Val: 42}
+	ctx := NewDependencyContext(context.Background(), WithResolveHook(hook), func() (*testWidget, *testImpl) {
+		return widget, &testImpl{val: 1}
+	}, func() (*testDoodad, error) {
+		return nil, errors.New("expected error")
+	})
+
+	assert.Equal(t, 42, Get[*testWidget](ctx).Val)
+	// Already resolved, so the hook isn't called again.
+	assert.Equal(t, 1, Get[*testImpl](ctx).val)
+
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, reflect.TypeOf(widget), events[0].slotType)
+		assert.Same(t, widget, events[0].value)
+		assert.Equal(t, reflect.TypeOf(&testImpl{}), events[1].slotType)
+	}
+
+	_, err := GetWithError[*testDoodad](ctx)
+	assert.Error(t, err)
+	if assert.Len(t, events, 3) {
+		assert.Equal(t, reflect.TypeOf(&testDoodad{}), events[2].slotType)
+		assert.Nil(t, events[2].value)
+		assert.Equal(t, err, events[2].err)
+	}
+}
+
+func Test_WithResolveHook_Concurrent(t *testing.T) {
+	var calls int32
+	ctx := NewDependencyContext(context.Background(), WithResolveHook(func(reflect.Type, any, error) {
+		atomic.AddInt32(&calls, 1)
+	}), func() *testWidget {
+		time.Sleep(10 * time.Millisecond)
+		return &testWidget{Val: 42}
+	})
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			Get[*testWidget](ctx)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func Test_WithIdempotentValues(t *testing.T) {
+	widget := &testWidget{Val: 42}
+	shared := []any{widget, AddAs[testInterface](&testImpl{val: 1})}
+	shared = append(shared, shared[1])
+
+	ctx := NewDependencyContext(context.Background(), WithIdempotentValues(), widget, shared, AliasAs(widget, reflect.TypeOf((*any)(nil)).Elem()), AliasAs(widget, reflect.TypeOf((*any)(nil)).Elem()))
+	assert.Same(t, widget, Get[*testWidget](ctx))
+
+	assert.PanicsWithValue(t, "a slot for type *ctxdep.testWidget already exists--value may not override an existing slot", func() {
+		NewDependencyContext(context.Background(), WithIdempotentValues(), widget, &testWidget{Val: 42})
+	})
+	assert.PanicsWithValue(t, "a slot for type *ctxdep.testWidget already exists--value may not override an existing slot", func() {
+		NewDependencyContext(context.Background(), widget, widget)
+	})
+}
+
+func Test_WithoutParentHoisting(t *testing.T) {
+	parent := NewDependencyContext(context.Background(), &testWidget{Val: 42})
+	ctx := NewDependencyContext(parent, WithoutParentHoisting(), func(w *testWidget) *testDoodad {
+		return &testDoodad{Val: "doodad"}
+	})
+
+	assert.Equal(t, 42, Get[*testWidget](ctx).Val)
+	assert.Equal(t, "doodad", Get[*testDoodad](ctx).Val)
+	assert.Equal(t, "*ctxdep.testDoodad - created from generator: (*ctxdep.testWidget) *ctxdep.testDoodad\n"+
+		"----\nparent dependency context:\n"+
+		"*ctxdep.testWidget - direct value set", Status(ctx))
+}
+
+func Test_WithCallerTiming(t *testing.T) {
+	EnableTiming = TimingGenerators
+	defer func() { EnableTiming = TimingDisable }()
+
+	timingCtx := timing.Root(context.Background())
+	parent := NewDependencyContext(timingCtx, WithCallerTiming(), func() *testWidget {
+		return &testWidget{Val: 42}
+	})
+	ctx := NewDependencyContext(parent, func(w *testWidget) *testDoodad {
+		return &testDoodad{Val: "doodad"}
+	})
+
+	_ = Get[*testDoodad](ctx)
+
+	report := timingCtx.String()
+	assert.Contains(t, report, "CtxGen(go-ctxdep.Test_WithCallerTiming → *ctxdep.testDoodad)")
+	// Only the top-level request includes the caller.
+	assert.Contains(t, report, "CtxGen(*ctxdep.testWidget)")
+}
+
+func Test_WithCallerTiming_Disabled(t *testing.T) {
+	EnableTiming = TimingGenerators
+	defer func() { EnableTiming = TimingDisable }()
+
+	timingCtx := timing.Root(context.Background())
+	ctx := NewDependencyContext(timingCtx, func() *testWidget {
+		return &testWidget{Val: 42}
+	})
+
+	_ = Get[*testWidget](ctx)
+
+	assert.Contains(t, timingCtx.String(), "CtxGen(*ctxdep.testWidget)")
+	assert.NotContains(t, timingCtx.String(), "→")
+}
+
+type resolvedService struct {
+	name string
+}
+
+func Test_WithFallbackResolver(t *testing.T) {
+	var resolved []reflect.Type
+	resolver := func(ctx context.Context, t reflect.Type) (any, error) {
+		resolved = append(resolved, t)
+		switch t {
+		case reflect.TypeOf(&resolvedService{}):
+			return &resolvedService{name: "resolved"}, nil
+		case reflect.TypeOf(&inputValue{}):
+			return nil, errors.New("expected error")
+		case reflect.TypeOf(&outputValue{}):
+			return &testDoodad{}, nil
+		}
+		return nil, nil
+	}
+	parent := NewDependencyContext(context.Background(), &testWidget{Val: 42})
+	// The generator's parameter is satisfiable since the resolver may provide it.
+	ctx := NewDependencyContext(parent, WithFallbackResolver(resolver), func(s *resolvedService) *testDoodad {
+		return &testDoodad{Val: s.name}
+	})
+
+	assert.Equal(t, "resolved", Get[*testDoodad](ctx).Val)
+	assert.Equal(t, "resolved", Get[*resolvedService](ctx).name)
+	// Values from the parent are not resolved.
+	assert.Equal(t, 42, Get[*testWidget](ctx).Val)
+	assert.Equal(t, []reflect.Type{reflect.TypeOf(&resolvedService{})}, resolved)
+	assert.False(t, Has[*testImpl](ctx))
+
+	_, err := GetWithError[*inputValue](ctx)
+	assert.EqualError(t, err, "fallback resolver failed: *ctxdep.inputValue (expected error)")
+	_, err = GetWithError[*outputValue](ctx)
+	assert.EqualError(t, err, "fallback resolver returned invalid value *ctxdep.testDoodad: *ctxdep.outputValue")
+	_, err = GetWithError[*testImpl](ctx)
+	assert.EqualError(t, err, "fallback resolver returned invalid value <nil>: *ctxdep.testImpl")
+}
+
+type configValue struct {
+	url string
+}
+
+func Test_WithConfigResolver(t *testing.T) {
+	var resolved []reflect.Type
+	resolver := func(ctx context.Context, t reflect.Type) (any, bool, error) {
+		resolved = append(resolved, t)
+		switch t {
+		case reflect.TypeOf(&configValue{}):
+			return &configValue{url: "https://example.com"}, true, nil
+		case reflect.TypeOf(&inputValue{}):
+			return nil, true, errors.New("expected error")
+		case reflect.TypeOf(&outputValue{}):
+			return &testDoodad{}, true, nil
+		}
+		return nil, false, nil
+	}
+	fallback := func(ctx context.Context, t reflect.Type) (any, error) {
+		if t == reflect.TypeOf(&resolvedService{}) {
+			return &resolvedService{name: "fallback"}, nil
+		}
+		return nil, nil
+	}
+	parent := NewDependencyContext(context.Background(), &testWidget{Val: 42})
+	// The generator's parameter is satisfiable since the resolver may provide it.
+	ctx := NewDependencyContext(parent, WithConfigResolver(resolver), WithFallbackResolver(fallback), func(c *configValue) *testDoodad {
+		return &testDoodad{Val: c.url}
+	})
+
+	assert.Equal(t, "https://example.com", Get[*testDoodad](ctx).Val)
+	assert.Equal(t, "https://example.com", Get[*configValue](ctx).url)
+	// Values from the parent are not resolved.
+	assert.Equal(t, 42, Get[*testWidget](ctx).Val)
+	assert.Equal(t, []reflect.Type{reflect.TypeOf(&configValue{})}, resolved)
+
+	sa, ok := GetDependencyContext(ctx).slots.Load(reflect.TypeOf(&configValue{}))
+	assert.True(t, ok)
+	assert.Equal(t, StatusDirect, sa.(*slot).status)
+
+	// Types that the config resolver isn't responsible for go to the fallback resolver.
+	assert.Equal(t, "fallback", Get[*resolvedService](ctx).name)
+
+	_, err := GetWithError[*inputValue](ctx)
+	assert.EqualError(t, err, "config resolver failed: *ctxdep.inputValue (expected error)")
+	_, err = GetWithError[*outputValue](ctx)
+	assert.EqualError(t, err, "config resolver returned invalid value *ctxdep.testDoodad: *ctxdep.outputValue")
+
+	noFallback := NewDependencyContext(context.Background(), WithConfigResolver(resolver))
+	_, err = GetWithError[*testImpl](noFallback)
+	assert.EqualError(t, err, "slot not found for requested type: *ctxdep.testImpl")
+}
+
+func Test_WithUsageTracking(t *testing.T) {
+	ctx := NewDependencyContext(context.Background(), WithUsageTracking(), &testImpl{val: 1}, func() (*testWidget, *testDoodad) {
+		return &testWidget{}, &testDoodad{}
+	}, func() *outputValue {
+		return &outputValue{}
+	})
+	dc := GetDependencyContext(ctx)
+
+	assert.Equal(t, []reflect.Type{reflect.TypeOf(&outputValue{}), reflect.TypeOf(&testDoodad{}), reflect.TypeOf(&testWidget{})}, dc.UnusedGenerators())
+
+	_ = Get[*testWidget](ctx)
+	assert.Equal(t, []reflect.Type{reflect.TypeOf(&outputValue{})}, dc.UnusedGenerators())
+
+	_ = Get[*outputValue](ctx)
+	assert.Empty(t, dc.UnusedGenerators())
+
+	assert.PanicsWithValue(t, "UnusedGenerators requires the WithUsageTracking option", func() {
+		GetDependencyContext(NewDependencyContext(context.Background())).UnusedGenerators()
+	})
+}
+
+func Test_WithMaxDepth(t *testing.T) {
+	deps := []any{
+		func(w *testWidget) *testDoodad { return &testDoodad{} },
+		func(i *testImpl) *testWidget { return &testWidget{} },
+		func(in *inputValue) *testImpl { return &testImpl{} },
+		func() *inputValue { return &inputValue{} },
+	}
+
+	parent := NewDependencyContext(context.Background(), WithMaxDepth(3))
+	ctx := NewDependencyContext(parent, deps)
+	_, err := GetWithError[*testDoodad](ctx)
+	assert.EqualError(t, err, "max resolution depth of 3 exceeded (*ctxdep.testDoodad -> *ctxdep.testWidget -> *ctxdep.testImpl -> *ctxdep.inputValue): *ctxdep.inputValue")
+
+	// Shallower requests are fine, and so is the full request once part of it is resolved.
+	assert.NotNil(t, Get[*testWidget](ctx))
+	assert.NotNil(t, Get[*testDoodad](ctx))
+
+	ctx = NewDependencyContext(context.Background(), WithMaxDepth(4), deps)
+	assert.NotNil(t, Get[*testDoodad](ctx))
+}
+
+func Test_WithFreeze(t *testing.T) {
+	parent := NewDependencyContext(context.Background(), WithFreeze(), &testWidget{}, func() *testDoodad { return &testDoodad{} })
+
+	// Children may look up dependencies and be given options, but not add dependencies.
+	child := NewDependencyContext(parent, WithRecoverGenerators())
+	grandchild := NewDependencyContext(child)
+	assert.NotNil(t, Get[*testWidget](grandchild))
+	assert.NotNil(t, Get[*testDoodad](grandchild))
+
+	assert.PanicsWithError(t, "cannot add dependencies to a child of a frozen dependency context", func() {
+		NewDependencyContext(parent, &testImpl{})
+	})
+	assert.PanicsWithError(t, "cannot add dependencies to a child of a frozen dependency context", func() {
+		NewDependencyContext(grandchild, []any{func() *testImpl { return &testImpl{} }})
+	})
+}
+
+func Test_WithStrictShadowCheck(t *testing.T) {
+	parent := NewDependencyContext(context.Background(), WithStrictShadowCheck(), &testWidget{}, AddAs[testInterface](&testImpl{}))
+
+	assert.PanicsWithError(t, "generator () *ctxdep.testWidget shadows the *ctxdep.testWidget direct value of a parent dependency context", func() {
+		NewDependencyContext(parent, func() *testWidget { return &testWidget{} })
+	})
+	assert.PanicsWithError(t, "generator () *ctxdep.testImpl shadows the ctxdep.testInterface direct value of a parent dependency context", func() {
+		NewDependencyContext(NewDependencyContext(parent), func() *testImpl { return &testImpl{} })
+	})
+
+	// Generators for other types are fine, and without the option the child may shadow the parent.
+	assert.NotPanics(t, func() {
+		NewDependencyContext(parent, func() *testDoodad { return &testDoodad{} })
+	})
+	assert.NotPanics(t, func() {
+		loose := NewDependencyContext(context.Background(), &testWidget{})
+		NewDependencyContext(loose, func() *testWidget { return &testWidget{} })
+	})
+}
+
+func Test_WithGeneratorMiddleware(t *testing.T) {
+	var calls []string
+	middleware := func(name string) func(next GeneratorInvoker) GeneratorInvoker {
+		return func(next GeneratorInvoker) GeneratorInvoker {
+			return func(ctx context.Context, slotType reflect.Type, call func() ([]reflect.Value, error)) ([]reflect.Value, error) {
+				calls = append(calls, name+" before "+slotType.String())
+				results, err := next(ctx, slotType, call)
+				calls = append(calls, name+" after "+slotType.String())
+				return results, err
+			}
+		}
+	}
+
+	parent := NewDependencyContext(context.Background(), WithGeneratorMiddleware(middleware("parent")))
+	ctx := NewDependencyContext(parent, WithGeneratorMiddleware(middleware("first")), WithGeneratorMiddleware(middleware("second")),
+		func() *testWidget {
+			calls = append(calls, "generator")
+			return &testWidget{Val: 42}
+		})
+
+	assert.Equal(t, 42, Get[*testWidget](ctx).Val)
+	assert.Equal(t, []string{
+		"parent before *ctxdep.testWidget",
+		"first before *ctxdep.testWidget",
+		"second before *ctxdep.testWidget",
+		"generator",
+		"second after *ctxdep.testWidget",
+		"first after *ctxdep.testWidget",
+		"parent after *ctxdep.testWidget",
+	}, calls)
+}
@@ -0,0 +1,27 @@
+package ctxdep
+
+import (
+	"reflect"
+)
+
+// RequestedType allows a generator to know which type it's being called to produce. If a
+// generator takes a RequestedType parameter, it's filled with the type that was requested
+// from the dependency context when the generator was run. This lets a single generator that
+// produces several types behave differently depending on which one was asked for:
+//
+//	func ClientsGenerator(requested ctxdep.RequestedType) (*ReadClient, *WriteClient) {
+//	    if requested.Type == reflect.TypeOf(&WriteClient{}) {
+//	        ...
+//	    }
+//	    ...
+//	}
+//
+// If the request was for an interface that a result of the generator implements, the
+// interface type is the requested type. A RequestedType parameter can always be satisfied,
+// so it is never a reason for a generator to fail validation.
+type RequestedType struct {
+	// Type is the type that was requested from the dependency context.
+	Type reflect.Type
+}
+
+var requestedTypeType = reflect.TypeOf(RequestedType{})
@@ -0,0 +1,50 @@
+package ctxdep
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"reflect"
+	"testing"
+)
+
+func Test_RequestedType(t *testing.T) {
+	var requested []reflect.Type
+	generator := func(r RequestedType) (*testWidget, *testDoodad) {
+		requested = append(requested, r.Type)
+		if r.Type == reflect.TypeOf(&testWidget{}) {
+			return &testWidget{Val: 1}, &testDoodad{Val: "widget requested"}
+		}
+		return &testWidget{Val: 2}, &testDoodad{Val: "doodad requested"}
+	}
+
+	ctx := NewDependencyContext(context.Background(), generator)
+	assert.Equal(t, "doodad requested", Get[*testDoodad](ctx).Val)
+	assert.Equal(t, 2, Get[*testWidget](ctx).Val)
+	assert.Equal(t, []reflect.Type{reflect.TypeOf(&testDoodad{})}, requested)
+
+	ctx = NewDependencyContext(context.Background(), generator)
+	assert.Equal(t, 1, Get[*testWidget](ctx).Val)
+	assert.Equal(t, []reflect.Type{reflect.TypeOf(&testDoodad{}), reflect.TypeOf(&testWidget{})}, requested)
+}
+
+func Test_RequestedType_Interface(t *testing.T) {
+	var requested reflect.Type
+	ctx := NewDependencyContext(context.Background(), func(ctx context.Context, r RequestedType) *testImpl {
+		requested = r.Type
+		return &testImpl{val: 42}
+	})
+
+	assert.Equal(t, 42, Get[testInterface](ctx).getVal())
+	assert.Equal(t, reflect.TypeOf((*testInterface)(nil)).Elem(), requested)
+}
+
+func Test_RequestedType_Validation(t *testing.T) {
+	ctx := NewDependencyContext(context.Background(), Immediate(func(r RequestedType) *testWidget {
+		return &testWidget{Val: 42}
+	}))
+	for err := range GetDependencyContext(ctx).ImmediateDone() {
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, 42, Get[*testWidget](ctx).Val)
+}
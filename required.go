@@ -0,0 +1,43 @@
+package ctxdep
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// requiredDependency is an internal wrapper to signal to the DependencyContext that a
+// dependency of the given type must be provided by a child dependency context before
+// it can be used. This is created by Require().
+type requiredDependency struct {
+	requiredType reflect.Type
+}
+
+// Require declares that a dependency of type T is required, but is not provided by this
+// dependency context. This is a forward declaration that documents the contract between
+// the layers of dependency contexts: a child dependency context is expected to supply the
+// actual value or generator.
+//
+// Generators in this dependency context may declare T as a parameter and will pass
+// validation. Requesting T directly from a context that has not supplied it returns
+// a "required dependency not provided" error. A value or generator for T that is added
+// to the same dependency context will replace the declaration.
+func Require[T any]() *requiredDependency {
+	return &requiredDependency{
+		requiredType: reflect.TypeOf((*T)(nil)).Elem(),
+	}
+}
+
+// addRequired adds a placeholder slot for a required dependency. If a slot already exists
+// for the type, the placeholder is not added.
+func (d *DependencyContext) addRequired(requiredType reflect.Type) {
+	if existing, ok := d.slots.Load(requiredType); ok {
+		if !d.loose && existing.(*slot).status != StatusRequired {
+			panic(fmt.Sprintf("a slot for type %v already exists--a requirement may not override an existing slot", requiredType))
+		}
+		return
+	}
+	d.slots.Store(requiredType, &slot{
+		slotType: requiredType,
+		status:   StatusRequired,
+	})
+}
@@ -0,0 +1,62 @@
+package ctxdep
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_Require_Unsatisfied(t *testing.T) {
+	ctx := NewDependencyContext(context.Background(), Require[*testWidget]())
+
+	assert.Equal(t, "*ctxdep.testWidget - required - unsatisfied", Status(ctx))
+
+	_, err := GetWithError[*testWidget](ctx)
+	assert.EqualError(t, err, "required dependency not provided: *ctxdep.testWidget")
+}
+
+func Test_Require_SatisfiedByChild(t *testing.T) {
+	base := NewDependencyContext(context.Background(), Require[*testWidget](), &testDoodad{Val: "base"})
+	child := NewDependencyContext(base, &testWidget{Val: 42})
+
+	widget := Get[*testWidget](child)
+	assert.Equal(t, 42, widget.Val)
+
+	doodad := Get[*testDoodad](child)
+	assert.Equal(t, "base", doodad.Val)
+}
+
+func Test_Require_Interface(t *testing.T) {
+	base := NewDependencyContext(context.Background(), Require[testInterface]())
+	child := NewDependencyContext(base, &testImpl{val: 105})
+
+	iface := Get[testInterface](child)
+	assert.Equal(t, 105, iface.getVal())
+
+	_, err := GetWithError[testInterface](base)
+	assert.EqualError(t, err, "required dependency not provided: ctxdep.testInterface")
+}
+
+func Test_Require_GeneratorValidation(t *testing.T) {
+	// A generator depending on a required type validates, but fails when it is run since
+	// generators only resolve from the context they were added to.
+	ctx := NewDependencyContext(context.Background(), Require[*testWidget](), func(w *testWidget) *testDoodad {
+		return &testDoodad{Val: "never"}
+	})
+
+	_, err := GetWithError[*testDoodad](ctx)
+	assert.EqualError(t, err, "required dependency not provided: *ctxdep.testWidget")
+}
+
+func Test_Require_SameContext(t *testing.T) {
+	ctx := NewDependencyContext(context.Background(), Require[*testWidget](), &testWidget{Val: 42}, Require[*testDoodad](), func() *testDoodad {
+		return &testDoodad{Val: "gen"}
+	})
+
+	assert.Equal(t, 42, Get[*testWidget](ctx).Val)
+	assert.Equal(t, "gen", Get[*testDoodad](ctx).Val)
+
+	assert.PanicsWithValue(t, "a slot for type *ctxdep.testWidget already exists--a requirement may not override an existing slot", func() {
+		NewDependencyContext(context.Background(), &testWidget{Val: 42}, Require[*testWidget]())
+	})
+}
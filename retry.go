@@ -0,0 +1,68 @@
+package ctxdep
+
+import (
+	"context"
+	"reflect"
+	"time"
+)
+
+// retryGenerator is an internal wrapper to signal to the DependencyContext that the
+// generator should be retried if it returns an error. This is created by Retry().
+type retryGenerator struct {
+	generator any
+	attempts  int
+	backoff   time.Duration
+}
+
+// Retry wraps a generator so that it is called up to attempts times until it doesn't return
+// an error. The first retry waits for the backoff duration, and the wait doubles for each
+// subsequent retry. If the context is canceled while waiting, no more attempts are made. If
+// every attempt fails, the error from the last one is returned the same as for any other
+// generator.
+//
+// Only errors returned by the generator are retried. Failures to resolve the parameters of
+// the generator are returned immediately.
+//
+// The result of Retry can be passed to NewDependencyContext or Immediate like any other
+// generator.
+func Retry(generator any, attempts int, backoff time.Duration) *retryGenerator {
+	genType := reflect.TypeOf(generator)
+	if genType == nil || genType.Kind() != reflect.Func {
+		panic("retry generator must be a function")
+	}
+	if attempts < 1 {
+		panic("retry attempts must be at least 1")
+	}
+	return &retryGenerator{
+		generator: generator,
+		attempts:  attempts,
+		backoff:   backoff,
+	}
+}
+
+// addRetryGenerator adds the generator to the dependency context and records the retry
+// settings on each of the resulting slots.
+func (d *DependencyContext) addRetryGenerator(retry *retryGenerator, immediate *immediateDependencies) {
+	slots := d.addGenerator(retry.generator, immediate)
+	for _, s := range slots {
+		s.retry = retry
+	}
+}
+
+// invokeRetryGenerator calls the slot's generator until it succeeds or runs out of attempts.
+// The parameters of the last attempt are returned along with its results.
+func (d *DependencyContext) invokeRetryGenerator(ctx context.Context, activeSlot *slot, requestedType reflect.Type) ([]reflect.Value, []reflect.Value, error) {
+	backoff := activeSlot.retry.backoff
+	for attempt := 1; ; attempt++ {
+		results, params, err := d.invokeGenerator(ctx, activeSlot.generator, activeSlot.slotType, requestedType, activeSlot.timeout)
+		if err != nil || attempt >= activeSlot.retry.attempts || d.getGeneratorError(results) == nil {
+			return results, params, err
+		}
+		select {
+		case <-ctx.Done():
+			return results, params, nil
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
@@ -0,0 +1,82 @@
+package ctxdep
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_Retry(t *testing.T) {
+	calls := 0
+	f := func() (*testWidget, error) {
+		calls++
+		if calls < 3 {
+			return nil, fmt.Errorf("attempt %d failed", calls)
+		}
+		return &testWidget{Val: 42}, nil
+	}
+
+	ctx := NewDependencyContext(context.Background(), Retry(f, 3, time.Millisecond))
+
+	assert.Equal(t, 42, Get[*testWidget](ctx).Val)
+	assert.Equal(t, 3, calls)
+}
+
+func Test_Retry_Exhausted(t *testing.T) {
+	calls := 0
+	f := func() (*testWidget, error) {
+		calls++
+		return nil, fmt.Errorf("attempt %d failed", calls)
+	}
+
+	ctx := NewDependencyContext(context.Background(), Retry(f, 2, time.Millisecond))
+
+	_, err := GetWithError[*testWidget](ctx)
+	assert.EqualError(t, err, "error running generator: *ctxdep.testWidget (attempt 2 failed)")
+	assert.Equal(t, 2, calls)
+}
+
+func Test_Retry_Canceled(t *testing.T) {
+	var calls int32
+	f := func() (*testWidget, error) {
+		atomic.AddInt32(&calls, 1)
+		return nil, errors.New("expected error")
+	}
+
+	ctx := NewDependencyContext(context.Background(), Retry(f, 5, time.Hour))
+
+	_, err := GetWithTimeout[*testWidget](ctx, 10*time.Millisecond)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func Test_Retry_Immediate(t *testing.T) {
+	calls := 0
+	f := func() (*testWidget, error) {
+		calls++
+		if calls < 2 {
+			return nil, errors.New("expected error")
+		}
+		return &testWidget{Val: 42}, nil
+	}
+
+	ctx := NewDependencyContext(context.Background(), Immediate(Retry(f, 2, time.Millisecond)))
+	for err := range GetDependencyContext(ctx).ImmediateDone() {
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, 42, Get[*testWidget](ctx).Val)
+}
+
+func Test_Retry_Invalid(t *testing.T) {
+	assert.PanicsWithValue(t, "retry generator must be a function", func() {
+		Retry(&testWidget{}, 2, time.Second)
+	})
+	assert.PanicsWithValue(t, "retry attempts must be at least 1", func() {
+		Retry(func() *testWidget { return nil }, 0, time.Second)
+	})
+}
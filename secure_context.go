@@ -11,7 +11,10 @@ import (
 // used to check for and prevent cyclic dependencies.
 //
 // The reason this exists is to provide extra security around accessing the context and preventing
-// accidental mixing of context information.
+// accidental mixing of context information. The dependency context is only ever served from the
+// baseContext so a caller can never override the dependencies of a generator. Other values that
+// are not found in the baseContext fall back to the timingContext so that ordinary request values,
+// such as trace IDs, are still available to generators.
 type secureContext struct {
 	// baseContext is the context that contains the dependency information. This is the context
 	// that existed when the dependency was created.
@@ -38,5 +41,11 @@ func (h *secureContext) Value(key any) any {
 	if key == cycleKey || key == timing.ContextTimingKey {
 		return h.timingContext.Value(key)
 	}
-	return h.baseContext.Value(key)
+	if key == dependencyContextKey {
+		return h.baseContext.Value(key)
+	}
+	if value := h.baseContext.Value(key); value != nil {
+		return value
+	}
+	return h.timingContext.Value(key)
 }
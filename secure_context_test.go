@@ -2,6 +2,7 @@ package ctxdep
 
 import (
 	"context"
+	"fmt"
 	"github.com/stretchr/testify/assert"
 	"strconv"
 	"testing"
@@ -22,7 +23,7 @@ func Test_secureContext(t *testing.T) {
 	}
 
 	assert.NotNil(t, secCtx.Value("ctx1"))
-	assert.Nil(t, secCtx.Value("ctx2"))
+	assert.Equal(t, "val2", secCtx.Value("ctx2"))
 	assert.NotNil(t, secCtx.Value(cycleKey))
 
 	secDeadline, ok := secCtx.Deadline()
@@ -49,3 +50,30 @@ func Test_ContextSecurity(t *testing.T) {
 
 	assert.Equal(t, "42", *Get[*string](ctxB))
 }
+
+func Test_secureContext_DependencyContextFromBase(t *testing.T) {
+	baseCtx := NewDependencyContext(context.Background(), &testWidget{Val: 42})
+	callerCtx := NewDependencyContext(context.WithValue(context.Background(), "ctx1", "caller"), &testWidget{Val: 105})
+
+	secCtx := &secureContext{
+		baseContext:   context.WithValue(baseCtx, "ctx1", "base"),
+		timingContext: callerCtx,
+	}
+
+	assert.Equal(t, "base", secCtx.Value("ctx1"))
+	assert.Equal(t, 42, Get[*testWidget](secCtx).Val)
+}
+
+func Test_ContextValuesInGenerators(t *testing.T) {
+	type traceKey struct{}
+	gen := func(ctx context.Context, w *testWidget) *testDoodad {
+		return &testDoodad{Val: fmt.Sprintf("%v-%d", ctx.Value(traceKey{}), w.Val)}
+	}
+	ctxA := NewDependencyContext(context.Background(), gen, &testWidget{Val: 42})
+
+	// Values that are not dependencies flow from the caller's context, but the
+	// dependencies are still those of the context the generator was added to.
+	ctxB := NewDependencyContext(context.WithValue(ctxA, traceKey{}, "trace"), &testWidget{Val: 105})
+
+	assert.Equal(t, "trace-42", Get[*testDoodad](ctxB).Val)
+}
@@ -0,0 +1,86 @@
+package ctxdep
+
+import (
+	"reflect"
+	"sync"
+)
+
+// processSingleton is the state of the values of one generator returned by ProcessSingleton.
+type processSingleton struct {
+	lock   sync.Mutex
+	done   bool
+	values map[reflect.Type]reflect.Value
+}
+
+// ProcessSingleton returns a generator that makes the results of the given generator once for
+// the whole process. Normally every dependency context that has a generator runs it on its own
+// and has its own values. With ProcessSingleton, the first dependency context to run the
+// generator saves its results, and every other dependency context gets the same values instead
+// of running it again:
+//
+//	var connectionPool = ctxdep.ProcessSingleton(NewConnectionPool)
+//
+//	ctx = ctxdep.NewDependencyContext(ctx, connectionPool)
+//
+// The values are kept by the returned generator, so as with Lazy, store it and add the same
+// one to every dependency context. Calling ProcessSingleton again, even with the same
+// generator, makes a separate singleton. The returned generator takes the same parameters,
+// but they're only used by the first run. If the generator returns an error or a nil value,
+// nothing is saved and the next request runs it again.
+//
+// The values are never cleaned up, even when the dependency contexts that made or used them
+// are done, so only use this for values that should live as long as the process. Concurrent
+// requests from different dependency contexts wait for the first run to finish, so the
+// generator is called at most once as long as it succeeds.
+func ProcessSingleton(generator any) any {
+	genType := reflect.TypeOf(generator)
+	if genType == nil || genType.Kind() != reflect.Func {
+		panic("ProcessSingleton requires a generator function")
+	}
+	genValue := reflect.ValueOf(generator)
+	ps := &processSingleton{}
+	return reflect.MakeFunc(genType, func(args []reflect.Value) []reflect.Value {
+		ps.lock.Lock()
+		defer ps.lock.Unlock()
+
+		if ps.done {
+			results := make([]reflect.Value, genType.NumOut())
+			for i := range results {
+				outType := genType.Out(i)
+				if outType.AssignableTo(errorType) {
+					results[i] = reflect.Zero(outType)
+				} else {
+					results[i] = ps.values[outType]
+				}
+			}
+			return results
+		}
+
+		var results []reflect.Value
+		if genType.IsVariadic() {
+			results = genValue.CallSlice(args)
+		} else {
+			results = genValue.Call(args)
+		}
+		values := map[reflect.Type]reflect.Value{}
+		for i, result := range results {
+			outType := genType.Out(i)
+			if outType.AssignableTo(errorType) {
+				if !result.IsNil() {
+					return results
+				}
+				continue
+			}
+			switch result.Kind() {
+			case reflect.Pointer, reflect.Interface, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+				if result.IsNil() {
+					return results
+				}
+			}
+			values[outType] = result
+		}
+		ps.values = values
+		ps.done = true
+		return results
+	}).Interface()
+}
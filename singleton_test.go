@@ -0,0 +1,128 @@
+package ctxdep
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+type singletonPool struct {
+	name string
+}
+
+type singletonConfig struct {
+	name string
+}
+
+type singletonFactory struct {
+	name string
+}
+
+func (f *singletonFactory) Make() *singletonPool {
+	return &singletonPool{name: f.name}
+}
+
+func Test_ProcessSingleton(t *testing.T) {
+	calls := 0
+	var lock sync.Mutex
+	newPool := func(ctx context.Context, w *testWidget) (*singletonPool, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		calls++
+		if w.Val == 0 {
+			return nil, errors.New("expected error")
+		}
+		return &singletonPool{name: "pool"}, nil
+	}
+	generator := ProcessSingleton(newPool)
+
+	failing := NewDependencyContext(context.Background(), &testWidget{Val: 0}, generator)
+	_, err := GetWithError[*singletonPool](failing)
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls)
+
+	ctx1 := NewDependencyContext(context.Background(), &testWidget{Val: 1}, generator)
+	ctx2 := NewDependencyContext(context.Background(), &testWidget{Val: 2}, generator)
+
+	var wg sync.WaitGroup
+	pools := make([]*singletonPool, 10)
+	for i := range pools {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if i%2 == 0 {
+				pools[i] = Get[*singletonPool](ctx1)
+			} else {
+				pools[i] = Get[*singletonPool](ctx2)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 2, calls)
+	for _, pool := range pools {
+		assert.Same(t, pools[0], pool)
+	}
+
+	// Wrapping the same function again makes a separate singleton.
+	again := NewDependencyContext(context.Background(), &testWidget{Val: 3}, ProcessSingleton(newPool))
+	assert.NotSame(t, pools[0], Get[*singletonPool](again))
+	assert.Equal(t, 3, calls)
+
+	// A different generator for the same type gets its own value.
+	other := NewDependencyContext(context.Background(), ProcessSingleton(func() *singletonPool {
+		return &singletonPool{name: "other"}
+	}))
+	assert.Equal(t, "other", Get[*singletonPool](other).name)
+	assert.NotSame(t, pools[0], Get[*singletonPool](other))
+
+	// A generator for another type gets its own value.
+	ctx3 := NewDependencyContext(context.Background(), ProcessSingleton(func() *singletonConfig {
+		return &singletonConfig{name: "config"}
+	}))
+	assert.Equal(t, "config", Get[*singletonConfig](ctx3).name)
+
+	assert.PanicsWithValue(t, "ProcessSingleton requires a generator function", func() {
+		ProcessSingleton(&testWidget{})
+	})
+}
+
+func Test_ProcessSingleton_Nil(t *testing.T) {
+	calls := 0
+	generator := ProcessSingleton(func(w *testWidget) *singletonPool {
+		calls++
+		if w.Val == 0 {
+			return nil
+		}
+		return &singletonPool{name: "pool"}
+	})
+
+	// A nil value isn't saved, so the generator runs again.
+	_, err := GetWithError[*singletonPool](NewDependencyContext(context.Background(), &testWidget{Val: 0}, generator))
+	assert.Error(t, err)
+	assert.Equal(t, "pool", Get[*singletonPool](NewDependencyContext(context.Background(), &testWidget{Val: 1}, generator)).name)
+	assert.Equal(t, "pool", Get[*singletonPool](NewDependencyContext(context.Background(), &testWidget{Val: 0}, generator)).name)
+	assert.Equal(t, 2, calls)
+}
+
+func Test_ProcessSingleton_SharedCode(t *testing.T) {
+	// Method values and the generators made by Cached share their code with others, but each
+	// of them is its own singleton.
+	a := ProcessSingleton((&singletonFactory{name: "a"}).Make)
+	b := ProcessSingleton((&singletonFactory{name: "b"}).Make)
+	assert.Equal(t, "a", Get[*singletonPool](NewDependencyContext(context.Background(), a)).name)
+	assert.Equal(t, "b", Get[*singletonPool](NewDependencyContext(context.Background(), b)).name)
+
+	cache := DumbCache{values: make(map[string][]any)}
+	pool := ProcessSingleton(Cached(&cache, func() *singletonPool {
+		return &singletonPool{name: "cached"}
+	}, time.Minute))
+	config := ProcessSingleton(Cached(&cache, func() *singletonConfig {
+		return &singletonConfig{name: "cached"}
+	}, time.Minute))
+	assert.Equal(t, "cached", Get[*singletonPool](NewDependencyContext(context.Background(), pool)).name)
+	assert.Equal(t, "cached", Get[*singletonConfig](NewDependencyContext(context.Background(), config)).name)
+}
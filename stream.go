@@ -0,0 +1,113 @@
+package ctxdep
+
+import (
+	"context"
+	"reflect"
+	"sync"
+)
+
+// streamTracker reads the values from a stream dependency in the background and keeps the
+// most recent one.
+type streamTracker[T any] struct {
+	lock     sync.Mutex
+	latest   T
+	hasValue bool
+
+	// ready is closed once the first value is received, or the stream is closed.
+	ready chan struct{}
+}
+
+// GetLatest returns the most recent value from a stream dependency of type T. A stream
+// dependency is a `<-chan T` that is provided by the dependency context, typically from a
+// generator for something that is updated over time, such as a configuration watcher:
+//
+//	func ConfigWatcher(ctx context.Context) <-chan *Config {
+//	    ...
+//	}
+//
+//	ctx = ctxdep.NewDependencyContext(ctx, ConfigWatcher)
+//	...
+//	config := ctxdep.GetLatest[*Config](ctx)
+//
+// The first call to GetLatest for a stream starts reading it in the background, so from then
+// on the values on the channel are consumed by the dependency context; anything else that
+// reads from the channel will miss values. Until the first value is received, this blocks.
+// If the stream can't be provided, or is closed without ever sending a value, this panics.
+func GetLatest[T any](ctx context.Context) T {
+	value, err := GetLatestWithError[T](ctx)
+	if err != nil {
+		panic(err)
+	}
+	return value
+}
+
+// GetLatestWithError is the same as GetLatest, except it returns an error instead of
+// panicking. If the context is done while waiting for the first value of the stream, the
+// error of the context is returned.
+func GetLatestWithError[T any](ctx context.Context) (T, error) {
+	var zero T
+	streamType := reflect.TypeOf((*<-chan T)(nil)).Elem()
+	owner := GetDependencyContext(ctx).streamOwner(streamType)
+
+	var tracker *streamTracker[T]
+	if ta, ok := owner.streams.Load(streamType); ok {
+		tracker = ta.(*streamTracker[T])
+	} else {
+		var stream <-chan T
+		if err := owner.FillDependency(ctx, &stream); err != nil {
+			return zero, err
+		}
+		ta, loaded := owner.streams.LoadOrStore(streamType, &streamTracker[T]{ready: make(chan struct{})})
+		tracker = ta.(*streamTracker[T])
+		if !loaded {
+			go tracker.track(stream)
+		}
+	}
+
+	select {
+	case <-tracker.ready:
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	}
+	tracker.lock.Lock()
+	defer tracker.lock.Unlock()
+	if !tracker.hasValue {
+		return zero, &DependencyError{
+			Message:        "stream closed without a value",
+			ReferencedType: streamType,
+			Status:         owner.Status(),
+		}
+	}
+	return tracker.latest, nil
+}
+
+// streamOwner returns the dependency context that provides the stream of the given type, so
+// that there is only one reader of the stream regardless of which child dependency context
+// it's requested from. If no dependency context provides it, this dependency context is
+// returned.
+func (d *DependencyContext) streamOwner(streamType reflect.Type) *DependencyContext {
+	for dc := d; dc != nil; dc = dc.parentDependencyContext() {
+		if sa, ok := dc.slots.Load(streamType); ok && sa.(*slot).status != StatusFromParent {
+			return dc
+		}
+	}
+	return d
+}
+
+// track reads the values from the stream until it is closed.
+func (t *streamTracker[T]) track(stream <-chan T) {
+	first := true
+	for value := range stream {
+		t.lock.Lock()
+		t.latest = value
+		t.hasValue = true
+		t.lock.Unlock()
+		if first {
+			close(t.ready)
+			first = false
+		}
+	}
+	if first {
+		close(t.ready)
+	}
+}
@@ -0,0 +1,60 @@
+package ctxdep
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func Test_GetLatest(t *testing.T) {
+	updates := make(chan *testWidget)
+	generatorCalls := 0
+	parent := NewDependencyContext(context.Background(), func() <-chan *testWidget {
+		generatorCalls++
+		return updates
+	})
+	child := NewDependencyContext(parent, &testDoodad{})
+
+	// The channel itself can still be requested.
+	assert.Equal(t, (<-chan *testWidget)(updates), Get[<-chan *testWidget](parent))
+
+	go func() {
+		updates <- &testWidget{Val: 1}
+	}()
+	assert.Equal(t, 1, GetLatest[*testWidget](child).Val)
+
+	updates <- &testWidget{Val: 2}
+	updates <- &testWidget{Val: 3}
+	// The send completes once the value is received, which may be before it is stored.
+	assert.Eventually(t, func() bool {
+		return GetLatest[*testWidget](parent).Val == 3
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, 3, GetLatest[*testWidget](child).Val)
+
+	// The last value is kept after the stream is closed.
+	close(updates)
+	assert.Equal(t, 3, GetLatest[*testWidget](child).Val)
+	assert.Equal(t, 1, generatorCalls)
+}
+
+func Test_GetLatest_Errors(t *testing.T) {
+	ctx := NewDependencyContext(context.Background(), &testDoodad{})
+	_, err := GetLatestWithError[*testWidget](ctx)
+	assert.EqualError(t, err, "slot not found for requested type: <-chan *ctxdep.testWidget")
+
+	closed := make(chan *testWidget)
+	close(closed)
+	ctx = NewDependencyContext(context.Background(), func() <-chan *testWidget { return closed })
+	_, err = GetLatestWithError[*testWidget](ctx)
+	assert.EqualError(t, err, "stream closed without a value: <-chan *ctxdep.testWidget")
+	assert.Panics(t, func() {
+		GetLatest[*testWidget](ctx)
+	})
+
+	ctx = NewDependencyContext(context.Background(), func() <-chan *testWidget { return make(chan *testWidget) })
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err = GetLatestWithError[*testWidget](timeoutCtx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
@@ -0,0 +1,145 @@
+package ctxdep
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// RegisterStruct adds a new dependency context to the context stack with the fields of the
+// deps struct as the dependencies. This allows for the dependencies to be declared as a
+// struct instead of a long list of parameters:
+//
+//	type AppDeps struct {
+//	    DB      *sql.DB
+//	    Users   UserService
+//	    Session func(ctx context.Context, r *Request) (*Session, error)
+//	    Audit   *AuditLog `ctxdep:"optional"`
+//	    Name    string    `ctxdep:"-"`
+//	}
+//
+//	ctx = ctxdep.RegisterStruct(ctx, &AppDeps{...})
+//
+// Each exported field is added as a dependency: pointer fields as values, func fields as
+// generators, and interface fields as values that can also be requested as the interface
+// type. Fields tagged with `ctxdep:"-"` are skipped. A nil field panics unless it is tagged
+// with `ctxdep:"optional"`, in which case it is left out. Any other kind of field panics.
+func RegisterStruct(ctx context.Context, deps any) context.Context {
+	return NewDependencyContext(ctx, structDependencies(deps)...)
+}
+
+// structDependencies returns the dependencies that are held by the fields of the struct.
+func structDependencies(deps any) []any {
+	v := reflect.ValueOf(deps)
+	if v.Kind() == reflect.Pointer && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("dependencies must be a struct or pointer to a struct, got %v", reflect.TypeOf(deps)))
+	}
+	t := v.Type()
+
+	var result []any
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		skip, optional := parseStructTag(field)
+		if skip {
+			continue
+		}
+
+		fieldVal := v.Field(i)
+		switch field.Type.Kind() {
+		case reflect.Pointer, reflect.Interface, reflect.Func:
+		default:
+			panic(fmt.Sprintf("unsupported dependency field %s.%s of type %v", t.Name(), field.Name, field.Type))
+		}
+		if fieldVal.IsNil() {
+			if optional {
+				continue
+			}
+			panic(fmt.Sprintf("dependency field %s.%s is nil", t.Name(), field.Name))
+		}
+		if field.Type.Kind() == reflect.Interface {
+			result = append(result, AliasAs(fieldVal.Interface(), field.Type))
+		} else {
+			result = append(result, fieldVal.Interface())
+		}
+	}
+	return result
+}
+
+// parseStructTag returns if the field is tagged to be skipped with `ctxdep:"-"`, and if it's
+// tagged with `ctxdep:"optional"`.
+func parseStructTag(field reflect.StructField) (skip bool, optional bool) {
+	tag := field.Tag.Get("ctxdep")
+	if tag == "-" {
+		return true, false
+	}
+	for _, opt := range strings.Split(tag, ",") {
+		if opt == "optional" {
+			optional = true
+		}
+	}
+	return false, optional
+}
+
+// GetInto returns a new T, which must be a struct, with each of its exported fields filled in
+// from the dependency context by the type of the field. This is the counterpart of
+// RegisterStruct for getting many dependencies at once:
+//
+//	type HandlerDeps struct {
+//	    DB    *sql.DB
+//	    Users UserService
+//	    Audit *AuditLog `ctxdep:"optional"`
+//	}
+//
+//	deps := ctxdep.GetInto[HandlerDeps](ctx)
+//
+// Fields tagged with `ctxdep:"-"` are skipped. Fields tagged with `ctxdep:"optional"` are left
+// as the zero value if the dependency context can't provide them. It otherwise behaves exactly
+// like Get.
+func GetInto[T any](ctx context.Context) *T {
+	result, err := GetIntoWithError[T](ctx)
+	if err != nil {
+		if PanicOnMissing() {
+			panic(err)
+		}
+		logf("error getting dependency: %v", err)
+		return nil
+	}
+	return result
+}
+
+// GetIntoWithError is the same as GetInto, except it returns an error if any of the fields
+// that aren't optional can't be filled in. All the fields are attempted even if one fails. If
+// more than one fails, the returned error is a MultiError of the individual errors.
+func GetIntoWithError[T any](ctx context.Context) (*T, error) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("GetInto requires a struct type, got %v", t))
+	}
+	dc := GetDependencyContext(ctx)
+	result := reflect.New(t)
+	v := result.Elem()
+
+	var targets []any
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		skip, optional := parseStructTag(field)
+		if skip || (optional && !dc.hasType(field.Type)) {
+			continue
+		}
+		targets = append(targets, v.Field(i).Addr().Interface())
+	}
+	if err := fillAll(ctx, targets...); err != nil {
+		return nil, err
+	}
+	return result.Interface().(*T), nil
+}
@@ -0,0 +1,92 @@
+package ctxdep
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+)
+
+func Test_RegisterStruct(t *testing.T) {
+	type deps struct {
+		Widget    *testWidget
+		Impl      testInterface
+		Generator func(w *testWidget) *testDoodad
+		Optional  *outputValue `ctxdep:"optional"`
+		Skipped   int          `ctxdep:"-"`
+		private   int
+	}
+
+	ctx := RegisterStruct(context.Background(), &deps{
+		Widget: &testWidget{Val: 42},
+		Impl:   &testImpl{val: 1},
+		Generator: func(w *testWidget) *testDoodad {
+			return &testDoodad{Val: strconv.Itoa(w.Val)}
+		},
+	})
+
+	assert.Equal(t, "42", Get[*testDoodad](ctx).Val)
+	assert.Equal(t, 1, Get[testInterface](ctx).getVal())
+	assert.False(t, Has[*outputValue](ctx))
+	assert.Equal(t, "*ctxdep.testDoodad - created from generator: (*ctxdep.testWidget) *ctxdep.testDoodad\n"+
+		"*ctxdep.testImpl - direct value set\n"+
+		"*ctxdep.testWidget - direct value set\n"+
+		"ctxdep.testInterface - assigned from *ctxdep.testImpl", Status(ctx))
+}
+
+func Test_RegisterStruct_Invalid(t *testing.T) {
+	type nilField struct {
+		Widget *testWidget
+	}
+	assert.PanicsWithValue(t, "dependency field nilField.Widget is nil", func() {
+		RegisterStruct(context.Background(), &nilField{})
+	})
+
+	type badField struct {
+		Val int
+	}
+	assert.PanicsWithValue(t, "unsupported dependency field badField.Val of type int", func() {
+		RegisterStruct(context.Background(), badField{})
+	})
+
+	assert.PanicsWithValue(t, "dependencies must be a struct or pointer to a struct, got *ctxdep.testWidget", func() {
+		RegisterStruct(context.Background(), (*testWidget)(nil))
+	})
+}
+
+func Test_GetInto(t *testing.T) {
+	type handlerDeps struct {
+		Widget *testWidget
+		Doodad *testDoodad
+		Impl   testInterface
+		Output *outputValue `ctxdep:"optional"`
+		Input  *inputValue  `ctxdep:"-"`
+		other  *testWidget
+	}
+
+	ctx := NewDependencyContext(context.Background(), &testWidget{Val: 42}, &testImpl{val: 7}, func(w *testWidget) *testDoodad {
+		return &testDoodad{Val: strconv.Itoa(w.Val)}
+	})
+
+	deps := GetInto[handlerDeps](ctx)
+	assert.Equal(t, 42, deps.Widget.Val)
+	assert.Equal(t, "42", deps.Doodad.Val)
+	assert.Equal(t, 7, deps.Impl.getVal())
+	assert.Nil(t, deps.Output)
+	assert.Nil(t, deps.Input)
+	assert.Nil(t, deps.other)
+
+	type missingDeps struct {
+		Widget *testWidget
+		Output *outputValue
+	}
+	_, err := GetIntoWithError[missingDeps](ctx)
+	assert.EqualError(t, err, "slot not found for requested type: *ctxdep.outputValue")
+	assert.Panics(t, func() {
+		GetInto[missingDeps](ctx)
+	})
+
+	assert.PanicsWithValue(t, "GetInto requires a struct type, got *ctxdep.testWidget", func() {
+		GetInto[*testWidget](ctx)
+	})
+}
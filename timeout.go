@@ -0,0 +1,49 @@
+package ctxdep
+
+import (
+	"reflect"
+	"time"
+)
+
+// timeoutGenerator is an internal wrapper to signal to the DependencyContext that the
+// generator should be given a context with a timeout. This is created by WithTimeout().
+type timeoutGenerator struct {
+	generator any
+	timeout   time.Duration
+}
+
+// WithTimeout wraps a generator so that each time it's run it's given a context that times
+// out after the duration. This allows a slow generator to have a different limit than the
+// others:
+//
+//	ctx = ctxdep.NewDependencyContext(ctx, ctxdep.WithTimeout(NewReport, 5*time.Second), NewUser)
+//
+// The timeout starts once the parameters of the generator are resolved, so it only covers
+// the run of the generator itself and the generators of its parameters don't see it. If the
+// context of the request already has an earlier deadline, such as from GetWithTimeout, the
+// earlier one applies.
+//
+// This does not interrupt the generator; it is up to the generator to respect the
+// cancellation of its context, typically by returning the error of the context.
+func WithTimeout(generator any, timeout time.Duration) *timeoutGenerator {
+	genType := reflect.TypeOf(generator)
+	if genType == nil || genType.Kind() != reflect.Func {
+		panic("timeout generator must be a function")
+	}
+	if timeout <= 0 {
+		panic("generator timeout must be positive")
+	}
+	return &timeoutGenerator{
+		generator: generator,
+		timeout:   timeout,
+	}
+}
+
+// addTimeoutGenerator adds the generator to the dependency context and records the timeout
+// on each of the resulting slots.
+func (d *DependencyContext) addTimeoutGenerator(tg *timeoutGenerator, immediate *immediateDependencies) {
+	slots := d.addGenerator(tg.generator, immediate)
+	for _, s := range slots {
+		s.timeout = tg.timeout
+	}
+}
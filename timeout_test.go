@@ -0,0 +1,73 @@
+package ctxdep
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func Test_WithTimeout(t *testing.T) {
+	var deadline time.Time
+	slow := func(ctx context.Context) (*testWidget, error) {
+		deadline, _ = ctx.Deadline()
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Second):
+			return &testWidget{Val: 42}, nil
+		}
+	}
+	fast := func(ctx context.Context) *testDoodad {
+		_, hasDeadline := ctx.Deadline()
+		assert.False(t, hasDeadline)
+		return &testDoodad{Val: "fast"}
+	}
+
+	ctx := NewDependencyContext(context.Background(), WithTimeout(slow, 10*time.Millisecond), fast)
+
+	start := time.Now()
+	_, err := GetWithError[*testWidget](ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	assert.WithinDuration(t, start.Add(10*time.Millisecond), deadline, 5*time.Millisecond)
+	assert.Equal(t, "fast", Get[*testDoodad](ctx).Val)
+
+	// An earlier deadline of the request applies.
+	ctx = NewDependencyContext(context.Background(), WithTimeout(slow, time.Hour))
+	start = time.Now()
+	_, err = GetWithTimeout[*testWidget](ctx, 10*time.Millisecond)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.WithinDuration(t, start.Add(10*time.Millisecond), deadline, 5*time.Millisecond)
+
+	assert.PanicsWithValue(t, "timeout generator must be a function", func() {
+		WithTimeout(&testWidget{}, time.Second)
+	})
+	assert.PanicsWithValue(t, "generator timeout must be positive", func() {
+		WithTimeout(slow, 0)
+	})
+}
+
+func Test_WithTimeout_Parameters(t *testing.T) {
+	param := func(ctx context.Context) *testDoodad {
+		// The timeout doesn't apply to the generators of the parameters.
+		_, hasDeadline := ctx.Deadline()
+		assert.False(t, hasDeadline)
+		time.Sleep(20 * time.Millisecond)
+		return &testDoodad{Val: "param"}
+	}
+	gen := func(ctx context.Context, d *testDoodad) (*testWidget, error) {
+		_, hasDeadline := ctx.Deadline()
+		assert.True(t, hasDeadline)
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return &testWidget{Val: len(d.Val)}, nil
+	}
+
+	ctx := NewDependencyContext(context.Background(), WithTimeout(gen, 10*time.Millisecond), param)
+
+	widget, err := GetWithError[*testWidget](ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, widget.Val)
+}
@@ -0,0 +1,38 @@
+package ctxdep
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// keyFieldCache holds the result of scanning struct types for fields that are tagged with
+// `ctxdep:"key"`. This is keyed on the struct type, and the value is an []int of the
+// indexes of the tagged fields.
+var keyFieldCache sync.Map
+
+// keyFields returns the indexes of the exported fields of the struct type that are tagged
+// with `ctxdep:"key"`. If the type is not a struct, or none of its fields are tagged, this
+// returns nil.
+func keyFields(t reflect.Type) []int {
+	if cached, ok := keyFieldCache.Load(t); ok {
+		return cached.([]int)
+	}
+	var fields []int
+	if t.Kind() == reflect.Struct {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			for _, opt := range strings.Split(field.Tag.Get("ctxdep"), ",") {
+				if opt == "key" {
+					fields = append(fields, i)
+					break
+				}
+			}
+		}
+	}
+	keyFieldCache.Store(t, fields)
+	return fields
+}
@@ -0,0 +1,111 @@
+package ctxdep
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// VerifyResolvable exercises every generator in this dependency context and reports all
+// the failures that occur. This is a stronger check than the validation done when the
+// dependency context is created, which only ensures that the parameters of generators
+// are structurally present. The intent is that this is called from integration tests or
+// as a startup check.
+//
+// Each generator is first checked to ensure its parameters can be fulfilled, then it is
+// run in isolation against a copy of this dependency context so none of the results are
+// saved here. Note that dependencies that are found in a parent dependency context are
+// resolved by the parent and saved there as usual.
+//
+// If any generator fails, the returned error is a MultiError containing a DependencyError
+// for each failing slot type, ordered by the type name. Otherwise, this returns nil.
+func (d *DependencyContext) VerifyResolvable(ctx context.Context) error {
+	var generatorSlots []*slot
+	d.slots.Range(func(key, value any) bool {
+		s := value.(*slot)
+		if key.(reflect.Type) != s.slotType || s.generator == nil {
+			return true
+		}
+		// Only verify a multi-output generator once.
+		if outputs := d.getGeneratorOutputSlots(s); len(outputs) > 0 && outputs[0] != s {
+			return true
+		}
+		generatorSlots = append(generatorSlots, s)
+		return true
+	})
+	sort.Slice(generatorSlots, func(i, j int) bool {
+		return generatorSlots[i].slotType.String() < generatorSlots[j].slotType.String()
+	})
+
+	var errs []error
+	for _, s := range generatorSlots {
+		if !d.isSlotValid(s) {
+			errs = append(errs, &DependencyError{
+				Message:        "generator has dependencies that cannot be resolved",
+				ReferencedType: s.slotType,
+				Status:         d.Status(),
+			})
+			continue
+		}
+		verifyContext := d.cloneUnresolved()
+		target := reflect.New(s.slotType)
+		err := verifyContext.FillDependency(ctx, target.Interface())
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return &MultiError{Errors: errs}
+	}
+	return nil
+}
+
+// cloneUnresolved makes a copy of this dependency context that shares the same parent
+// context and slot definitions, but none of the values that were created by generators.
+func (d *DependencyContext) cloneUnresolved() *DependencyContext {
+	clone := &DependencyContext{
+		parentContext: d.parentContext,
+		parentDC:      d.parentDC,
+		loose:         d.loose,
+		parentFixed:   true,
+		options:       d.options,
+	}
+	clone.selfContext = context.WithValue(d.parentContext, dependencyContextKey, clone)
+
+	generatorLocks := map[*sync.Mutex]*sync.Mutex{}
+	d.slots.Range(func(key, value any) bool {
+		s := value.(*slot)
+		if key.(reflect.Type) != s.slotType {
+			// Assignable slots will get recreated lazily.
+			return true
+		}
+		cs := &slot{
+			generator: s.generator,
+			slotType:  s.slotType,
+			status:    s.status,
+			fallback:  s.fallback,
+			retry:     s.retry,
+			breaker:   s.breaker,
+			timeout:   s.timeout,
+		}
+		cs.storeValue(s.loadValue())
+		if s.generatorLock != nil {
+			if generatorLocks[s.generatorLock] == nil {
+				generatorLocks[s.generatorLock] = &sync.Mutex{}
+			}
+			cs.generatorLock = generatorLocks[s.generatorLock]
+		}
+		if s.generator != nil {
+			cs.storeValue(nil)
+		}
+		clone.slots.Store(key, cs)
+		return true
+	})
+	d.keyedSlots.Range(func(key, value any) bool {
+		clone.keyedSlots.Store(key, value)
+		return true
+	})
+	return clone
+}
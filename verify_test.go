@@ -0,0 +1,82 @@
+package ctxdep
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_VerifyResolvable_Success(t *testing.T) {
+	calls := 0
+	ctx := NewDependencyContext(context.Background(), &testImpl{val: 42}, func(in testInterface) *testWidget {
+		calls++
+		return &testWidget{Val: in.getVal()}
+	}, func(w *testWidget) (*testDoodad, error) {
+		return &testDoodad{Val: fmt.Sprintf("%d", w.Val)}, nil
+	})
+
+	dc := GetDependencyContext(ctx)
+	err := dc.VerifyResolvable(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls)
+
+	// Nothing was saved in the verified context.
+	assert.Equal(t, "*ctxdep.testDoodad - uninitialized - generator: (*ctxdep.testWidget) *ctxdep.testDoodad, error\n*ctxdep.testImpl - direct value set\n*ctxdep.testWidget - uninitialized - generator: (ctxdep.testInterface) *ctxdep.testWidget\nctxdep.testInterface - assigned from *ctxdep.testImpl", dc.Status())
+}
+
+func Test_VerifyResolvable_CollectsFailures(t *testing.T) {
+	ctx := NewDependencyContext(context.Background(), func() (*testWidget, error) {
+		return nil, fmt.Errorf("widget failure")
+	}, func() (*testDoodad, error) {
+		return nil, fmt.Errorf("doodad failure")
+	}, func() *testImpl {
+		return &testImpl{val: 42}
+	})
+
+	err := GetDependencyContext(ctx).VerifyResolvable(context.Background())
+	assert.EqualError(t, err, "error running generator: *ctxdep.testDoodad (doodad failure); error running generator: *ctxdep.testWidget (widget failure)")
+
+	multiErr, ok := err.(*MultiError)
+	assert.True(t, ok)
+	assert.Len(t, multiErr.Errors, 2)
+
+	var depErr *DependencyError
+	assert.ErrorAs(t, err, &depErr)
+}
+
+func Test_VerifyResolvable_MultiOutput(t *testing.T) {
+	calls := 0
+	ctx := NewDependencyContext(context.Background(), func() (*testWidget, *testDoodad) {
+		calls++
+		return &testWidget{Val: 42}, &testDoodad{Val: "doodad"}
+	})
+
+	err := GetDependencyContext(ctx).VerifyResolvable(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, calls)
+}
+
+func Test_VerifyResolvable_AlreadyResolved(t *testing.T) {
+	calls := 0
+	ctx := NewDependencyContext(context.Background(), func() *testWidget {
+		calls++
+		return &testWidget{Val: calls}
+	})
+	assert.Equal(t, 1, Get[*testWidget](ctx).Val)
+
+	// The generator is exercised again, but the existing value is kept.
+	err := GetDependencyContext(ctx).VerifyResolvable(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, 1, Get[*testWidget](ctx).Val)
+}
+
+func Test_VerifyResolvable_Required(t *testing.T) {
+	ctx := NewDependencyContext(context.Background(), Require[*testWidget](), func(w *testWidget) *testDoodad {
+		return &testDoodad{}
+	})
+
+	err := GetDependencyContext(ctx).VerifyResolvable(context.Background())
+	assert.EqualError(t, err, "required dependency not provided: *ctxdep.testWidget")
+}
@@ -0,0 +1,45 @@
+package ctxdep
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// hiddenDependency is an internal wrapper to signal to the DependencyContext that a
+// dependency of the given type should be hidden from the parent dependency contexts. This is
+// created by Without().
+type hiddenDependency struct {
+	hiddenType reflect.Type
+}
+
+// Without hides the dependency of type T that a parent dependency context provides, so that
+// it can't be gotten from this dependency context or any of its children:
+//
+//	ctx = ctxdep.NewDependencyContext(ctx, ctxdep.Without[*AdminClient]())
+//
+// Requesting T returns an error instead of the parent's value, and generators that take T as
+// a parameter fail validation. Only T itself is hidden. A request for an interface that T
+// implements may still be filled by the parent, so hide the interface as well if that should
+// not be available either.
+func Without[T any]() *hiddenDependency {
+	return &hiddenDependency{
+		hiddenType: reflect.TypeOf((*T)(nil)).Elem(),
+	}
+}
+
+// addHidden adds a placeholder slot that hides the type from the parent dependency contexts.
+func (d *DependencyContext) addHidden(hiddenType reflect.Type) {
+	if _, ok := d.slots.Load(hiddenType); ok && !d.loose {
+		panic(fmt.Sprintf("a slot for type %v already exists--Without may not hide a slot of the same dependency context", hiddenType))
+	}
+	d.slots.Store(hiddenType, &slot{
+		slotType: hiddenType,
+		status:   StatusHidden,
+	})
+}
+
+// providesValue checks if the slot can provide a value, which is not the case for the
+// placeholders from Require and Without.
+func (s *slot) providesValue() bool {
+	return s.status != StatusRequired && s.status != StatusHidden
+}
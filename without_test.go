@@ -0,0 +1,38 @@
+package ctxdep
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_Without(t *testing.T) {
+	parent := NewDependencyContext(context.Background(), &testWidget{Val: 42}, &testDoodad{Val: "doodad"})
+	child := NewDependencyContext(parent, Without[*testWidget]())
+	grandchild := NewDependencyContext(child)
+
+	assert.Equal(t, 42, Get[*testWidget](parent).Val)
+	assert.Equal(t, "doodad", Get[*testDoodad](grandchild).Val)
+
+	_, err := GetWithError[*testWidget](child)
+	assert.EqualError(t, err, "dependency hidden with Without: *ctxdep.testWidget")
+	_, err = GetWithError[*testWidget](grandchild)
+	assert.EqualError(t, err, "dependency hidden with Without: *ctxdep.testWidget")
+	assert.False(t, Has[*testWidget](grandchild))
+
+	assert.Contains(t, GetDependencyContext(child).Status(), "*ctxdep.testWidget - hidden from parent context")
+	assert.Equal(t, 1, GetDependencyContext(child).Stats().Hidden)
+}
+
+func Test_Without_Validation(t *testing.T) {
+	parent := NewDependencyContext(context.Background(), &testWidget{Val: 42})
+
+	assert.Panics(t, func() {
+		NewDependencyContext(parent, Without[*testWidget](), func(w *testWidget) *testDoodad {
+			return &testDoodad{}
+		})
+	})
+	assert.Panics(t, func() {
+		NewDependencyContext(context.Background(), &testWidget{}, Without[*testWidget]())
+	})
+}